@@ -0,0 +1,266 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// AffectedRows DML 语句影响行数评估结果
+type AffectedRows struct {
+	Table     string // 被修改的表 `db`.`table`
+	Select    string // RewriteDML2Select 改写后的 SELECT
+	Estimated int64  // 根据 EXPLAIN 预估的影响行数
+	Exact     int64  // SELECT COUNT(*) 统计的影响行数，-1 表示未统计
+	Bounded   bool   // COUNT(*) 达到 AffectedCountLimit 上限，实际影响行数不少于 Exact
+	TableRows int64  // SHOW TABLE STATUS 中的表行数，-1 表示未知
+}
+
+// AffectedRowsAdvise 评估 UPDATE, DELETE, INSERT ... SELECT 语句的影响行数 RES.012
+// 影响行数占大表总行数的比例超过 MaxAffectedRatio 时提升建议级别
+func AffectedRowsAdvise(conn *database.Connector, q *Query4Audit) Rule {
+	var rule = q.RuleOK()
+	if IsIgnoreRule("RES.012") || conn == nil || common.Config.OnlineDSN.Disable || common.Config.TestDSN.Disable {
+		return rule
+	}
+
+	affected, err := EstimateAffectedRows(conn, q.Query)
+	if err != nil {
+		common.Log.Warn("AffectedRowsAdvise Error: %s", err.Error())
+		return rule
+	}
+	if affected == nil {
+		return rule
+	}
+
+	rule = HeuristicRules["RES.012"]
+	rule.Content = affected.String()
+	if affected.Exceeded() {
+		rule.Severity = "L4"
+	}
+	return rule
+}
+
+// EstimateAffectedRows 将 DML 改写为 SELECT 后在线上环境 EXPLAIN，配置 AffectedCountLimit 时使用 COUNT(*) 统计影响行数
+// 非 UPDATE, DELETE, INSERT ... SELECT 语句返回 nil
+func EstimateAffectedRows(conn *database.Connector, sql string) (*AffectedRows, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	var table sqlparser.TableName
+	var limit int64 = -1
+	switch node := stmt.(type) {
+	case *sqlparser.Update:
+		table, limit = affectedTable(node.TableExprs), affectedLimit(node.Limit)
+	case *sqlparser.Delete:
+		table, limit = affectedTable(node.TableExprs), affectedLimit(node.Limit)
+	case *sqlparser.Insert:
+		switch node.Rows.(type) {
+		case *sqlparser.Select, *sqlparser.Union, *sqlparser.ParenSelect:
+			table = node.Table
+		default:
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	if table.Name.IsEmpty() {
+		return nil, fmt.Errorf("can't find table in: %s", sql)
+	}
+
+	tmpConn := *conn
+	if !table.Qualifier.IsEmpty() {
+		tmpConn.Database = table.Qualifier.String()
+	}
+	affected := &AffectedRows{
+		Table:     fmt.Sprintf("`%s`.`%s`", tmpConn.Database, table.Name.String()),
+		Exact:     -1,
+		TableRows: -1,
+	}
+
+	rw := ast.NewRewrite(sql).RewriteDML2Select()
+	if rw.Stmt == nil {
+		return nil, fmt.Errorf("can't rewrite to select: %s", sql)
+	}
+	affected.Select = rw.NewSQL
+
+	// EXPLAIN ANALYZE 会真实执行查询且没有表格输出，使用传统方式预估
+	explainType := database.ExplainType[common.Config.ExplainType]
+	if explainType == database.AnalyzeExplainType {
+		explainType = database.TraditionalExplainType
+	}
+	exp, err := conn.Explain(affected.Select, explainType, database.TraditionalFormatExplain)
+	if err != nil {
+		return nil, err
+	}
+	affected.Estimated = explainAffectedRows(exp.ExplainRows)
+	if limit >= 0 && affected.Estimated > limit {
+		affected.Estimated = limit
+	}
+
+	if status, err := tmpConn.ShowTableStatus(table.Name.String()); err == nil && len(status.Rows) > 0 {
+		affected.TableRows = database.NullInt(status.Rows[0].Rows)
+	}
+
+	if common.Config.AffectedCountLimit > 0 {
+		affected.Exact, affected.Bounded, err = countAffectedRows(conn, rw.Stmt, limit)
+		if err != nil {
+			common.Log.Warn("EstimateAffectedRows COUNT(*) Error: %s", err.Error())
+			affected.Exact = -1
+		}
+	}
+	return affected, nil
+}
+
+// Rows 评估使用的影响行数，COUNT(*) 统计完整时使用精确值，否则使用较大的值
+func (a *AffectedRows) Rows() int64 {
+	if a.Exact >= 0 && !a.Bounded {
+		return a.Exact
+	}
+	if a.Exact > a.Estimated {
+		return a.Exact
+	}
+	return a.Estimated
+}
+
+// Ratio 影响行数占表总行数的百分比，表行数未知时返回 -1
+func (a *AffectedRows) Ratio() float64 {
+	if a.TableRows <= 0 {
+		return -1
+	}
+	return float64(a.Rows()) * 100 / float64(a.TableRows)
+}
+
+// Exceeded 表总行数超过 MinAffectedTableRows 且影响比例超过 MaxAffectedRatio
+func (a *AffectedRows) Exceeded() bool {
+	return a.TableRows > common.Config.MinAffectedTableRows && a.Ratio() > common.Config.MaxAffectedRatio
+}
+
+// String 输出影响行数评估
+func (a *AffectedRows) String() string {
+	content := fmt.Sprintf("%s EXPLAIN 预估影响 %d 行", a.Table, a.Estimated)
+	switch {
+	case a.Exact >= 0 && a.Bounded:
+		content += fmt.Sprintf("，COUNT(*) 统计影响不少于 %d 行", a.Exact)
+	case a.Exact >= 0:
+		content += fmt.Sprintf("，COUNT(*) 统计影响 %d 行", a.Exact)
+	}
+	if ratio := a.Ratio(); ratio >= 0 {
+		content += fmt.Sprintf("，表总行数约 %d 行，约占 %.2f%%", a.TableRows, ratio)
+	}
+	content += "。"
+	if a.Exceeded() {
+		content += fmt.Sprintf("影响行数超过表总行数的 %s%%，长时间持有大量行锁并产生大事务，会导致锁等待和主从延迟，建议按主键范围分批执行。",
+			strconv.FormatFloat(common.Config.MaxAffectedRatio, 'f', -1, 64))
+	}
+	return content
+}
+
+// affectedTable 获取 UPDATE, DELETE 语句中的第一张表
+func affectedTable(exprs sqlparser.TableExprs) sqlparser.TableName {
+	for _, expr := range exprs {
+		switch node := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			if table, ok := node.Expr.(sqlparser.TableName); ok {
+				return table
+			}
+		case *sqlparser.ParenTableExpr:
+			return affectedTable(node.Exprs)
+		case *sqlparser.JoinTableExpr:
+			return affectedTable(sqlparser.TableExprs{node.LeftExpr})
+		}
+	}
+	return sqlparser.TableName{}
+}
+
+// affectedLimit 获取 LIMIT 的行数，没有 LIMIT 或无法确定时返回 -1
+func affectedLimit(limit *sqlparser.Limit) int64 {
+	if limit == nil || limit.Offset != nil {
+		return -1
+	}
+	if val, ok := limit.Rowcount.(*sqlparser.SQLVal); ok && val.Type == sqlparser.IntVal {
+		if n, err := strconv.ParseInt(string(val.Val), 10, 64); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+// explainAffectedRows 根据 EXPLAIN 结果预估 SELECT 返回的行数
+// 最外层查询中各表的 rows * filtered 之积，即 JOIN 的结果集大小
+func explainAffectedRows(rows []database.ExplainRow) int64 {
+	if len(rows) == 0 {
+		return 0
+	}
+	estimated := 1.0
+	for _, row := range rows {
+		if row.ID != rows[0].ID {
+			continue
+		}
+		filtered := row.Filtered
+		if filtered <= 0 || filtered > 100 {
+			filtered = 100
+		}
+		estimated *= float64(row.Rows) * filtered / 100
+	}
+	return int64(estimated + 0.5)
+}
+
+// countAffectedRows 使用 COUNT(*) 统计影响行数，最多统计 AffectedCountLimit 行
+// 返回统计的行数及是否达到统计上限
+func countAffectedRows(conn *database.Connector, stmt sqlparser.Statement, limit int64) (int64, bool, error) {
+	max := common.Config.AffectedCountLimit
+	capped := true
+	if limit >= 0 && limit <= max {
+		max, capped = limit, false
+	}
+
+	var inner string
+	if sel, ok := stmt.(*sqlparser.Select); ok && sel.Distinct == "" && sel.GroupBy == nil && sel.Having == nil && sel.Limit == nil {
+		// 只查询常量，避免 JOIN 后 SELECT * 中的同名列在派生表中冲突
+		count := *sel
+		count.SelectExprs = sqlparser.SelectExprs{&sqlparser.AliasedExpr{Expr: sqlparser.NewIntVal([]byte("1"))}}
+		count.OrderBy = nil
+		count.Limit = &sqlparser.Limit{Rowcount: sqlparser.NewIntVal([]byte(strconv.FormatInt(max, 10)))}
+		inner = sqlparser.String(&count)
+	} else {
+		inner = fmt.Sprintf("select 1 from (%s) as soar_affected limit %d", sqlparser.String(stmt), max)
+	}
+
+	res, err := conn.Query(fmt.Sprintf("select count(*) from (%s) as soar_count", inner))
+	if err != nil {
+		return -1, false, err
+	}
+	defer res.Rows.Close()
+
+	var count int64
+	for res.Rows.Next() {
+		if err = res.Rows.Scan(&count); err != nil {
+			return -1, false, err
+		}
+	}
+	return count, capped && count >= max, res.Rows.Err()
+}
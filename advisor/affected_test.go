@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"github.com/kr/pretty"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func TestEstimateAffectedRows(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgConfig := *common.Config
+	defer func() { *common.Config = orgConfig }()
+	common.Config.AffectedCountLimit = 100
+	conn := *rEnv
+	conn.Database = "sakila"
+	sqls := []string{
+		"DELETE FROM film WHERE film_id < 11",
+		"UPDATE film SET length = 1 WHERE length > 0",
+		"INSERT INTO film_text SELECT film_id, title, description FROM film WHERE film_id = 1",
+	}
+	for _, sql := range sqls {
+		affected, err := EstimateAffectedRows(&conn, sql)
+		if err != nil || affected == nil {
+			t.Errorf("SQL: %s, Error: %v", sql, err)
+			continue
+		}
+		if affected.TableRows <= 0 || affected.Exact < 0 {
+			t.Errorf("SQL: %s, got: %s", sql, pretty.Sprint(affected))
+		}
+	}
+
+	affected, err := EstimateAffectedRows(&conn, "INSERT INTO film_text VALUES (1, 'a', 'b')")
+	if err != nil || affected != nil {
+		t.Errorf("INSERT ... VALUES should be skipped, got: %s, %v", pretty.Sprint(affected), err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestAffectedLimit(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string]int64{
+		"DELETE FROM film WHERE length > 100":          -1,
+		"DELETE FROM film WHERE length > 100 LIMIT 10": 10,
+		"UPDATE film SET length = 1 LIMIT 5000":        5000,
+	}
+	for sql, want := range cases {
+		stmt, err := sqlparser.Parse(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var got int64
+		switch node := stmt.(type) {
+		case *sqlparser.Delete:
+			got = affectedLimit(node.Limit)
+		case *sqlparser.Update:
+			got = affectedLimit(node.Limit)
+		}
+		if got != want {
+			t.Errorf("SQL: %s, want: %d, got: %d", sql, want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestExplainAffectedRows(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := []struct {
+		rows []database.ExplainRow
+		want int64
+	}{
+		{nil, 0},
+		{[]database.ExplainRow{{ID: 1, Rows: 1000, Filtered: 10}}, 100},
+		{[]database.ExplainRow{{ID: 1, Rows: 1000}}, 1000},
+		{[]database.ExplainRow{{ID: 1, Rows: 200, Filtered: 50}, {ID: 1, Rows: 3, Filtered: 100}, {ID: 2, Rows: 999}}, 300},
+	}
+	for _, c := range cases {
+		if got := explainAffectedRows(c.rows); got != c.want {
+			t.Errorf("rows: %s, want: %d, got: %d", pretty.Sprint(c.rows), c.want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestAffectedRowsString(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := []*AffectedRows{
+		{Table: "`sakila`.`film`", Estimated: 10, Exact: -1, TableRows: -1},
+		{Table: "`sakila`.`film`", Estimated: 10, Exact: 8, TableRows: 1000},
+		{Table: "`sakila`.`rental`", Estimated: 50000, Exact: 1000, Bounded: true, TableRows: 200000},
+		{Table: "`sakila`.`rental`", Estimated: 5000, Exact: -1, TableRows: 200000},
+	}
+	err := common.GoldenDiff(func() {
+		for _, c := range cases {
+			fmt.Println(c.Exceeded(), c.String())
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
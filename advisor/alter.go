@@ -0,0 +1,532 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+)
+
+// ALTER TABLE 执行方式，参考 MySQL 5.6/5.7/8.0 Online DDL 文档
+const (
+	AlterAlgorithmInstant = "INSTANT"
+	AlterAlgorithmInplace = "INPLACE"
+	AlterAlgorithmCopy    = "COPY"
+
+	AlterLockNone      = "NONE"
+	AlterLockShared    = "SHARED"
+	AlterLockExclusive = "EXCLUSIVE"
+)
+
+// 用于估算 ALTER TABLE 耗时的处理速度，单位 bytes/s，实际速度与硬件及负载相关，仅供参考
+const (
+	alterRebuildSpeed    = 64 << 20 // INPLACE 重建表
+	alterCopySpeed       = 32 << 20 // COPY 方式复制表，需要逐行写入临时表并重建所有索引
+	alterIndexBuildSpeed = 96 << 20 // 不重建表的二级索引创建
+)
+
+// largeTableSize 超过该大小的表建议使用在线表结构变更工具
+const largeTableSize = 1 << 30
+
+// AlterSpecImpact 单个 ALTER TABLE 子句的影响
+type AlterSpecImpact struct {
+	Spec      string // ALTER TABLE 子句
+	Algorithm string // INSTANT, INPLACE, COPY
+	Lock      string // NONE, SHARED, EXCLUSIVE
+	Rebuild   bool   // 是否重建表
+	Scan      bool   // 不重建表，但需要扫描全表，如添加二级索引
+	Note      string // 补充说明
+}
+
+// AlterImpact 一条 ALTER TABLE 语句的影响评估
+type AlterImpact struct {
+	Table     string
+	Specs     []AlterSpecImpact
+	Algorithm string // 所有子句中最重的执行方式
+	Lock      string // 所有子句中最重的锁级别
+	Rebuild   bool
+	Scan      bool
+
+	Rows       int64 // 表行数，-1 表示未知
+	DataLength int64 // 数据大小，-1 表示未知
+	IndexSize  int64 // 索引大小，-1 表示未知
+	Seconds    int64 // 预计耗时，单位秒，-1 表示未知
+	Risk       string
+	Severity   string
+	UseOSCTool bool // 是否建议使用在线表结构变更工具
+}
+
+var (
+	alterAlgorithmLevel = map[string]int{AlterAlgorithmInstant: 0, AlterAlgorithmInplace: 1, AlterAlgorithmCopy: 2}
+	alterLockLevel      = map[string]int{AlterLockNone: 0, AlterLockShared: 1, AlterLockExclusive: 2}
+	intDisplayWidthExp  = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|integer|bigint)\(\d+\)`)
+)
+
+// AlterImpactAdvise 对 ALTER TABLE 语句中的每个子句进行 ALGORITHM, LOCK 及是否重建表评估
+// 结合线上环境表的大小给出耗时及风险评估，线上环境不可用时只给出执行方式评估
+func AlterImpactAdvise(conn *database.Connector, q *Query4Audit) Rule {
+	var rule = q.RuleOK()
+	if IsIgnoreRule("ALT.005") {
+		return rule
+	}
+
+	var contents []string
+	severity := "L0"
+	for _, stmt := range q.TiStmt {
+		node, ok := stmt.(*tidb.AlterTableStmt)
+		if !ok || node.Table == nil {
+			continue
+		}
+		impact := newAlterImpact(conn, node)
+		contents = append(contents, impact.String())
+		if impact.Severity > severity {
+			severity = impact.Severity
+		}
+	}
+
+	if len(contents) > 0 {
+		rule = HeuristicRules["ALT.005"]
+		rule.Severity = severity
+		rule.Content = strings.Join(contents, "\n")
+	}
+	return rule
+}
+
+// newAlterImpact 评估单条 ALTER TABLE 语句
+func newAlterImpact(conn *database.Connector, node *tidb.AlterTableStmt) *AlterImpact {
+	impact := &AlterImpact{
+		Table:      node.Table.Name.O,
+		Algorithm:  AlterAlgorithmInstant,
+		Lock:       AlterLockNone,
+		Rows:       -1,
+		DataLength: -1,
+		IndexSize:  -1,
+		Seconds:    -1,
+	}
+
+	// 获取线上表的列定义及表大小
+	columns := make(map[string]database.TableDescValue)
+	if conn != nil && !common.Config.OnlineDSN.Disable && !common.Config.TestDSN.Disable {
+		tmpConn := *conn
+		if node.Table.Schema.O != "" {
+			tmpConn.Database = node.Table.Schema.O
+		}
+		if desc, err := tmpConn.ShowColumns(impact.Table); err == nil {
+			for _, col := range desc.DescValues {
+				columns[strings.ToLower(col.Field)] = col
+			}
+		}
+		if status, err := tmpConn.ShowTableStatus(impact.Table); err == nil && len(status.Rows) > 0 {
+			impact.Rows = database.NullInt(status.Rows[0].Rows)
+			impact.DataLength = database.NullInt(status.Rows[0].DataLength)
+			impact.IndexSize = database.NullInt(status.Rows[0].IndexLength)
+		}
+	}
+
+	for _, spec := range node.Specs {
+		for _, s := range ClassifyAlterSpec(spec, node.Specs, common.Config.OnlineDSN.Version, columns) {
+			impact.Specs = append(impact.Specs, s)
+			if alterAlgorithmLevel[s.Algorithm] > alterAlgorithmLevel[impact.Algorithm] {
+				impact.Algorithm = s.Algorithm
+			}
+			if alterLockLevel[s.Lock] > alterLockLevel[impact.Lock] {
+				impact.Lock = s.Lock
+			}
+			impact.Rebuild = impact.Rebuild || s.Rebuild
+			impact.Scan = impact.Scan || s.Scan
+		}
+	}
+	impact.estimate()
+	return impact
+}
+
+// estimate 根据执行方式及表大小估算耗时和风险
+func (impact *AlterImpact) estimate() {
+	if impact.DataLength >= 0 {
+		size := impact.DataLength
+		if impact.IndexSize > 0 {
+			size += impact.IndexSize
+		}
+		switch {
+		case impact.Algorithm == AlterAlgorithmCopy:
+			impact.Seconds = size / alterCopySpeed
+		case impact.Rebuild:
+			impact.Seconds = size / alterRebuildSpeed
+		case impact.Scan:
+			impact.Seconds = impact.DataLength / alterIndexBuildSpeed
+		default:
+			impact.Seconds = 0
+		}
+		impact.UseOSCTool = size >= largeTableSize &&
+			(impact.Algorithm == AlterAlgorithmCopy || impact.Rebuild || impact.Scan)
+	}
+
+	large := impact.UseOSCTool
+	switch {
+	case impact.Algorithm == AlterAlgorithmCopy || impact.Lock != AlterLockNone:
+		impact.Risk, impact.Severity = "高", "L4"
+		if impact.DataLength >= 0 && !large {
+			impact.Risk, impact.Severity = "中", "L2"
+		}
+	case impact.Rebuild || impact.Scan:
+		impact.Risk, impact.Severity = "中", "L2"
+		if impact.DataLength >= 0 && !large {
+			impact.Risk, impact.Severity = "低", "L0"
+		}
+	default:
+		impact.Risk, impact.Severity = "低", "L0"
+	}
+}
+
+// String 格式化输出评估结果
+func (impact *AlterImpact) String() string {
+	var buf []string
+	buf = append(buf, fmt.Sprintf("表 `%s` 的变更评估：", impact.Table))
+	for _, s := range impact.Specs {
+		line := fmt.Sprintf("* %s: ALGORITHM=%s, LOCK=%s, %s", s.Spec, s.Algorithm, s.Lock, rebuildDesc(s.Rebuild))
+		if s.Note != "" {
+			line += ", " + s.Note
+		}
+		buf = append(buf, line)
+	}
+
+	summary := fmt.Sprintf("整体执行方式为 ALGORITHM=%s, LOCK=%s, %s", impact.Algorithm, impact.Lock, rebuildDesc(impact.Rebuild))
+	if impact.Rows >= 0 && impact.DataLength >= 0 {
+		summary += fmt.Sprintf("；表约 %d 行，数据 %s，索引 %s，预计耗时 %s", impact.Rows,
+			common.HumanBytes(impact.DataLength), common.HumanBytes(impact.IndexSize), humanSeconds(impact.Seconds))
+	} else {
+		summary += "；未获取到表大小，无法预估耗时"
+	}
+	summary += fmt.Sprintf("，风险：%s。", impact.Risk)
+	if impact.UseOSCTool {
+		summary += "表较大，建议使用 gh-ost 或 pt-online-schema-change 等在线表结构变更工具执行。"
+	}
+	buf = append(buf, summary)
+	return strings.Join(buf, "\n")
+}
+
+func rebuildDesc(rebuild bool) string {
+	if rebuild {
+		return "需要重建表"
+	}
+	return "不重建表"
+}
+
+// humanSeconds 将秒数转换为易读的时间
+func humanSeconds(seconds int64) string {
+	switch {
+	case seconds < 1:
+		return "小于 1 秒"
+	case seconds < 60:
+		return fmt.Sprintf("%d 秒", seconds)
+	case seconds < 3600:
+		return fmt.Sprintf("%d 分钟", (seconds+59)/60)
+	default:
+		return fmt.Sprintf("%.1f 小时", float64(seconds)/3600)
+	}
+}
+
+// ClassifyAlterSpec 根据 MySQL 版本评估 ALTER TABLE 子句的执行方式
+// columns 为线上表的列定义，用于判断 MODIFY/CHANGE COLUMN 是否修改了数据类型，为空时按修改数据类型评估
+// specs 为同一条 ALTER TABLE 语句中的全部子句，部分子句的执行方式与其他子句有关
+// 一个子句中包含多个列或多个属性时会拆分为多个评估结果
+func ClassifyAlterSpec(spec *tidb.AlterTableSpec, specs []*tidb.AlterTableSpec, version int, columns map[string]database.TableDescValue) []AlterSpecImpact {
+	var impacts []AlterSpecImpact
+	online := version >= 50600
+
+	// inplace 在 5.6 及以上版本可在线执行的操作，低版本使用 COPY 方式
+	inplace := func(sql string, rebuild bool) AlterSpecImpact {
+		if !online {
+			return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+		}
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone, Rebuild: rebuild}
+	}
+	// instant 在 minVersion 及以上版本为 INSTANT，否则为不重建表的 INPLACE
+	instant := func(sql string, minVersion int) AlterSpecImpact {
+		if version >= minVersion {
+			return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInstant, Lock: AlterLockNone}
+		}
+		return inplace(sql, false)
+	}
+	copyTable := func(sql string) AlterSpecImpact {
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+	}
+
+	sql := restoreAlterSpec(spec)
+	switch spec.Tp {
+	case tidb.AlterTableAddColumns:
+		for _, col := range spec.NewColumns {
+			colSQL := "ADD COLUMN " + restoreAlterSpec(col)
+			switch {
+			case columnHasOption(col, tidb.ColumnOptionAutoIncrement):
+				s := inplace(colSQL, true)
+				if online {
+					s.Lock = AlterLockShared
+				}
+				impacts = append(impacts, s)
+			case version >= 80029,
+				version >= 80012 && (spec.Position == nil || spec.Position.Tp == tidb.ColumnPositionNone):
+				// 8.0.12 开始支持 INSTANT 在末尾添加列，8.0.29 开始支持任意位置
+				impacts = append(impacts, instant(colSQL, 80012))
+			default:
+				impacts = append(impacts, inplace(colSQL, true))
+			}
+		}
+
+	case tidb.AlterTableDropColumn:
+		if version >= 80029 {
+			impacts = append(impacts, instant(sql, 80029))
+		} else {
+			impacts = append(impacts, inplace(sql, true))
+		}
+
+	case tidb.AlterTableModifyColumn, tidb.AlterTableChangeColumn:
+		for _, col := range spec.NewColumns {
+			oldName := col.Name.Name.L
+			if spec.OldColumnName != nil {
+				oldName = spec.OldColumnName.Name.L
+			}
+			impacts = append(impacts, classifyModifyColumn(sql, oldName, col, version, columns))
+		}
+
+	case tidb.AlterTableRenameColumn:
+		impacts = append(impacts, instant(sql, 80028))
+
+	case tidb.AlterTableAlterColumn:
+		// SET DEFAULT, DROP DEFAULT 只修改元数据
+		impacts = append(impacts, instant(sql, 80000))
+
+	case tidb.AlterTableAddConstraint:
+		impacts = append(impacts, classifyAddConstraint(sql, spec.Constraint, version))
+
+	case tidb.AlterTableDropIndex, tidb.AlterTableDropForeignKey:
+		if online {
+			impacts = append(impacts, inplace(sql, false))
+		} else {
+			impacts = append(impacts, AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone})
+		}
+
+	case tidb.AlterTableDropPrimaryKey:
+		// 同一语句中添加新主键时可以 INPLACE 重建表，只删除主键不添加新主键时只能使用 COPY
+		if alterAddPrimaryKey(specs) {
+			impacts = append(impacts, inplace(sql, true))
+		} else {
+			impacts = append(impacts, copyTable(sql))
+		}
+
+	case tidb.AlterTableRenameIndex:
+		if version >= 50700 {
+			impacts = append(impacts, instant(sql, 80000))
+		} else {
+			impacts = append(impacts, copyTable(sql))
+		}
+
+	case tidb.AlterTableRenameTable:
+		impacts = append(impacts, instant(sql, 80000))
+
+	case tidb.AlterTableIndexInvisible:
+		impacts = append(impacts, instant(sql, 80000))
+
+	case tidb.AlterTableForce:
+		impacts = append(impacts, inplace(sql, true))
+
+	case tidb.AlterTableOption:
+		for _, opt := range spec.Options {
+			impacts = append(impacts, classifyTableOption(opt, version))
+		}
+
+	case tidb.AlterTableLock, tidb.AlterTableAlgorithm:
+		// 用户指定的 LOCK, ALGORITHM 不改变表结构
+		return impacts
+
+	default:
+		// 分区操作等其他变更保守按 COPY 评估
+		s := copyTable(sql)
+		s.Note = "保守按 COPY 评估"
+		impacts = append(impacts, s)
+	}
+	return impacts
+}
+
+// alterAddPrimaryKey 判断 ALTER TABLE 子句中是否添加主键
+func alterAddPrimaryKey(specs []*tidb.AlterTableSpec) bool {
+	for _, spec := range specs {
+		if spec.Tp == tidb.AlterTableAddConstraint && spec.Constraint != nil &&
+			spec.Constraint.Tp == tidb.ConstraintPrimaryKey {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyModifyColumn 评估 MODIFY/CHANGE COLUMN
+func classifyModifyColumn(sql, oldName string, col *tidb.ColumnDef, version int, columns map[string]database.TableDescValue) AlterSpecImpact {
+	online := version >= 50600
+	old, ok := columns[oldName]
+	if !ok || !online {
+		s := AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+		if !ok && online {
+			s.Note = "未获取到原列定义，按修改数据类型评估"
+		}
+		return s
+	}
+
+	oldType := normalizeColumnType(old.Type)
+	newType := normalizeColumnType(col.Tp.InfoSchemaStr())
+	renamed := col.Name.Name.L != oldName
+	notNull := columnHasOption(col, tidb.ColumnOptionNotNull) || columnHasOption(col, tidb.ColumnOptionPrimaryKey)
+	oldNotNull := strings.EqualFold(old.Null, "NO")
+
+	switch {
+	case oldType != newType:
+		// VARCHAR 扩展长度且长度字节数不变时可以 INPLACE 不重建表
+		if version >= 50700 && col.Tp.Tp == mysql.TypeVarchar && strings.HasPrefix(oldType, "varchar(") {
+			var oldLen int
+			fmt.Sscanf(oldType, "varchar(%d)", &oldLen)
+			charBytes := collationBytes(string(old.Collation))
+			if col.Tp.Flen > oldLen && (oldLen*charBytes < 256) == (col.Tp.Flen*charBytes < 256) {
+				return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone,
+					Note: "VARCHAR 扩展长度"}
+			}
+		}
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true,
+			Note: fmt.Sprintf("数据类型由 %s 修改为 %s", oldType, newType)}
+	case notNull != oldNotNull:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone, Rebuild: true,
+			Note: "修改列的 NULL 属性"}
+	case renamed && version < 80028:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone}
+	case version >= 80000:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInstant, Lock: AlterLockNone}
+	default:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone}
+	}
+}
+
+// classifyAddConstraint 评估添加索引、主键、外键
+func classifyAddConstraint(sql string, cons *tidb.Constraint, version int) AlterSpecImpact {
+	if cons == nil {
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+	}
+	if version < 50600 {
+		switch cons.Tp {
+		case tidb.ConstraintKey, tidb.ConstraintIndex, tidb.ConstraintUniq, tidb.ConstraintUniqKey, tidb.ConstraintUniqIndex:
+			// 5.5 InnoDB 二级索引支持 Fast Index Creation，但会阻塞写入
+			return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockShared, Scan: true}
+		}
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+	}
+
+	switch cons.Tp {
+	case tidb.ConstraintPrimaryKey:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone, Rebuild: true}
+	case tidb.ConstraintFulltext:
+		// 表中没有 FTS_DOC_ID 列时添加第一个全文索引需要重建表
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockShared, Scan: true,
+			Note: "添加第一个全文索引时需要重建表"}
+	case tidb.ConstraintForeignKey:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true,
+			Note: "foreign_key_checks=0 时可以使用 INPLACE"}
+	case tidb.ConstraintCheck:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+	}
+	if cons.Option != nil && cons.Option.Tp == model.IndexTypeRtree {
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockShared, Scan: true}
+	}
+	return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone, Scan: true}
+}
+
+// classifyTableOption 评估修改表属性
+func classifyTableOption(opt *tidb.TableOption, version int) AlterSpecImpact {
+	sql := restoreAlterSpec(opt)
+	if version < 50600 {
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+	}
+	switch opt.Tp {
+	case tidb.TableOptionCharset, tidb.TableOptionCollate:
+		if opt.UintValue == tidb.TableOptionCharsetWithConvertTo {
+			return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+		}
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone, Rebuild: true}
+	case tidb.TableOptionEngine:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true}
+	case tidb.TableOptionComment:
+		if version >= 80000 {
+			return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInstant, Lock: AlterLockNone}
+		}
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone}
+	case tidb.TableOptionAutoIncrement, tidb.TableOptionStatsPersistent, tidb.TableOptionStatsAutoRecalc,
+		tidb.TableOptionStatsSamplePages:
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone}
+	default:
+		// ROW_FORMAT, KEY_BLOCK_SIZE 等属性需要重建表
+		return AlterSpecImpact{Spec: sql, Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone, Rebuild: true}
+	}
+}
+
+// restoreAlterSpec 将 ALTER TABLE 子句还原为 SQL
+func restoreAlterSpec(node interface {
+	Restore(ctx *format.RestoreCtx) error
+}) string {
+	var sb strings.Builder
+	ctx := format.NewRestoreCtx(format.DefaultRestoreFlags|format.RestoreStringWithoutCharset, &sb)
+	if err := node.Restore(ctx); err != nil {
+		common.Log.Warn("restoreAlterSpec Error: %s", err.Error())
+	}
+	return sb.String()
+}
+
+func columnHasOption(col *tidb.ColumnDef, tp tidb.ColumnOptionType) bool {
+	for _, opt := range col.Options {
+		if opt.Tp == tp {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeColumnType 去除整型的显示宽度，8.0.19 之后的版本 SHOW COLUMNS 不再显示整型宽度
+func normalizeColumnType(tp string) string {
+	return intDisplayWidthExp.ReplaceAllString(strings.ToLower(strings.TrimSpace(tp)), "$1")
+}
+
+// collationBytes 根据字符序获取每个字符最多占用的字节数
+func collationBytes(collation string) int {
+	collation = strings.ToLower(collation)
+	switch {
+	case strings.HasPrefix(collation, "utf8mb4"):
+		return 4
+	case strings.HasPrefix(collation, "utf8"):
+		return 3
+	case strings.HasPrefix(collation, "latin1"), strings.HasPrefix(collation, "ascii"),
+		strings.HasPrefix(collation, "binary"):
+		return 1
+	case strings.HasPrefix(collation, "gbk"), strings.HasPrefix(collation, "gb2312"):
+		return 2
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+)
+
+func TestClassifyAlterSpec(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := []string{
+		"ALTER TABLE film ADD COLUMN c INT",
+		"ALTER TABLE film ADD COLUMN c INT AFTER title",
+		"ALTER TABLE film DROP COLUMN description",
+		"ALTER TABLE film MODIFY COLUMN title VARCHAR(60) NOT NULL",
+		"ALTER TABLE film MODIFY COLUMN title VARCHAR(255) NOT NULL",
+		"ALTER TABLE film MODIFY COLUMN title VARCHAR(128) NULL",
+		"ALTER TABLE film CHANGE COLUMN title film_title VARCHAR(128) NOT NULL",
+		"ALTER TABLE film MODIFY COLUMN length INT",
+		"ALTER TABLE film ALTER COLUMN rating SET DEFAULT 'G'",
+		"ALTER TABLE film ADD INDEX idx_title(title), DROP INDEX idx_fk_language_id",
+		"ALTER TABLE film ADD FULLTEXT INDEX idx_ft(description)",
+		"ALTER TABLE film DROP PRIMARY KEY",
+		"ALTER TABLE film DROP PRIMARY KEY, ADD PRIMARY KEY(film_id, title)",
+		"ALTER TABLE film ADD PRIMARY KEY(film_id), DROP PRIMARY KEY",
+		"ALTER TABLE film RENAME INDEX idx_title TO idx_film_title",
+		"ALTER TABLE film ENGINE = InnoDB, COMMENT = 'film'",
+		"ALTER TABLE film CONVERT TO CHARACTER SET utf8mb4",
+	}
+	columns := map[string]database.TableDescValue{
+		"title":  {Field: "title", Type: "varchar(128)", Collation: []byte("utf8mb4_general_ci"), Null: "NO"},
+		"length": {Field: "length", Type: "smallint(5) unsigned", Null: "YES"},
+	}
+	err := common.GoldenDiff(func() {
+		for _, version := range []int{50542, 50640, 50726, 80023, 80030} {
+			fmt.Println("Version:", version)
+			for _, sql := range sqls {
+				stmts, err := ast.TiParse(sql, "", "")
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				for _, spec := range stmts[0].(*tidb.AlterTableStmt).Specs {
+					for _, s := range ClassifyAlterSpec(spec, stmts[0].(*tidb.AlterTableStmt).Specs, version, columns) {
+						fmt.Println(s.Spec, s.Algorithm, s.Lock, s.Rebuild, s.Note)
+					}
+				}
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestAlterImpactEstimate(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	impacts := []*AlterImpact{
+		{Table: "t1", Algorithm: AlterAlgorithmInstant, Lock: AlterLockNone, Rows: 1000000, DataLength: 4 << 30, IndexSize: 1 << 30},
+		{Table: "t2", Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone, Scan: true, Rows: 1000, DataLength: 1 << 20, IndexSize: 0},
+		{Table: "t3", Algorithm: AlterAlgorithmInplace, Lock: AlterLockNone, Rebuild: true, Rows: 1000000, DataLength: 4 << 30, IndexSize: 1 << 30},
+		{Table: "t4", Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true, Rows: 1000000, DataLength: 4 << 30, IndexSize: 1 << 30},
+		{Table: "t5", Algorithm: AlterAlgorithmCopy, Lock: AlterLockShared, Rebuild: true, Rows: -1, DataLength: -1, IndexSize: -1},
+	}
+	err := common.GoldenDiff(func() {
+		for _, impact := range impacts {
+			impact.estimate()
+			fmt.Println(impact.Severity, impact.Seconds, impact.UseOSCTool)
+			fmt.Println(impact.String())
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// BenchmarkAdvise 在测试环境中分别执行原 SQL 及每一种重写后的 SQL n 次，对比耗时及 Handler 计数
+func BenchmarkAdvise(conn *database.Connector, sql string, variants []ast.RewriteVariant, n int) Rule {
+	if conn == nil || n <= 0 {
+		return Rule{}
+	}
+
+	names := []string{"original"}
+	queries := []string{sql}
+	for _, v := range variants {
+		names = append(names, v.Rule)
+		queries = append(queries, v.SQL)
+	}
+
+	var benchNames []string
+	var results []*database.BenchmarkResult
+	for i, query := range queries {
+		res, err := conn.Benchmark(query, n)
+		if err != nil {
+			common.Log.Debug("BenchmarkAdvise %s Error: %v", names[i], err)
+			// 原 SQL 无法执行时对比没有意义
+			if i == 0 {
+				return Rule{}
+			}
+			continue
+		}
+		benchNames = append(benchNames, names[i])
+		results = append(results, res)
+	}
+	return benchmarkRule(benchNames, results)
+}
+
+// benchmarkRule 将压测结果格式化为 PRO.007
+func benchmarkRule(names []string, results []*database.BenchmarkResult) Rule {
+	if len(results) == 0 {
+		return Rule{}
+	}
+
+	fastest := 0
+	var handlers []string
+	seen := make(map[string]bool)
+	for i, res := range results {
+		if res.P50 < results[fastest].P50 {
+			fastest = i
+		}
+		for name := range res.Handler {
+			if !seen[name] {
+				seen[name] = true
+				handlers = append(handlers, name)
+			}
+		}
+	}
+	sort.Strings(handlers)
+
+	buf := []string{
+		"| # | SQL | min | p50 | p95 | max |",
+		"|---|---|---|---|---|---|",
+	}
+	for i, res := range results {
+		buf = append(buf, fmt.Sprintf("| %d | %s | %s | %s | %s | %s |", i, names[i],
+			formatBenchmarkDuration(res.Min), formatBenchmarkDuration(res.P50),
+			formatBenchmarkDuration(res.P95), formatBenchmarkDuration(res.Max)))
+	}
+
+	if len(handlers) > 0 {
+		buf = append(buf, "", "| # | "+strings.Join(handlers, " | ")+" |",
+			"|---|"+strings.Repeat("---|", len(handlers)))
+		for i, res := range results {
+			var values []string
+			for _, name := range handlers {
+				values = append(values, formatTraceNumber(res.Handler[name]))
+			}
+			buf = append(buf, fmt.Sprintf("| %d | %s |", i, strings.Join(values, " | ")))
+		}
+	}
+
+	for i, res := range results {
+		buf = append(buf, "", fmt.Sprintf("%d. %s", i, names[i]), "", "```sql", res.Query, "```")
+	}
+
+	return Rule{
+		Item:     "PRO.007",
+		Severity: "L0",
+		Summary:  "原 SQL 与重写后的 SQL 执行耗时对比",
+		Content: fmt.Sprintf("预热后在测试环境中各执行 %d 次，Handler 计数为 SHOW SESSION STATUS 中平均每次执行的增量。中位数耗时最短的是 %s。",
+			results[0].Count, names[fastest]),
+		Case: strings.Join(buf, "\n"),
+	}
+}
+
+// formatBenchmarkDuration 耗时保留到微秒
+func formatBenchmarkDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
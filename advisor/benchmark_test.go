@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestBenchmarkRule(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	org := database.NewBenchmarkResult("select title, count(*) from film group by title having title = 'abc'",
+		[]time.Duration{1500 * time.Microsecond, 1200 * time.Microsecond, 3 * time.Millisecond})
+	org.Handler = map[string]float64{"Handler_read_key": 0, "Handler_read_rnd_next": 1001}
+	having := database.NewBenchmarkResult("select title, count(*) from film where title = 'abc' group by title",
+		[]time.Duration{210 * time.Microsecond, 180 * time.Microsecond, 250 * time.Microsecond})
+	having.Handler = map[string]float64{"Handler_read_key": 1, "Handler_read_next": 1}
+
+	err := common.GoldenDiff(func() {
+		r := benchmarkRule([]string{"original", "having"}, []*database.BenchmarkResult{org, having})
+		fmt.Println(r.Item, r.Severity, r.Summary)
+		fmt.Println(r.Content)
+		fmt.Println(r.Case)
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if r := benchmarkRule(nil, nil); r.Item != "" {
+		t.Errorf("no results got rule: %s", r.Item)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// ExplainDiff 两个环境中同一查询块同一张表执行计划的差异
+type ExplainDiff struct {
+	ID     int
+	Table  string
+	Item   string // table, join order, type, key, rows, Extra
+	Base   string
+	Target string
+}
+
+// explainDiffSevere 这些差异说明执行计划发生了变化，其他差异只是统计信息或优化器输出的不同
+var explainDiffSevere = map[string]bool{
+	"table":      true,
+	"join order": true,
+	"type":       true,
+	"key":        true,
+}
+
+// CompareExplainAdvise 在两个环境中分别执行 EXPLAIN 并对比执行计划，如：线上环境与测试环境，MySQL 5.7 与 8.0
+// 任一环境 EXPLAIN 失败时返回错误，由调用方输出，避免对比静默失效
+func CompareExplainAdvise(base, target *database.Connector, sql string) (Rule, error) {
+	if base == nil || target == nil || common.Config.OnlineDSN.Disable {
+		return Rule{}, nil
+	}
+
+	// 不同版本支持的 EXPLAIN 格式不同，统一使用传统格式对比
+	baseExp, err := base.Explain(sql, database.TraditionalExplainType, database.TraditionalFormatExplain)
+	if err != nil {
+		common.Log.Error("CompareExplainAdvise %s Explain Error: %v", base.Addr, err)
+		return Rule{}, err
+	}
+	targetExp, err := target.Explain(sql, database.TraditionalExplainType, database.TraditionalFormatExplain)
+	if err != nil {
+		common.Log.Error("CompareExplainAdvise %s Explain Error: %v", target.Addr, err)
+		return Rule{}, err
+	}
+
+	baseName := fmt.Sprintf("%s/%s", base.Addr, base.Database)
+	targetName := fmt.Sprintf("%s/%s", target.Addr, target.Database)
+	return compareExplainRule(baseName, targetName, CompareExplain(baseExp, targetExp)), nil
+}
+
+// compareExplainRule 将执行计划差异格式化为 EXP.007
+func compareExplainRule(baseName, targetName string, diffs []ExplainDiff) Rule {
+	if len(diffs) == 0 {
+		return Rule{}
+	}
+
+	severity := "L1"
+	buf := []string{
+		fmt.Sprintf("| id | table | item | %s | %s |", common.MarkdownEscape(baseName), common.MarkdownEscape(targetName)),
+		"|---|---|---|---|---|",
+	}
+	for _, diff := range diffs {
+		if explainDiffSevere[diff.Item] {
+			severity = "L3"
+		}
+		buf = append(buf, fmt.Sprintf("| %d | %s | %s | %s | %s |", diff.ID, common.MarkdownEscape(diff.Table),
+			diff.Item, common.MarkdownEscape(diff.Base), common.MarkdownEscape(diff.Target)))
+	}
+
+	return Rule{
+		Item:     "EXP.007",
+		Severity: severity,
+		Summary:  "不同环境中的执行计划不一致",
+		Content:  fmt.Sprintf("同一条 SQL 在 %s 与 %s 中的执行计划不同。统计信息、数据量及 MySQL 版本的差异都会导致执行计划变化，升级 MySQL 版本或在测试环境验证 SQL 前请确认以下变化符合预期。", baseName, targetName),
+		Case:     strings.Join(buf, "\n"),
+		Func:     (*Query4Audit).RuleOK,
+	}
+}
+
+// CompareExplain 按 id 及表名对齐两个执行计划，对比访问类型、索引、扫描行数及 Extra 信息
+func CompareExplain(base, target *database.ExplainInfo) []ExplainDiff {
+	baseRows, targetRows := explainCompareRows(base), explainCompareRows(target)
+	baseIdx, targetIdx := explainRowsIndex(baseRows), explainRowsIndex(targetRows)
+
+	var diffs []ExplainDiff
+	// 同一查询块中表的连接顺序
+	baseOrder, targetOrder := explainJoinOrder(baseRows), explainJoinOrder(targetRows)
+	var ids []int
+	for id := range baseOrder {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		// 只比较两边都存在的表的先后顺序
+		b, t := explainCommonTables(baseOrder[id], targetOrder[id]), explainCommonTables(targetOrder[id], baseOrder[id])
+		if strings.Join(b, ",") != strings.Join(t, ",") {
+			diffs = append(diffs, ExplainDiff{
+				ID:     id,
+				Item:   "join order",
+				Base:   strings.Join(baseOrder[id], ", "),
+				Target: strings.Join(targetOrder[id], ", "),
+			})
+		}
+	}
+
+	for _, key := range explainRowsKeys(baseRows, targetRows) {
+		b, bok := baseIdx[key]
+		t, tok := targetIdx[key]
+		switch {
+		case !tok:
+			diffs = append(diffs, ExplainDiff{ID: b.ID, Table: b.TableName, Item: "table", Base: "type: " + b.AccessType, Target: "-"})
+			continue
+		case !bok:
+			diffs = append(diffs, ExplainDiff{ID: t.ID, Table: t.TableName, Item: "table", Base: "-", Target: "type: " + t.AccessType})
+			continue
+		}
+
+		if b.AccessType != t.AccessType {
+			diffs = append(diffs, ExplainDiff{ID: b.ID, Table: b.TableName, Item: "type", Base: b.AccessType, Target: t.AccessType})
+		}
+		if explainCompareKey(b.Key) != explainCompareKey(t.Key) {
+			diffs = append(diffs, ExplainDiff{ID: b.ID, Table: b.TableName, Item: "key",
+				Base: explainCompareKey(b.Key), Target: explainCompareKey(t.Key)})
+		}
+		if explainRowsGap(b.Rows, t.Rows) {
+			diffs = append(diffs, ExplainDiff{ID: b.ID, Table: b.TableName, Item: "rows",
+				Base: fmt.Sprint(b.Rows), Target: fmt.Sprint(t.Rows)})
+		}
+		if explainCompareExtra(b.Extra) != explainCompareExtra(t.Extra) {
+			diffs = append(diffs, ExplainDiff{ID: b.ID, Table: b.TableName, Item: "Extra",
+				Base: explainCompareExtra(b.Extra), Target: explainCompareExtra(t.Extra)})
+		}
+	}
+	return diffs
+}
+
+// explainCompareRows 传统格式及 JSON 格式统一转换为 ExplainRow
+func explainCompareRows(exp *database.ExplainInfo) []database.ExplainRow {
+	if exp == nil {
+		return nil
+	}
+	if exp.ExplainFormat == database.JSONFormatExplain {
+		return database.ConvertExplainJSON2Row(exp.ExplainJSON)
+	}
+	return exp.ExplainRows
+}
+
+// explainRowKey 同一查询块中同一张表可能出现多次，如自连接，按出现的次序区分
+func explainRowKey(row database.ExplainRow, n int) string {
+	return fmt.Sprintf("%d/%s/%d", row.ID, row.TableName, n)
+}
+
+func explainRowsIndex(rows []database.ExplainRow) map[string]database.ExplainRow {
+	index := make(map[string]database.ExplainRow)
+	count := make(map[string]int)
+	for _, row := range rows {
+		k := fmt.Sprintf("%d/%s", row.ID, row.TableName)
+		index[explainRowKey(row, count[k])] = row
+		count[k]++
+	}
+	return index
+}
+
+// explainRowsKeys 先按 base 中的顺序，再按 target 中的顺序列出所有的表
+func explainRowsKeys(base, target []database.ExplainRow) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, rows := range [][]database.ExplainRow{base, target} {
+		count := make(map[string]int)
+		for _, row := range rows {
+			k := fmt.Sprintf("%d/%s", row.ID, row.TableName)
+			key := explainRowKey(row, count[k])
+			count[k]++
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// explainJoinOrder 每个查询块中表的连接顺序
+func explainJoinOrder(rows []database.ExplainRow) map[int][]string {
+	order := make(map[int][]string)
+	for _, row := range rows {
+		order[row.ID] = append(order[row.ID], row.TableName)
+	}
+	return order
+}
+
+// explainCommonTables tables 中同样出现在 others 中的表
+func explainCommonTables(tables, others []string) []string {
+	exists := make(map[string]bool)
+	for _, table := range others {
+		exists[table] = true
+	}
+	var found []string
+	for _, table := range tables {
+		if exists[table] {
+			found = append(found, table)
+		}
+	}
+	return found
+}
+
+func explainCompareKey(key string) string {
+	if key == "" || key == "NULL" {
+		return "NULL"
+	}
+	return key
+}
+
+// explainCompareExtra Extra 中的各项按字母排序后再对比
+func explainCompareExtra(extra string) string {
+	var items []string
+	for _, item := range strings.Split(extra, ";") {
+		item = strings.TrimSpace(item)
+		if item != "" && item != "NULL" {
+			items = append(items, item)
+		}
+	}
+	sort.Strings(items)
+	return strings.Join(items, "; ")
+}
+
+// explainRowsGap 预估行数相差超过 explain-max-rows-gap 倍
+func explainRowsGap(a, b int64) bool {
+	if common.Config.ExplainMaxRowsGap <= 1 {
+		return false
+	}
+	if a < 1 {
+		a = 1
+	}
+	if b < 1 {
+		b = 1
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return float64(a)/float64(b) > common.Config.ExplainMaxRowsGap
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"github.com/kr/pretty"
+)
+
+func TestCompareExplain(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	// MySQL 5.7
+	base := `+----+-------------+----------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+----------------------------------------------+
+| id | select_type | table    | partitions | type | possible_keys     | key               | key_len | ref                       | rows | filtered | Extra                                        |
++----+-------------+----------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+----------------------------------------------+
+|  1 | SIMPLE      | country  | NULL       | ALL  | PRIMARY           | NULL              | NULL    | NULL                      |  109 |   100.00 | Using where; Using temporary; Using filesort |
+|  1 | SIMPLE      | city     | NULL       | ref  | idx_fk_country_id | idx_fk_country_id | 2       | sakila.country.country_id |    5 |   100.00 | NULL                                         |
+|  1 | SIMPLE      | address  | NULL       | ref  | idx_fk_city_id    | idx_fk_city_id    | 2       | sakila.city.city_id       |    1 |   100.00 | Using index                                  |
++----+-------------+----------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+----------------------------------------------+`
+	// MySQL 8.0
+	target := `+----+-------------+---------+------------+--------+-------------------+---------+---------+------------------------+------+----------+------------------------------+
+| id | select_type | table   | partitions | type   | possible_keys     | key     | key_len | ref                    | rows | filtered | Extra                        |
++----+-------------+---------+------------+--------+-------------------+---------+---------+------------------------+------+----------+------------------------------+
+|  1 | SIMPLE      | city    | NULL       | ALL    | idx_fk_country_id | NULL    | NULL    | NULL                   | 6000 |   100.00 | Using temporary; Using where |
+|  1 | SIMPLE      | country | NULL       | eq_ref | PRIMARY           | PRIMARY | 2       | sakila.city.country_id |    1 |   100.00 | NULL                         |
++----+-------------+---------+------------+--------+-------------------+---------+---------+------------------------+------+----------+------------------------------+`
+	baseExp, err := database.ParseExplainText(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetExp, err := database.ParseExplainText(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = common.GoldenDiff(func() {
+		r := compareExplainRule("127.0.0.1:3306/sakila", "127.0.0.1:3308/sakila", CompareExplain(baseExp, targetExp))
+		fmt.Println(r.Item, r.Severity, r.Summary)
+		fmt.Println(r.Content)
+		fmt.Println(r.Case)
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if diffs := CompareExplain(baseExp, baseExp); len(diffs) != 0 {
+		t.Errorf("same explain got diffs: %s", pretty.Sprint(diffs))
+	}
+	if r := compareExplainRule("a", "b", nil); r.Item != "" {
+		t.Errorf("no diffs got rule: %s", pretty.Sprint(r))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestCompareExplainAdvise(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	conn := *rEnv
+	conn.Database = "sakila"
+	r, err := CompareExplainAdvise(&conn, &conn, "select * from film where length > 100")
+	if err != nil {
+		t.Error(err)
+	}
+	if r.Item != "" {
+		t.Errorf("same DSN got rule: %s", pretty.Sprint(r))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// explainGraphNode 执行计划图中的一个节点，可以是查询块、表或 FORMAT=TREE 中的迭代器
+type explainGraphNode struct {
+	id       string
+	label    []string
+	level    string // 告警级别：warn 或 danger，为空时表示没有告警
+	edge     string // 父节点指向该节点的边上的说明，如连接顺序
+	children []*explainGraphNode
+}
+
+// explainGraphColors 不同告警级别节点的填充色及边框色
+var explainGraphColors = map[string][2]string{
+	"":       {"#ffffff", "#333333"},
+	"warn":   {"#fff3cd", "#856404"},
+	"danger": {"#f8d7da", "#721c24"},
+}
+
+// <derived2>, <subquery2> 等引用其他查询块结果的临时表
+var explainGraphRefExp = regexp.MustCompile(`^<(?:derived|subquery)(\d+)>$`)
+
+// <union1,2> UNION 的结果
+var explainGraphUnionExp = regexp.MustCompile(`^<union([\d,]+)>$`)
+
+// ExplainGraph 将 EXPLAIN 信息转换为执行计划图，format 支持 dot 及 mermaid
+func ExplainGraph(exp *database.ExplainInfo, format string) string {
+	var root *explainGraphNode
+	if exp.ExplainTree != nil {
+		root = explainTreeGraph(exp.ExplainTree)
+	} else {
+		root = explainRowsGraph(exp)
+	}
+	if root == nil {
+		return ""
+	}
+
+	// 按深度优先的顺序为节点编号
+	var nodes []*explainGraphNode
+	var number func(n *explainGraphNode)
+	number = func(n *explainGraphNode) {
+		n.id = fmt.Sprintf("n%d", len(nodes))
+		nodes = append(nodes, n)
+		for _, child := range n.children {
+			number(child)
+		}
+	}
+	number(root)
+
+	var buf []string
+	switch format {
+	case "mermaid":
+		buf = append(buf, "graph TD")
+		for _, n := range nodes {
+			buf = append(buf, fmt.Sprintf("  %s[\"%s\"]", n.id, mermaidEscape(strings.Join(n.label, "\n"))))
+		}
+		for _, n := range nodes {
+			for _, child := range n.children {
+				if child.edge != "" {
+					buf = append(buf, fmt.Sprintf("  %s -->|%s| %s", n.id, mermaidEscape(child.edge), child.id))
+				} else {
+					buf = append(buf, fmt.Sprintf("  %s --> %s", n.id, child.id))
+				}
+			}
+		}
+		for _, level := range []string{"warn", "danger"} {
+			var ids []string
+			for _, n := range nodes {
+				if n.level == level {
+					ids = append(ids, n.id)
+				}
+			}
+			if len(ids) > 0 {
+				color := explainGraphColors[level]
+				buf = append(buf, fmt.Sprintf("  classDef %s fill:%s,stroke:%s", level, color[0], color[1]))
+				buf = append(buf, fmt.Sprintf("  class %s %s", strings.Join(ids, ","), level))
+			}
+		}
+	default:
+		buf = append(buf, "digraph explain {")
+		buf = append(buf, `  node [shape=box, style="rounded,filled", fontname="Helvetica"];`)
+		for _, n := range nodes {
+			color := explainGraphColors[n.level]
+			buf = append(buf, fmt.Sprintf(`  %s [label="%s", fillcolor="%s", color="%s"];`,
+				n.id, dotEscape(strings.Join(n.label, "\n")), color[0], color[1]))
+		}
+		for _, n := range nodes {
+			for _, child := range n.children {
+				if child.edge != "" {
+					buf = append(buf, fmt.Sprintf(`  %s -> %s [label="%s"];`, n.id, child.id, dotEscape(child.edge)))
+				} else {
+					buf = append(buf, fmt.Sprintf("  %s -> %s;", n.id, child.id))
+				}
+			}
+		}
+		buf = append(buf, "}")
+	}
+	return strings.Join(buf, "\n")
+}
+
+// explainRowsGraph 传统格式及 JSON 格式的 EXPLAIN 按查询块组织为树，查询块下的表按连接顺序排列
+func explainRowsGraph(exp *database.ExplainInfo) *explainGraphNode {
+	rows := exp.ExplainRows
+	if exp.ExplainFormat == database.JSONFormatExplain {
+		rows = database.ConvertExplainJSON2Row(exp.ExplainJSON)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// 节点颜色与 checkExplainAccessType, checkExplainRows 给出的告警保持一致
+	orgSuggests := tablesSuggests
+	tablesSuggests = make(map[string][]string)
+	checkExplainAccessType(exp)
+	checkExplainRows(exp)
+	warnings := tablesSuggests
+	tablesSuggests = orgSuggests
+
+	// 查询块按 id 区分，UNION RESULT 的 id 为 NULL，按 <unionM,N> 区分
+	var keys []string
+	blocks := make(map[string]*explainGraphNode)
+	for _, row := range rows {
+		key := strconv.Itoa(row.ID)
+		if row.SelectType == "UNION RESULT" || explainGraphUnionExp.MatchString(row.TableName) {
+			key = row.TableName
+		}
+		block, ok := blocks[key]
+		if !ok {
+			block = &explainGraphNode{label: []string{fmt.Sprintf("select #%d", row.ID)}}
+			if key == row.TableName {
+				block.label = []string{"UNION RESULT"}
+			} else if row.SelectType != "" {
+				block.label = append(block.label, row.SelectType)
+			}
+			blocks[key] = block
+			keys = append(keys, key)
+		}
+
+		table := &explainGraphNode{
+			label: []string{row.TableName, "type: " + row.AccessType},
+			edge:  strconv.Itoa(len(block.children) + 1),
+		}
+		if row.Key != "" && row.Key != "NULL" {
+			table.label = append(table.label, "key: "+row.Key)
+		}
+		table.label = append(table.label, fmt.Sprintf("rows: %d", row.Rows))
+		for _, warning := range warnings[row.TableName] {
+			switch {
+			case strings.HasPrefix(warning, "Scalability:"):
+				table.level = "danger"
+			case strings.HasPrefix(warning, "Rows:") && table.level == "":
+				table.level = "warn"
+			}
+		}
+		block.children = append(block.children, table)
+	}
+
+	// UNION 的第一个查询块被派生表或子查询引用时，引用的是整个 UNION 的结果
+	unions := make(map[string]string)
+	for _, key := range keys {
+		if m := explainGraphUnionExp.FindStringSubmatch(key); m != nil {
+			unions[strings.Split(m[1], ",")[0]] = key
+		}
+	}
+
+	// 派生表、物化子查询及 UNION 的各个查询块挂在引用它们的表下面
+	attached := make(map[string]bool)
+	for _, key := range keys {
+		for _, table := range blocks[key].children {
+			var refs []string
+			if m := explainGraphUnionExp.FindStringSubmatch(table.label[0]); m != nil {
+				refs = strings.Split(m[1], ",")
+			} else if m := explainGraphRefExp.FindStringSubmatch(table.label[0]); m != nil {
+				refs = []string{m[1]}
+				if union, ok := unions[m[1]]; ok {
+					refs = []string{union}
+				}
+			}
+			for _, ref := range refs {
+				if ref == key || attached[ref] || blocks[ref] == nil {
+					continue
+				}
+				attached[ref] = true
+				table.children = append(table.children, blocks[ref])
+			}
+		}
+	}
+
+	var top []*explainGraphNode
+	for _, key := range keys {
+		if !attached[key] {
+			top = append(top, blocks[key])
+		}
+	}
+	if len(top) == 1 {
+		return top[0]
+	}
+	return &explainGraphNode{label: []string{"query"}, children: top}
+}
+
+// explainTreeGraph FORMAT=TREE 及 EXPLAIN ANALYZE 按迭代器树输出
+func explainTreeGraph(tree *database.ExplainNode) *explainGraphNode {
+	var convert func(node *database.ExplainNode) *explainGraphNode
+	convert = func(node *database.ExplainNode) *explainGraphNode {
+		n := &explainGraphNode{label: []string{node.Operation}}
+		if node.Estimated {
+			n.label = append(n.label, fmt.Sprintf("cost: %s rows: %s",
+				strconv.FormatFloat(node.Cost, 'f', -1, 64), strconv.FormatFloat(node.Rows, 'f', -1, 64)))
+		}
+		switch {
+		case node.Analyzed && node.Executed:
+			n.label = append(n.label, fmt.Sprintf("actual rows: %s loops: %d",
+				strconv.FormatFloat(node.ActualRows, 'f', -1, 64), node.Loops))
+		case node.Analyzed:
+			n.label = append(n.label, "never executed")
+		}
+		if common.Config.ExplainMaxRows > 0 && node.TotalRows() >= float64(common.Config.ExplainMaxRows) {
+			n.level = "warn"
+		}
+		if strings.HasPrefix(node.Operation, "Table scan on") {
+			n.level = "danger"
+		}
+		for _, child := range node.Children {
+			n.children = append(n.children, convert(child))
+		}
+		return n
+	}
+	return convert(tree)
+}
+
+// dotEscape 转义 DOT 双引号字符串中的特殊字符
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// mermaidEscape 转义 mermaid 节点文本中的特殊字符
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "|", "#124;", "\n", "<br/>").Replace(s)
+}
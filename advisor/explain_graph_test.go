@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestExplainGraph(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	texts := []string{
+		// 派生表中包含 UNION
+		`+------+--------------+------------+------------+--------+---------------+---------+---------+----------------+------+----------+-----------------+
+| id   | select_type  | table      | partitions | type   | possible_keys | key     | key_len | ref            | rows | filtered | Extra           |
++------+--------------+------------+------------+--------+---------------+---------+---------+----------------+------+----------+-----------------+
+|    1 | PRIMARY      | <derived2> | NULL       | ALL    | NULL          | NULL    | NULL    | NULL           | 2000 |   100.00 | NULL            |
+|    1 | PRIMARY      | language   | NULL       | eq_ref | PRIMARY       | PRIMARY | 1       | t.language_id  |    1 |   100.00 | NULL            |
+|    2 | DERIVED      | film       | NULL       | ALL    | NULL          | NULL    | NULL    | NULL           | 1000 |   100.00 | NULL            |
+|    3 | UNION        | film_text  | NULL       | range  | PRIMARY       | PRIMARY | 2       | NULL           |  500 |   100.00 | Using where     |
+| NULL | UNION RESULT | <union2,3> | NULL       | ALL    | NULL          | NULL    | NULL    | NULL           | NULL |     NULL | Using temporary |
++------+--------------+------------+------------+--------+---------------+---------+---------+----------------+------+----------+-----------------+`,
+		// JSON 格式，被驱动表扫描行数超过 explain-max-rows
+		`{
+  "query_block": {
+    "select_id": 1,
+    "nested_loop": [
+      {
+        "table": {
+          "table_name": "customer",
+          "access_type": "index",
+          "key": "idx_fk_store_id",
+          "rows_examined_per_scan": 599,
+          "filtered": "100.00"
+        }
+      },
+      {
+        "table": {
+          "table_name": "rental",
+          "access_type": "ref",
+          "key": "idx_fk_customer_id",
+          "ref": ["sakila.customer.customer_id"],
+          "rows_examined_per_scan": 16008,
+          "filtered": "100.00"
+        }
+      }
+    ]
+  }
+}`,
+		// EXPLAIN ANALYZE
+		`-> Nested loop inner join  (cost=1230.50 rows=120) (actual time=0.105..95.310 rows=16044 loops=1)
+    -> Table scan on rental  (cost=1625.45 rows=16008) (actual time=0.052..8.145 rows=16044 loops=1)
+    -> Single-row index lookup on customer using PRIMARY (customer_id=rental.customer_id)  (cost=0.25 rows=1) (actual time=0.003..0.003 rows=1 loops=16044)`,
+	}
+	err := common.GoldenDiff(func() {
+		for _, text := range texts {
+			exp, err := database.ParseExplainText(text)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			for _, format := range []string{"dot", "mermaid"} {
+				fmt.Println(ExplainGraph(exp, format))
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -18,6 +18,9 @@ package advisor
 
 import (
 	"fmt"
+	"html"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/XiaoMi/soar/common"
@@ -168,6 +171,231 @@ func checkExplainFiltered(exp *database.ExplainInfo) {
 	}
 }
 
+// explainTreeMinRows 行数较少时预估偏差对执行计划影响不大，不检查
+const explainTreeMinRows = 100
+
+// explainTreeExpensive 代价较高的迭代器，按 Operation 前缀匹配
+var explainTreeExpensive = []struct {
+	Prefix string
+	Desc   string
+}{
+	{"Table scan on", "全表扫描"},
+	{"Index scan on", "全索引扫描"},
+	{"Sort", "排序"},
+	{"Temporary table", "使用临时表"},
+	{"Aggregate using temporary table", "使用临时表聚合"},
+	{"Materialize", "物化为临时表"},
+}
+
+// checkExplainTreeRows EXP.001 EXPLAIN ANALYZE 实际返回行数与预估行数偏差过大
+func checkExplainTreeRows(exp *database.ExplainInfo) {
+	if exp.ExplainTree == nil || common.Config.ExplainMaxRowsGap <= 1 {
+		return
+	}
+	var contents []string
+	exp.ExplainTree.Walk(func(node *database.ExplainNode, depth int) {
+		if !node.Estimated || !node.Analyzed || !node.Executed {
+			return
+		}
+		max, min := node.Rows, node.ActualRows
+		if min > max {
+			max, min = min, max
+		}
+		if max < explainTreeMinRows || (max+1)/(min+1) < common.Config.ExplainMaxRowsGap {
+			return
+		}
+		contents = append(contents, fmt.Sprintf("* `%s`: 预估 %.0f 行，实际 %.0f 行，循环 %d 次",
+			node.Operation, node.Rows, node.ActualRows, node.Loops))
+	})
+	if len(contents) > 0 {
+		explainRules["EXP.001"] = Rule{
+			Item:     "EXP.001",
+			Severity: "L2",
+			Summary:  "实际返回行数与预估行数偏差过大",
+			Content:  "统计信息不准确会导致优化器选择错误的连接顺序及访问方式，建议执行 ANALYZE TABLE 更新统计信息，MySQL 8.0 中可以为非索引列创建直方图。\n\n" + strings.Join(contents, "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+}
+
+// checkExplainTreeIterator EXP.002 处理行数超过 ExplainMaxRows 的高代价迭代器
+func checkExplainTreeIterator(exp *database.ExplainInfo) {
+	if exp.ExplainTree == nil || common.Config.ExplainMaxRows <= 0 {
+		return
+	}
+	var contents []string
+	exp.ExplainTree.Walk(func(node *database.ExplainNode, depth int) {
+		if node.TotalRows() < float64(common.Config.ExplainMaxRows) {
+			return
+		}
+		for _, e := range explainTreeExpensive {
+			if !strings.HasPrefix(node.Operation, e.Prefix) {
+				continue
+			}
+			content := fmt.Sprintf("* `%s`: %s，处理约 %.0f 行", node.Operation, e.Desc, node.TotalRows())
+			if node.Analyzed {
+				content += fmt.Sprintf("，耗时约 %.3f ms", node.LastTime*float64(node.Loops))
+			}
+			contents = append(contents, content)
+			break
+		}
+	})
+	if len(contents) > 0 {
+		explainRules["EXP.002"] = Rule{
+			Item:     "EXP.002",
+			Severity: "L3",
+			Summary:  "执行计划中存在高代价的迭代器",
+			Content:  "全表扫描、排序及临时表等迭代器处理大量数据时会消耗较多的 CPU 及 IO 资源，建议通过添加索引或改写 SQL 减少处理的行数。\n\n" + strings.Join(contents, "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+}
+
+const (
+	explainJSONReadEvalRatio = 10  // read_cost 超过 eval_cost 的倍数
+	explainJSONMinReadCost   = 100 // read_cost 较小时不检查
+)
+
+var (
+	// 在列上使用函数或类型转换，如：date(`sakila`.`film`.`last_update`)
+	explainJSONFunctionExp = regexp.MustCompile("(?i)\\b\\w+\\(`[^`]+`\\.`[^`]+`\\.`[^`]+`")
+	// 前项通配符
+	explainJSONPrefixLikeExp = regexp.MustCompile(`(?i)\blike\s+'%`)
+)
+
+// explainJSONBlocks 遍历 JSON 格式 EXPLAIN 中所有的查询块，非 JSON 格式返回 nil
+func explainJSONBlocks(exp *database.ExplainInfo) []*database.ExplainJSONQueryBlock {
+	if exp.ExplainFormat != database.JSONFormatExplain || exp.ExplainJSON == nil {
+		return nil
+	}
+	var blocks []*database.ExplainJSONQueryBlock
+	exp.ExplainJSON.Walk(func(qb *database.ExplainJSONQueryBlock) {
+		blocks = append(blocks, qb)
+	})
+	return blocks
+}
+
+// checkExplainJSONCost EXP.003 读取数据的代价远大于条件计算的代价
+func checkExplainJSONCost(exp *database.ExplainInfo) {
+	var contents []string
+	for _, qb := range explainJSONBlocks(exp) {
+		for _, table := range qb.JoinTables() {
+			readCost, err := strconv.ParseFloat(table.CostInfo.ReadCost, 64)
+			if err != nil || readCost < explainJSONMinReadCost {
+				continue
+			}
+			evalCost, _ := strconv.ParseFloat(table.CostInfo.EvalCost, 64)
+			if readCost < evalCost*explainJSONReadEvalRatio {
+				continue
+			}
+			contents = append(contents, fmt.Sprintf("* select #%d `%s`: read_cost %s, eval_cost %s, access_type %s",
+				qb.SelectID, table.TableName, table.CostInfo.ReadCost, table.CostInfo.EvalCost, table.AccessType))
+		}
+	}
+	if len(contents) > 0 {
+		explainRules["EXP.003"] = Rule{
+			Item:     "EXP.003",
+			Severity: "L2",
+			Summary:  "读取数据的代价远大于条件计算的代价",
+			Content:  "read_cost 为读取数据的 IO 代价，eval_cost 为计算条件的 CPU 代价。read_cost 远大于 eval_cost 说明读取了大量最终被过滤掉的数据或回表代价较高，建议使用选择性更好的索引或覆盖索引。\n\n" + strings.Join(contents, "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+}
+
+// checkExplainJSONCondition EXP.004 全表扫描或全索引扫描时附加的过滤条件无法使用索引
+func checkExplainJSONCondition(exp *database.ExplainInfo) {
+	var contents []string
+	for _, qb := range explainJSONBlocks(exp) {
+		for _, table := range qb.JoinTables() {
+			// <derived2>, <subquery2> 等临时表无法添加索引
+			if table.AttachedCondition == "" || strings.HasPrefix(table.TableName, "<") ||
+				(table.AccessType != "ALL" && table.AccessType != "index") {
+				continue
+			}
+			var reason string
+			switch {
+			case explainJSONFunctionExp.MatchString(table.AttachedCondition):
+				reason = "列上使用了函数或类型转换"
+			case explainJSONPrefixLikeExp.MatchString(table.AttachedCondition):
+				reason = "使用了前项通配符"
+			default:
+				reason = "条件中的列没有可用的索引"
+			}
+			contents = append(contents, fmt.Sprintf("* select #%d `%s`: %s, attached_condition: `%s`",
+				qb.SelectID, table.TableName, reason, table.AttachedCondition))
+		}
+	}
+	if len(contents) > 0 {
+		explainRules["EXP.004"] = Rule{
+			Item:     "EXP.004",
+			Severity: "L3",
+			Summary:  "过滤条件无法使用索引",
+			Content:  "以下表在读取数据后才使用 attached_condition 逐行过滤，条件无法通过索引定位数据。建议避免在列上使用函数、隐式类型转换及前项通配符，并为过滤条件中的列添加索引。\n\n" + strings.Join(contents, "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+}
+
+// checkExplainJSONTemporary EXP.005 查询块中使用了临时表或文件排序
+func checkExplainJSONTemporary(exp *database.ExplainInfo) {
+	var contents []string
+	for _, qb := range explainJSONBlocks(exp) {
+		var ops []string
+		check := func(op string, temporary, filesort bool) {
+			if temporary {
+				ops = append(ops, op+" 使用临时表")
+			}
+			if filesort {
+				ops = append(ops, op+" 使用文件排序")
+			}
+		}
+		ordering := qb.OrderingOperation
+		check("ORDER BY", ordering.UsingTemporaryTable, ordering.UsingFilesort)
+		check("GROUP BY", qb.GroupingOperation.UsingTemporaryTable || ordering.GroupingOperation.UsingTemporaryTable,
+			qb.GroupingOperation.UsingFilesort || ordering.GroupingOperation.UsingFilesort)
+		check("DISTINCT", qb.DuplicatesRemoval.UsingTemporaryTable || ordering.DuplicatesRemoval.UsingTemporaryTable,
+			qb.DuplicatesRemoval.UsingFilesort || ordering.DuplicatesRemoval.UsingFilesort)
+		check("UNION", qb.UnionResult.UsingTemporaryTable, false)
+		if len(ops) > 0 {
+			contents = append(contents, fmt.Sprintf("* select #%d: %s", qb.SelectID, strings.Join(ops, ", ")))
+		}
+	}
+	if len(contents) > 0 {
+		explainRules["EXP.005"] = Rule{
+			Item:     "EXP.005",
+			Severity: "L2",
+			Summary:  "查询中使用了临时表或文件排序",
+			Content:  "无法利用索引顺序完成的 ORDER BY, GROUP BY, DISTINCT 及 UNION 需要使用临时表或文件排序，数据量较大时会使用磁盘临时文件。建议为排序和分组的列添加索引，UNION 可以考虑改写为 UNION ALL。\n\n" + strings.Join(contents, "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+}
+
+// checkExplainJSONMaterialized EXP.006 作为被驱动表的物化子查询或派生表没有可用的索引
+func checkExplainJSONMaterialized(exp *database.ExplainInfo) {
+	var contents []string
+	for _, qb := range explainJSONBlocks(exp) {
+		for i, table := range qb.JoinTables() {
+			// 驱动表只需要扫描一次临时表
+			if i == 0 || table.Key != "" || table.MaterializedFromSubquery.QueryBlock == nil {
+				continue
+			}
+			contents = append(contents, fmt.Sprintf("* select #%d `%s`: access_type %s, rows_examined_per_scan %d",
+				qb.SelectID, table.TableName, table.AccessType, table.RowsExaminedPerScan))
+		}
+	}
+	if len(contents) > 0 {
+		explainRules["EXP.006"] = Rule{
+			Item:     "EXP.006",
+			Severity: "L3",
+			Summary:  "物化的子查询或派生表没有可用的索引",
+			Content:  "子查询或派生表的结果物化为临时表后作为被驱动表，但连接时没有可用的索引，驱动表的每一行都需要扫描整个临时表。建议检查连接条件是否可以被优化器用于生成 auto_key，或将子查询改写为 JOIN。\n\n" + strings.Join(contents, "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+}
+
 // ExplainAdvisor 基于explain信息给出建议
 func ExplainAdvisor(exp *database.ExplainInfo) map[string]Rule {
 	common.Log.Debug("ExplainAdvisor SQL: %v", exp.SQL)
@@ -180,10 +408,23 @@ func ExplainAdvisor(exp *database.ExplainInfo) map[string]Rule {
 	checkExplainFiltered(exp)
 	checkExplainRef(exp)
 	checkExplainRows(exp)
+	checkExplainTreeRows(exp)
+	checkExplainTreeIterator(exp)
+	checkExplainJSONCost(exp)
+	checkExplainJSONCondition(exp)
+	checkExplainJSONTemporary(exp)
+	checkExplainJSONMaterialized(exp)
 
 	// 打印explain table
 	content := database.PrintMarkdownExplainTable(exp)
 
+	// HTML 报告中在 explain table 后嵌入执行计划图
+	if common.Config.ReportType == "html" && content != "" {
+		if graph := ExplainGraph(exp, "mermaid"); graph != "" {
+			content += "\n<div class=\"mermaid\">\n" + html.EscapeString(graph) + "\n</div>\n"
+		}
+	}
+
 	if common.Config.ShowWarnings {
 		content += "\n" + database.MySQLExplainWarnings(exp)
 	}
@@ -221,6 +462,14 @@ func DigestExplainText(text string) {
 			common.Log.Error("main ParseExplainText Error: %v", err)
 			return
 		}
+		switch common.Config.ReportType {
+		case "explain-dot":
+			fmt.Println(ExplainGraph(explainInfo, "dot"))
+			return
+		case "explain-mermaid":
+			fmt.Println(ExplainGraph(explainInfo, "mermaid"))
+			return
+		}
 		expSuggest := ExplainAdvisor(explainInfo)
 		_, output := FormatSuggest("", "", common.Config.ReportType, expSuggest)
 		if common.Config.ReportType == "html" {
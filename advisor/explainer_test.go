@@ -43,3 +43,116 @@ func TestDigestExplainText(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestDigestExplainTree(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	var text = `-> Nested loop inner join  (cost=1230.50 rows=120) (actual time=0.105..95.310 rows=16044 loops=1)
+    -> Table scan on rental  (cost=1625.45 rows=16008) (actual time=0.052..8.145 rows=16044 loops=1)
+    -> Single-row index lookup on customer using PRIMARY (customer_id=rental.customer_id)  (cost=0.25 rows=1) (actual time=0.003..0.003 rows=1 loops=16044)`
+	orgReportType := common.Config.ReportType
+	common.Config.ReportType = "explain-digest"
+	err := common.GoldenDiff(func() {
+		DigestExplainText(text)
+	}, t.Name(), update)
+	if nil != err {
+		t.Fatal(err)
+	}
+	common.Config.ReportType = orgReportType
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestExplainJSONRules(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	var text = `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {
+      "query_cost": "2341.20"
+    },
+    "ordering_operation": {
+      "using_temporary_table": true,
+      "using_filesort": true,
+      "nested_loop": [
+        {
+          "table": {
+            "table_name": "film",
+            "access_type": "ALL",
+            "rows_examined_per_scan": 1000,
+            "rows_produced_per_join": 100,
+            "filtered": "10.00",
+            "cost_info": {
+              "read_cost": "1180.00",
+              "eval_cost": "20.00",
+              "prefix_cost": "1200.00",
+              "data_read_per_join": "78K"
+            },
+            "used_columns": [
+              "film_id",
+              "title",
+              "last_update"
+            ],
+            "attached_condition": "(cast(` + "`sakila`.`film`.`last_update`" + ` as date) = '2006-02-15')"
+          }
+        },
+        {
+          "table": {
+            "table_name": "fa",
+            "access_type": "ALL",
+            "rows_examined_per_scan": 5462,
+            "rows_produced_per_join": 546,
+            "filtered": "10.00",
+            "using_join_buffer": "Block Nested Loop",
+            "cost_info": {
+              "read_cost": "32.70",
+              "eval_cost": "109.24",
+              "prefix_cost": "1341.20",
+              "data_read_per_join": "8K"
+            },
+            "used_columns": [
+              "actor_id",
+              "film_id"
+            ],
+            "attached_condition": "(` + "`fa`.`film_id` = `sakila`.`film`.`film_id`" + `)",
+            "materialized_from_subquery": {
+              "using_temporary_table": true,
+              "dependent": false,
+              "cacheable": true,
+              "query_block": {
+                "select_id": 2,
+                "cost_info": {
+                  "query_cost": "1104.40"
+                },
+                "table": {
+                  "table_name": "film_actor",
+                  "access_type": "index",
+                  "key": "idx_fk_film_id",
+                  "rows_examined_per_scan": 5462,
+                  "rows_produced_per_join": 5462,
+                  "filtered": "100.00",
+                  "using_index": true,
+                  "cost_info": {
+                    "read_cost": "12.00",
+                    "eval_cost": "1092.40",
+                    "prefix_cost": "1104.40",
+                    "data_read_per_join": "85K"
+                  }
+                }
+              }
+            }
+          }
+        }
+      ]
+    }
+  }
+}`
+	orgReportType := common.Config.ReportType
+	common.Config.ReportType = "explain-digest"
+	err := common.GoldenDiff(func() {
+		DigestExplainText(text)
+	}, t.Name(), update)
+	if nil != err {
+		t.Fatal(err)
+	}
+	common.Config.ReportType = orgReportType
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,503 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// FlashbackResult 一条 UPDATE 或 DELETE 语句的闪回结果
+type FlashbackResult struct {
+	SQL        string   // 原始 SQL
+	Select     string   // 用于备份的 SELECT 语句
+	Table      string   // `db`.`table`
+	Rows       int      // 备份的行数
+	Statements []string // 闪回语句
+	Warnings   []string
+}
+
+// flashbackTable 闪回语句需要的表信息
+type flashbackTable struct {
+	Name    string   // `db`.`table`
+	Keys    []string // 主键或唯一键列，为空时使用未被修改的列作为条件
+	Updated []string // UPDATE 语句中被修改的列，DELETE 时为空
+	Delete  bool
+	// 生成列，INSERT 时不能指定值
+	Generated []string
+	// ON UPDATE CURRENT_TIMESTAMP 列，UPDATE 后值会改变，不能作为没有主键时的定位条件
+	OnUpdate []string
+}
+
+// flashbackTouched 同一批语句中前面的语句在一张表中修改过的行及列
+// 所有前镜像都在执行变更前查询，后面的语句修改这些行或者按这些列过滤时，查询到的前镜像不准确
+type flashbackTouched struct {
+	All     bool            // 无法确定修改了哪些行，如：没有主键，没有查询前镜像
+	Keys    map[string]bool // 被修改或删除的行的主键值
+	Columns map[string]bool // 被修改的列
+}
+
+// binaryTypes 需要以十六进制输出的数据类型
+var binaryTypes = map[string]bool{
+	"BINARY": true, "VARBINARY": true, "BIT": true, "GEOMETRY": true,
+	"BLOB": true, "TINYBLOB": true, "MEDIUMBLOB": true, "LONGBLOB": true,
+}
+
+// generatedColumnExp SHOW COLUMNS 中生成列的 Extra，MySQL 8.0 表达式默认值的 DEFAULT_GENERATED 不是生成列
+var generatedColumnExp = regexp.MustCompile(`(?i)\b(VIRTUAL|STORED|PERSISTENT) GENERATED\b`)
+
+// Flashback 为输入的单表 UPDATE, DELETE 语句生成闪回语句
+// 使用 RewriteDML2Select 将 DML 改写为 SELECT，从线上环境中只读查询受影响的行（前镜像），按主键生成回滚语句
+// 回滚语句按与输入相反的顺序输出，受影响的行数超过 FlashbackMaxRows 时不生成闪回语句
+// 前面的语句修改过相同的行或 WHERE 条件中的列时，前镜像不准确，不生成闪回语句
+func Flashback(conn *database.Connector, buf string) []FlashbackResult {
+	common.Log.Debug("Enter:  Flashback, Caller: %s", common.Caller())
+	var results []FlashbackResult
+	touched := make(map[string]*flashbackTouched)
+	for buf = strings.TrimSpace(buf); buf != ""; {
+		_, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(common.Config.Delimiter))
+		if len(bufBytes) == len(buf) {
+			buf = ""
+		} else {
+			buf = string(bufBytes)
+		}
+		sql = strings.TrimSpace(database.RemoveSQLComments(sql))
+		if sql == "" {
+			continue
+		}
+		results = append([]FlashbackResult{flashbackDML(conn, sql, touched)}, results...)
+	}
+	return results
+}
+
+// flashbackDML 生成单条 DML 语句的闪回语句，touched 为同一批语句中前面的语句修改过的数据
+func flashbackDML(conn *database.Connector, sql string, touched map[string]*flashbackTouched) FlashbackResult {
+	result := FlashbackResult{SQL: sql}
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		result.Warnings = append(result.Warnings, "语法解析失败: "+err.Error())
+		return result
+	}
+
+	var tableExprs sqlparser.TableExprs
+	var limit *sqlparser.Limit
+	var where *sqlparser.Where
+	tb := flashbackTable{}
+	switch node := stmt.(type) {
+	case *sqlparser.Delete:
+		tableExprs, limit, where, tb.Delete = node.TableExprs, node.Limit, node.Where, true
+	case *sqlparser.Update:
+		tableExprs, limit, where = node.TableExprs, node.Limit, node.Where
+		for _, expr := range node.Exprs {
+			tb.Updated = append(tb.Updated, expr.Name.Name.String())
+		}
+	default:
+		result.Warnings = append(result.Warnings, "只支持为 UPDATE, DELETE 语句生成闪回语句")
+		return result
+	}
+
+	// 多表 UPDATE, DELETE 的 SELECT * 结果中包含多张表的列，无法还原到单张表
+	var table sqlparser.TableName
+	if aliased, ok := tableExprs[0].(*sqlparser.AliasedTableExpr); ok && len(tableExprs) == 1 {
+		table, ok = aliased.Expr.(sqlparser.TableName)
+		if !ok {
+			result.Warnings = append(result.Warnings, "不支持多表 UPDATE, DELETE 语句")
+			return result
+		}
+	} else {
+		result.Warnings = append(result.Warnings, "不支持多表 UPDATE, DELETE 语句")
+		return result
+	}
+
+	if conn == nil || common.Config.OnlineDSN.Disable || common.Config.TestDSN.Disable {
+		result.Warnings = append(result.Warnings, "未配置线上环境，无法备份受影响的数据")
+		return result
+	}
+	tmpConn := *conn
+	if !table.Qualifier.IsEmpty() {
+		tmpConn.Database = table.Qualifier.String()
+	}
+	tb.Name = fmt.Sprintf("`%s`.`%s`", tmpConn.Database, table.Name.String())
+	result.Table = tb.Name
+
+	// 没有查询到前镜像时无法确定修改了哪些行
+	prev, cur := touched[tb.Name], &flashbackTouched{All: true}
+	defer func() { touched[tb.Name] = prev.merge(cur) }()
+
+	// 主键或唯一键
+	tb.Keys = flashbackKeys(&tmpConn, table.Name.String())
+	tb.Generated, tb.OnUpdate = flashbackColumns(&tmpConn, table.Name.String())
+	if len(tb.Keys) == 0 {
+		result.Warnings = append(result.Warnings, "表中没有主键或非空唯一键，使用未被修改的列作为回滚语句的条件")
+		if !tb.Delete && len(tb.OnUpdate) > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("列 `%s` 的值会在 UPDATE 时自动更新，回滚语句的条件中不包含这些列，可能匹配到其他行",
+				strings.Join(tb.OnUpdate, "`, `")))
+		}
+	}
+	for _, col := range tb.Updated {
+		for _, key := range tb.Keys {
+			if strings.EqualFold(col, key) {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("UPDATE 修改了主键或唯一键列 `%s`，无法根据修改前的值定位行，未生成闪回语句", col))
+				return result
+			}
+		}
+	}
+
+	// 改写为 SELECT，多查询一行用于判断是否超过最大行数
+	rw := ast.NewRewrite(sql).RewriteDML2Select()
+	sel, ok := rw.Stmt.(*sqlparser.Select)
+	if !ok {
+		result.Warnings = append(result.Warnings, "无法将语句改写为 SELECT")
+		return result
+	}
+	sel.Limit = flashbackLimit(limit, common.Config.FlashbackMaxRows+1)
+	result.Select = sqlparser.String(sel)
+
+	columns, rows, err := flashbackQuery(&tmpConn, result.Select)
+	if err != nil {
+		result.Warnings = append(result.Warnings, "查询受影响的数据失败: "+err.Error())
+		return result
+	}
+	if len(rows) > common.Config.FlashbackMaxRows {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("受影响的行数超过 %d 行，未生成闪回语句，请使用其他方式备份数据",
+			common.Config.FlashbackMaxRows))
+		return result
+	}
+	result.Rows = len(rows)
+	cur = newFlashbackTouched(tb, columns, rows)
+	if prev.overlap(tb, flashbackWhereColumns(where), columns, rows) {
+		result.Warnings = append(result.Warnings, "同一批语句中前面的语句修改过相同的行或 WHERE 条件中的列，执行变更前查询的前镜像不准确，未生成闪回语句，请单独执行该语句并备份数据")
+		return result
+	}
+	result.Statements = flashbackStatements(tb, columns, rows)
+	return result
+}
+
+// newFlashbackTouched 根据前镜像记录语句修改的行及列
+func newFlashbackTouched(tb flashbackTable, columns []string, rows [][]string) *flashbackTouched {
+	t := &flashbackTouched{
+		All:     len(tb.Keys) == 0,
+		Keys:    make(map[string]bool),
+		Columns: make(map[string]bool),
+	}
+	for _, key := range flashbackKeyValues(tb.Keys, columns, rows) {
+		t.Keys[key] = true
+	}
+	for _, col := range append(tb.Updated, tb.OnUpdate...) {
+		t.Columns[strings.ToLower(col)] = true
+	}
+	return t
+}
+
+// merge 合并同一张表上前后两条语句修改的数据
+func (t *flashbackTouched) merge(o *flashbackTouched) *flashbackTouched {
+	if t == nil {
+		return o
+	}
+	merged := &flashbackTouched{All: t.All || o.All, Keys: make(map[string]bool), Columns: make(map[string]bool)}
+	for _, m := range []*flashbackTouched{t, o} {
+		for k := range m.Keys {
+			merged.Keys[k] = true
+		}
+		for c := range m.Columns {
+			merged.Columns[c] = true
+		}
+	}
+	return merged
+}
+
+// overlap 判断语句是否会修改前面的语句修改过的行，或者按前面的语句修改过的列过滤
+func (t *flashbackTouched) overlap(tb flashbackTable, whereColumns, columns []string, rows [][]string) bool {
+	if t == nil {
+		return false
+	}
+	if t.All || len(tb.Keys) == 0 {
+		return true
+	}
+	for _, col := range whereColumns {
+		if t.Columns[strings.ToLower(col)] {
+			return true
+		}
+	}
+	for _, key := range flashbackKeyValues(tb.Keys, columns, rows) {
+		if t.Keys[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// flashbackKeyValues 每一行主键列的值
+func flashbackKeyValues(keys, columns []string, rows [][]string) []string {
+	var values []string
+	for _, row := range rows {
+		var value []string
+		for _, key := range keys {
+			for i, col := range columns {
+				if strings.EqualFold(col, key) {
+					value = append(value, row[i])
+				}
+			}
+		}
+		values = append(values, strings.Join(value, ","))
+	}
+	return values
+}
+
+// flashbackWhereColumns WHERE 条件中使用的列
+func flashbackWhereColumns(where *sqlparser.Where) []string {
+	var columns []string
+	if where == nil {
+		return columns
+	}
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if col, ok := node.(*sqlparser.ColName); ok {
+			columns = append(columns, col.Name.String())
+		}
+		return true, nil
+	}, where)
+	return columns
+}
+
+// flashbackLimit 备份查询的 LIMIT，原语句的 LIMIT 小于 max 时使用原语句的 LIMIT
+func flashbackLimit(limit *sqlparser.Limit, max int) *sqlparser.Limit {
+	if limit != nil && limit.Offset == nil {
+		if val, ok := limit.Rowcount.(*sqlparser.SQLVal); ok && val.Type == sqlparser.IntVal {
+			if n, err := strconv.Atoi(string(val.Val)); err == nil && n <= max {
+				return limit
+			}
+		}
+	}
+	return &sqlparser.Limit{Rowcount: sqlparser.NewIntVal([]byte(strconv.Itoa(max)))}
+}
+
+// flashbackKeys 获取用于定位行的主键列，没有主键时使用第一个所有列都非空的唯一键
+func flashbackKeys(conn *database.Connector, table string) []string {
+	idxInfo, err := conn.ShowIndex(table)
+	if err != nil {
+		common.Log.Debug("flashbackKeys ShowIndex Error: %s", err.Error())
+		return nil
+	}
+
+	indexes := make(map[string][]database.TableIndexRow)
+	var names []string
+	for _, row := range idxInfo.Rows {
+		if row.NonUnique != 0 {
+			continue
+		}
+		if _, ok := indexes[row.KeyName]; !ok {
+			names = append(names, row.KeyName)
+		}
+		indexes[row.KeyName] = append(indexes[row.KeyName], row)
+	}
+	if _, ok := indexes["PRIMARY"]; ok {
+		names = append([]string{"PRIMARY"}, names...)
+	}
+
+	for _, name := range names {
+		rows := indexes[name]
+		sort.Slice(rows, func(i, j int) bool { return rows[i].SeqInIndex < rows[j].SeqInIndex })
+		var keys []string
+		for _, row := range rows {
+			if row.Null == "YES" || row.SubPart > 0 || row.ColumnName == "" {
+				keys = nil
+				break
+			}
+			keys = append(keys, row.ColumnName)
+		}
+		if len(keys) > 0 {
+			return keys
+		}
+	}
+	return nil
+}
+
+// flashbackColumns 获取表中的生成列及 ON UPDATE CURRENT_TIMESTAMP 列
+func flashbackColumns(conn *database.Connector, table string) (generated, onUpdate []string) {
+	desc, err := conn.ShowColumns(table)
+	if err != nil {
+		common.Log.Debug("flashbackColumns ShowColumns Error: %s", err.Error())
+		return nil, nil
+	}
+	for _, col := range desc.DescValues {
+		switch {
+		case generatedColumnExp.MatchString(col.Extra):
+			generated = append(generated, col.Field)
+		case strings.Contains(strings.ToLower(col.Extra), "on update"):
+			onUpdate = append(onUpdate, col.Field)
+		}
+	}
+	return generated, onUpdate
+}
+
+// flashbackQuery 执行备份查询，返回列名及每行各列的 SQL 字面值
+func flashbackQuery(conn *database.Connector, query string) ([]string, [][]string, error) {
+	res, err := conn.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Rows.Close()
+
+	columns, err := res.Rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	types, err := res.Rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows [][]string
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for res.Rows.Next() {
+		if err = res.Rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(columns))
+		for i, v := range values {
+			switch {
+			case v == nil:
+				row[i] = "NULL"
+			case binaryTypes[types[i].DatabaseTypeName()]:
+				row[i] = fmt.Sprintf("X'%X'", []byte(v))
+			default:
+				row[i] = "'" + database.Escape(string(v), false) + "'"
+			}
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, res.Rows.Err()
+}
+
+// flashbackStatements 根据前镜像生成闪回语句，DELETE 生成 INSERT，UPDATE 生成按主键还原被修改列及 ON UPDATE 列的 UPDATE
+func flashbackStatements(tb flashbackTable, columns []string, rows [][]string) []string {
+	var statements []string
+	quoted := make([]string, len(columns))
+	index := make(map[string]int)
+	var insertCols []string
+	for i, col := range columns {
+		quoted[i] = "`" + col + "`"
+		index[strings.ToLower(col)] = i
+		if !flashbackUpdated(tb.Generated, col) {
+			insertCols = append(insertCols, quoted[i])
+		}
+	}
+
+	for _, row := range rows {
+		if tb.Delete {
+			// 生成列的值由 MySQL 计算，INSERT 时不能指定
+			var values []string
+			for i, col := range columns {
+				if !flashbackUpdated(tb.Generated, col) {
+					values = append(values, row[i])
+				}
+			}
+			statements = append(statements, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)%s",
+				tb.Name, strings.Join(insertCols, ", "), strings.Join(values, ", "), common.Config.Delimiter))
+			continue
+		}
+
+		var set, where []string
+		for _, col := range tb.Updated {
+			if i, ok := index[strings.ToLower(col)]; ok {
+				set = append(set, fmt.Sprintf("%s = %s", quoted[i], row[i]))
+			}
+		}
+		// ON UPDATE CURRENT_TIMESTAMP 列在 UPDATE 及回滚时都会被修改，需要同时还原
+		if len(set) > 0 {
+			for _, col := range tb.OnUpdate {
+				if i, ok := index[strings.ToLower(col)]; ok && !flashbackUpdated(tb.Updated, col) {
+					set = append(set, fmt.Sprintf("%s = %s", quoted[i], row[i]))
+				}
+			}
+		}
+		keys := tb.Keys
+		if len(keys) == 0 {
+			// 没有主键时使用未被修改的列定位行
+			for _, col := range columns {
+				if !flashbackUpdated(tb.Updated, col) && !flashbackUpdated(tb.OnUpdate, col) {
+					keys = append(keys, col)
+				}
+			}
+		}
+		for _, key := range keys {
+			i, ok := index[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+			if row[i] == "NULL" {
+				where = append(where, quoted[i]+" IS NULL")
+			} else {
+				where = append(where, fmt.Sprintf("%s = %s", quoted[i], row[i]))
+			}
+		}
+		if len(set) == 0 || len(where) == 0 {
+			continue
+		}
+		limit := ""
+		if len(tb.Keys) == 0 {
+			limit = " LIMIT 1"
+		}
+		statements = append(statements, fmt.Sprintf("UPDATE %s SET %s WHERE %s%s%s",
+			tb.Name, strings.Join(set, ", "), strings.Join(where, " AND "), limit, common.Config.Delimiter))
+	}
+	return statements
+}
+
+func flashbackUpdated(updated []string, col string) bool {
+	for _, u := range updated {
+		if strings.EqualFold(u, col) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatFlashback 输出闪回文件内容
+func FormatFlashback(results []FlashbackResult) string {
+	buf := []string{
+		fmt.Sprintf("-- soar flashback, generated at %s from %s", time.Now().Format("2006-01-02 15:04:05"),
+			common.Config.OnlineDSN.Addr),
+		"-- 前镜像在执行变更前查询得到，如查询与变更之间数据发生变化，闪回语句可能无法完全还原数据",
+	}
+	for _, r := range results {
+		buf = append(buf, "", "-- 闪回: "+ast.Compress(r.SQL))
+		if r.Select != "" {
+			buf = append(buf, fmt.Sprintf("-- 备份查询: %s, 行数: %d", r.Select, r.Rows))
+		}
+		for _, w := range r.Warnings {
+			buf = append(buf, "-- 警告: "+w)
+		}
+		buf = append(buf, r.Statements...)
+	}
+	return strings.Join(buf, "\n")
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func TestFlashback(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	conn := *rEnv
+	conn.Database = "sakila"
+	buf := "DELETE FROM film WHERE film_id = 1; UPDATE actor SET first_name = 'x' WHERE actor_id IN (1, 2);"
+	results := Flashback(&conn, buf)
+	// 后执行的语句先闪回
+	if len(results) != 2 || results[0].Rows != 2 || results[1].Rows != 1 {
+		t.Errorf("got results: %s", pretty.Sprint(results))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFlashbackLimit(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string]string{
+		"DELETE FROM film WHERE length > 100":          " limit 1001",
+		"DELETE FROM film WHERE length > 100 LIMIT 10": " limit 10",
+		"UPDATE film SET length = 1 LIMIT 5000":        " limit 1001",
+	}
+	for sql, want := range cases {
+		stmt, err := sqlparser.Parse(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var limit *sqlparser.Limit
+		switch node := stmt.(type) {
+		case *sqlparser.Delete:
+			limit = node.Limit
+		case *sqlparser.Update:
+			limit = node.Limit
+		}
+		if got := sqlparser.String(flashbackLimit(limit, 1001)); got != want {
+			t.Errorf("SQL: %s, want: '%s', got: '%s'", sql, want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFlashbackStatements(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	columns := []string{"film_id", "title", "description", "cover"}
+	rows := [][]string{
+		{"'1'", "'ACADEMY DINOSAUR'", "NULL", "X'FF00'"},
+		{"'2'", "'ACE \\'GOLDFINGER\\''", "'desc'", "NULL"},
+	}
+	tables := []flashbackTable{
+		{Name: "`sakila`.`film`", Keys: []string{"film_id"}, Delete: true},
+		{Name: "`sakila`.`film`", Keys: []string{"film_id"}, Updated: []string{"title", "DESCRIPTION"}},
+		{Name: "`sakila`.`film`", Updated: []string{"title"}},
+		{Name: "`sakila`.`film`", Keys: []string{"film_id"}, Delete: true, Generated: []string{"cover"}},
+		{Name: "`sakila`.`film`", Updated: []string{"title"}, OnUpdate: []string{"description"}},
+		{Name: "`sakila`.`film`", Keys: []string{"film_id"}, Updated: []string{"title"}, OnUpdate: []string{"description"}},
+	}
+	err := common.GoldenDiff(func() {
+		for _, tb := range tables {
+			for _, s := range flashbackStatements(tb, columns, rows) {
+				fmt.Println(s)
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFlashbackOverlap(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	columns := []string{"film_id", "title"}
+	first := flashbackTable{Name: "`sakila`.`film`", Keys: []string{"film_id"}, Updated: []string{"title"}}
+	touched := newFlashbackTouched(first, columns, [][]string{{"'1'", "'a'"}, {"'2'", "'b'"}})
+	cases := []struct {
+		tb    flashbackTable
+		where []string
+		rows  [][]string
+		want  bool
+	}{
+		// 不同的行
+		{first, []string{"film_id"}, [][]string{{"'3'", "'c'"}}, false},
+		// 相同的行
+		{first, []string{"film_id"}, [][]string{{"'3'", "'c'"}, {"'2'", "'b'"}}, true},
+		// WHERE 条件使用了前面语句修改过的列
+		{first, []string{"TITLE"}, [][]string{{"'3'", "'c'"}}, true},
+		// 没有主键无法判断修改了哪些行
+		{flashbackTable{Name: "`sakila`.`film`", Delete: true}, nil, [][]string{{"'3'", "'c'"}}, true},
+	}
+	for i, c := range cases {
+		if got := touched.overlap(c.tb, c.where, columns, c.rows); got != c.want {
+			t.Errorf("case %d, want: %v, got: %v", i, c.want, got)
+		}
+	}
+	// 第一次修改该表
+	var none *flashbackTouched
+	if none.overlap(first, nil, columns, [][]string{{"'1'", "'a'"}}) {
+		t.Error("table not touched before should not overlap")
+	}
+	// 合并后包含前后两条语句修改的行
+	merged := touched.merge(newFlashbackTouched(first, columns, [][]string{{"'3'", "'c'"}}))
+	if !merged.overlap(first, nil, columns, [][]string{{"'3'", "'c'"}}) || !merged.overlap(first, nil, columns, [][]string{{"'1'", "'c'"}}) {
+		t.Errorf("got merged: %s", pretty.Sprint(merged))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
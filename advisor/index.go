@@ -56,6 +56,7 @@ type IndexInfo struct {
 	Table         string           `json:"table"`          // 表名
 	DDL           string           `json:"ddl"`            // ALTER, CREATE 等类型的 DDL 语句
 	ColumnDetails []*common.Column `json:"column_details"` // 列详情
+	Warning       string           `json:"warning"`        // 添加索引可能带来的问题，如超过单表最大索引数
 }
 
 // IndexAdvises IndexAdvises列表
@@ -505,6 +506,9 @@ func (idxAdv *IndexAdvisor) mergeIndexes(idxList []IndexInfo) []IndexInfo {
 			continue
 		}
 
+		// 已存在的普通索引是索引建议的最左前缀时，扩展原有索引，避免新增一个冗余索引
+		extendName := extendableIndex(indexMeta, idx)
+
 		if existedIndexes := indexMeta.FindIndex(database.IndexColumnName, idx.ColumnDetails[0].Name); len(existedIndexes) > 0 {
 			for _, existedIdx := range existedIndexes {
 				// flag: 用于标记已存在的索引是否是约束条件
@@ -543,7 +547,7 @@ func (idxAdv *IndexAdvisor) mergeIndexes(idxList []IndexInfo) []IndexInfo {
 
 					// 库、表、列名需要用反撇转义
 					// TODO: 关于外键索引去重的优雅解决方案
-					if !isConstraint {
+					if !isConstraint && idxName != extendName {
 						if common.Config.AllowDropIndex {
 							alterSQL := fmt.Sprintf("alter table `%s`.`%s` drop index `%s`", idx.Database, idx.Table, idxName)
 							indexes = append(indexes, IndexInfo{
@@ -563,7 +567,15 @@ func (idxAdv *IndexAdvisor) mergeIndexes(idxList []IndexInfo) []IndexInfo {
 			}
 		}
 
-		if !isExisted {
+		if !isExisted && extendName != "" {
+			// 在同一条 ALTER 语句中删除原索引并以原名称重建，不影响已有的 FORCE INDEX 等用法
+			common.Log.Info("extend index `%s`.`%s` %s instead of adding index %s",
+				idx.Database, idx.Table, extendName, idx.Name)
+			idx.DDL = strings.Replace(idx.DDL, fmt.Sprintf("add index `%s`", idx.Name),
+				fmt.Sprintf("drop index `%s`, add index `%s`", extendName, extendName), 1)
+			idx.Name = extendName
+			indexes = mergeAdvices(indexes, idx)
+		} else if !isExisted {
 			// 检测索引名称是否重复?
 			if existedIndexes := indexMeta.FindIndex(database.IndexKeyName, idx.Name); len(existedIndexes) > 0 {
 				var newName string
@@ -586,7 +598,89 @@ func (idxAdv *IndexAdvisor) mergeIndexes(idxList []IndexInfo) []IndexInfo {
 	}
 
 	// 对索引进行去重
-	return rmSelfDupIndex(indexes)
+	indexes = rmSelfDupIndex(indexes)
+	idxAdv.checkIndexCount(indexes)
+	return indexes
+}
+
+// extendableIndex 查找可以通过追加列扩展为索引建议的已存在索引，返回索引名称
+// 只有非唯一、BTREE 类型、不含前缀长度及函数表达式，且为索引建议最左前缀的索引才会被扩展
+// 存在多个可扩展的索引时选择列数最多的一个
+func extendableIndex(indexMeta *database.TableIndexInfo, idx IndexInfo) string {
+	var name string
+	// 索引建议中包含前缀索引时不做扩展，避免原有索引的区分度降低
+	if len(idx.ColumnDetails) == 0 || strings.Contains(idx.DDL, "`(") {
+		return name
+	}
+
+	maxCols := 0
+	for _, row := range indexMeta.FindIndex(database.IndexColumnName, idx.ColumnDetails[0].Name) {
+		if row.SeqInIndex != 1 || row.NonUnique == 0 {
+			continue
+		}
+
+		keyRows := indexMeta.FindIndex(database.IndexKeyName, row.KeyName)
+		if len(keyRows) >= len(idx.ColumnDetails) || len(keyRows) <= maxCols {
+			continue
+		}
+
+		extendable := true
+		for i, keyRow := range keyRows {
+			if !strings.EqualFold(keyRow.IndexType, "BTREE") || keyRow.SubPart > 0 || len(keyRow.Expression) > 0 ||
+				!strings.EqualFold(keyRow.ColumnName, idx.ColumnDetails[i].Name) {
+				extendable = false
+				break
+			}
+		}
+
+		if extendable {
+			name = row.KeyName
+			maxCols = len(keyRows)
+		}
+	}
+	return name
+}
+
+// checkIndexCount 检查添加索引后单表索引数量是否超过 max-index-count 的限制
+func (idxAdv *IndexAdvisor) checkIndexCount(indexes []IndexInfo) {
+	count := make(map[string]int)
+	for _, idx := range indexes {
+		key := idx.Database + "." + idx.Table
+		if _, ok := count[key]; !ok {
+			dbInVEnv := idx.Database
+			if _, ok := idxAdv.vEnv.DBRef[idx.Database]; ok {
+				dbInVEnv = idxAdv.vEnv.DBRef[idx.Database]
+			}
+			keyNames := make(map[string]bool)
+			if indexMeta := idxAdv.IndexMeta[dbInVEnv][idx.Table]; indexMeta != nil {
+				for _, row := range indexMeta.Rows {
+					keyNames[row.KeyName] = true
+				}
+			}
+			count[key] = len(keyNames)
+		}
+
+		// 扩展已有索引不改变索引数量
+		ddl := strings.ToLower(idx.DDL)
+		hasAdd := strings.Contains(ddl, " add index ")
+		hasDrop := strings.Contains(ddl, " drop index ")
+		switch {
+		case hasAdd && !hasDrop:
+			count[key]++
+		case hasDrop && !hasAdd:
+			count[key]--
+		}
+	}
+
+	for i, idx := range indexes {
+		total := count[idx.Database+"."+idx.Table]
+		if total > common.Config.MaxIdxCount {
+			common.Log.Warning("`%s`.`%s` will have %d indexes, more than max-index-count %d",
+				idx.Database, idx.Table, total, common.Config.MaxIdxCount)
+			indexes[i].Warning = fmt.Sprintf("添加索引后表中将有%d个索引，超过了单表最大索引个数%d的限制，请考虑合并或删除部分索引。",
+				total, common.Config.MaxIdxCount)
+		}
+	}
 }
 
 // getRandomIndexSuffix format: _xxxx, length: 5
@@ -1004,6 +1098,9 @@ func (idxAdvs IndexAdvises) Format() map[string]Rule {
 		if !common.Config.Sampling && len(rules[advKey].Content) > 5 {
 			rules[advKey].Content += " 由于未开启数据采样，各列在索引中的顺序需要自行调整。"
 		}
+		if advise.Warning != "" && !strings.Contains(rules[advKey].Content, advise.Warning) {
+			rules[advKey].Content += " " + advise.Warning
+		}
 		// 清理多余的标点
 		rules[advKey].Content = strings.Trim(rules[advKey].Content, common.Config.Delimiter)
 	}
@@ -1102,7 +1199,6 @@ func DuplicateKeyChecker(conn *database.Connector, databases ...string) map[stri
 
 		for _, tb := range tables {
 			// 获取表中所有的索引
-			idxMap := make(map[string][]*common.Column)
 			idxInfo, err := tmpOnline.ShowIndex(tb)
 			if err != nil {
 				funcErrCheck(err)
@@ -1111,57 +1207,404 @@ func DuplicateKeyChecker(conn *database.Connector, databases ...string) map[stri
 				}
 			}
 
-			// 枚举所有的索引信息，提取用到的列
-			for _, idx := range idxInfo.Rows {
-				if _, ok := idxMap[idx.KeyName]; !ok {
-					idxMap[idx.KeyName] = make([]*common.Column, 0)
-					for _, col := range idxInfo.FindIndex(database.IndexKeyName, idx.KeyName) {
-						idxMap[idx.KeyName] = append(idxMap[idx.KeyName], &common.Column{
-							Name:  col.ColumnName,
-							Table: tb,
-							DB:    db,
-						})
-					}
-				}
+			// InnoDB 的二级索引隐含了主键列
+			isInnoDB := false
+			if status, err := tmpOnline.ShowTableStatus(tb); err == nil && len(status.Rows) > 0 {
+				isInnoDB = strings.EqualFold(string(status.Rows[0].Engine), "InnoDB")
+			} else {
+				funcErrCheck(err)
+			}
+
+			// 外键需要使用的索引不能删除
+			var fkCols []fkIndexCols
+			fks, err := tmpOnline.ShowReference(db, tb)
+			funcErrCheck(err)
+			for _, fk := range fks {
+				fkCols = append(fkCols, fkIndexCols{Name: fk.ConstraintName, Columns: fk.Columns})
+			}
+			refs, err := tmpOnline.ShowReferenced(db, tb)
+			funcErrCheck(err)
+			for _, fk := range refs {
+				fkCols = append(fkCols, fkIndexCols{Name: fk.ConstraintName, Columns: fk.ReferencedColumns})
 			}
 
 			// 对索引进行重复检查
-			hasDup := false
-			content := ""
-
-			for k1, cl1 := range idxMap {
-				for k2, cl2 := range idxMap {
-					if k1 != k2 && common.IsColsPart(cl1, cl2) {
-						// by pass primary key
-						if k1 == "PRIMARY" || k2 == "PRIMARY" {
-							continue
-						}
-						hasDup = true
-						col1Str := common.JoinColumnsName(cl1, ", ")
-						col2Str := common.JoinColumnsName(cl2, ", ")
-						content += fmt.Sprintf("索引%s(%s)与%s(%s)重复;", k1, col1Str, k2, col2Str)
-						common.Log.Debug(" %s.%s has duplicate index %s(%s) <--> %s(%s)", db, tb, k1, col1Str, k2, col2Str)
-					}
+			content, drops := duplicateKeys(idxInfo, isInnoDB, fkCols)
+			if content == "" {
+				continue
+			}
+			common.Log.Debug("%s.%s has duplicate index: %s", db, tb, content)
+
+			tmpOnline.Database = db
+			ddl, _ := tmpOnline.ShowCreateTable(tb)
+			if common.Config.AllowDropIndex && len(drops) > 0 {
+				var alterSQLs []string
+				for _, name := range drops {
+					alterSQLs = append(alterSQLs, fmt.Sprintf("alter table `%s`.`%s` drop index `%s`", db, tb, name))
+				}
+				for _, alter := range ast.MergeAlterTables(alterSQLs...) {
+					ddl = fmt.Sprintf("%s;\n-- 删除冗余索引\n%s", ddl, strings.TrimSpace(alter))
 				}
-				delete(idxMap, k1)
-			}
-
-			// TODO 重复索引检查添加对约束及索引的判断，提供重复索引的删除功能
-			if hasDup {
-				tmpOnline.Database = db
-				ddl, _ := tmpOnline.ShowCreateTable(tb)
-				key := fmt.Sprintf("IDX.%03d", number)
-				ruleMap[key] = Rule{
-					Item:     key,
-					Severity: "L2",
-					Summary:  fmt.Sprintf("%s.%s存在重复的索引", db, tb),
-					Content:  content,
-					Case:     ddl,
+			}
+
+			key := fmt.Sprintf("IDX.%03d", number)
+			ruleMap[key] = Rule{
+				Item:     key,
+				Severity: "L2",
+				Summary:  fmt.Sprintf("%s.%s存在重复的索引", db, tb),
+				Content:  content,
+				Case:     ddl,
+			}
+			number++
+		}
+	}
+
+	return ruleMap
+}
+
+// fkIndexCols 外键约束要求有索引的列，子表中为外键列，父表中为被引用列
+type fkIndexCols struct {
+	Name    string
+	Columns []string
+}
+
+// dupKey 重复索引检查中使用的索引信息
+type dupKey struct {
+	Name      string
+	Unique    bool
+	IndexType string
+	Columns   []database.TableIndexRow // 定义索引时指定的列
+	Effective []database.TableIndexRow // 实际存储的列，InnoDB 二级索引会在末尾隐含主键列
+}
+
+// duplicateKeys 检查一张表中的冗余索引，返回检查结果的描述和可以安全删除的索引
+// 1. FULLTEXT、SPATIAL 索引不参与检查，不同类型的索引之间不做比较
+// 2. InnoDB 表中二级索引隐含主键列，以主键前缀结尾的索引与不含该后缀的索引是相同的
+// 3. 唯一索引是约束条件，不会因为被其他索引覆盖而删除
+// 4. 外键需要使用的索引，只有在还有其他可用索引时才能删除
+func duplicateKeys(idxInfo *database.TableIndexInfo, isInnoDB bool, fks []fkIndexCols) (string, []string) {
+	var content string
+	var drops []string
+	if idxInfo == nil {
+		return content, drops
+	}
+
+	// 按 SHOW INDEX 的顺序整理索引信息
+	var keys []*dupKey
+	keyMap := make(map[string]*dupKey)
+	for _, row := range idxInfo.Rows {
+		if k, ok := keyMap[row.KeyName]; ok {
+			k.Columns = append(k.Columns, row)
+			continue
+		}
+		k := &dupKey{
+			Name:      row.KeyName,
+			Unique:    row.NonUnique == 0,
+			IndexType: strings.ToUpper(row.IndexType),
+			Columns:   []database.TableIndexRow{row},
+		}
+		keyMap[row.KeyName] = k
+		keys = append(keys, k)
+	}
+
+	pk := keyMap["PRIMARY"]
+	for _, k := range keys {
+		k.Effective = k.Columns
+		if !isInnoDB || pk == nil || k == pk {
+			continue
+		}
+		for _, pkCol := range pk.Columns {
+			if !hasIndexColumn(k.Columns, pkCol.ColumnName) {
+				k.Effective = append(k.Effective, pkCol)
+			}
+		}
+		// 以主键前缀结尾的二级索引，末尾的主键列是多余的
+		if !k.Unique && k.IndexType == "BTREE" {
+			for n := len(pk.Columns); n > 0; n-- {
+				if len(k.Columns) > n && isIndexColsPrefix(pk.Columns[:n], k.Columns[len(k.Columns)-n:], true) {
+					content += fmt.Sprintf("索引%s(%s)末尾的主键列是多余的，InnoDB二级索引已隐含主键;",
+						k.Name, joinIndexColumns(k.Columns))
+					break
+				}
+			}
+		}
+	}
+
+	dropped := make(map[string]bool)
+	kept := make(map[string]bool)
+	fkRequired := make(map[string]bool)
+	for _, k := range keys {
+		fkRequired[k.Name] = requiredByForeignKey(k, keys, dropped, fks) != ""
+	}
+	for i, k1 := range keys {
+		for j, k2 := range keys {
+			// k1 为被覆盖的索引，k2 为覆盖它的索引，主键不会被删除
+			if k1 == k2 || k1 == pk || dropped[k1.Name] || dropped[k2.Name] ||
+				k1.IndexType != k2.IndexType || k1.IndexType == "FULLTEXT" || k1.IndexType == "SPATIAL" || k1.IndexType == "RTREE" {
+				continue
+			}
+
+			// 唯一索引是另一个唯一索引的前缀，较长的唯一约束是冗余的
+			if k1.Unique && k2.Unique && k2 != pk && len(k1.Columns) < len(k2.Columns) &&
+				isIndexColsPrefix(k1.Columns, k2.Columns, false) {
+				content += fmt.Sprintf("唯一索引%s(%s)已保证唯一性，%s(%s)的唯一约束是冗余的;",
+					k1.Name, joinIndexColumns(k1.Columns), k2.Name, joinIndexColumns(k2.Columns))
+				continue
+			}
+
+			if !k1.coveredBy(k2) {
+				continue
+			}
+
+			if k1.Unique {
+				// 唯一索引只有与另一个唯一索引完全相同时才是重复的
+				if !k2.Unique || !isIndexColsPrefix(k1.Columns, k2.Columns, true) || (k2 != pk && i < j) {
+					continue
+				}
+			} else if !k2.Unique && k2.coveredBy(k1) {
+				// 两个普通索引互相覆盖时只保留一个，保留外键需要的，其次优先删除显式包含主键列的，最后删除靠后的
+				if !fkRequired[k2.Name] &&
+					(fkRequired[k1.Name] || len(k1.Columns) < len(k2.Columns) || (len(k1.Columns) == len(k2.Columns) && i < j)) {
+					continue
+				}
+			}
+
+			if k2 == pk {
+				content += fmt.Sprintf("索引%s(%s)与主键(%s)重复;",
+					k1.Name, joinIndexColumns(k1.Columns), joinIndexColumns(pk.Columns))
+			} else {
+				content += fmt.Sprintf("索引%s(%s)与%s(%s)重复;",
+					k1.Name, joinIndexColumns(k1.Columns), k2.Name, joinIndexColumns(k2.Columns))
+			}
+
+			// 检查删除后外键是否还有可用的索引
+			if fk := requiredByForeignKey(k1, keys, dropped, fks); fk != "" {
+				if !kept[k1.Name] {
+					content += fmt.Sprintf("索引%s被外键%s使用，不能删除;", k1.Name, fk)
+					kept[k1.Name] = true
+				}
+				continue
+			}
+			dropped[k1.Name] = true
+			drops = append(drops, k1.Name)
+		}
+	}
+
+	return content, drops
+}
+
+// coveredBy 判断索引 k 是否被 other 覆盖，即 k 的列（或 InnoDB 中实际存储的列）是 other 的最左前缀
+func (k *dupKey) coveredBy(other *dupKey) bool {
+	return isIndexColsPrefix(k.Columns, other.Columns, false) || isIndexColsPrefix(k.Effective, other.Effective, false)
+}
+
+// requiredByForeignKey 判断删除索引后外键是否还有可用的索引，返回依赖该索引的外键名称
+func requiredByForeignKey(k *dupKey, keys []*dupKey, dropped map[string]bool, fks []fkIndexCols) string {
+	for _, fk := range fks {
+		if !isFKIndex(k.Columns, fk.Columns) {
+			continue
+		}
+		usable := false
+		for _, other := range keys {
+			if other != k && !dropped[other.Name] && other.IndexType == "BTREE" && isFKIndex(other.Columns, fk.Columns) {
+				usable = true
+				break
+			}
+		}
+		if !usable {
+			return fk.Name
+		}
+	}
+	return ""
+}
+
+// isFKIndex 索引的最左列与外键列相同时可被外键使用
+func isFKIndex(cols []database.TableIndexRow, fkCols []string) bool {
+	if len(fkCols) == 0 || len(cols) < len(fkCols) {
+		return false
+	}
+	for i, col := range fkCols {
+		if !strings.EqualFold(cols[i].ColumnName, col) || cols[i].SubPart > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isIndexColsPrefix 判断索引列 a 是否为 b 的最左前缀，考虑前缀索引长度，equal 为 true 时要求列数相同
+func isIndexColsPrefix(a, b []database.TableIndexRow, equal bool) bool {
+	if len(a) > len(b) || (equal && len(a) != len(b)) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i].ColumnName, b[i].ColumnName) {
+			return false
+		}
+		// 前缀索引只能被相同或更长的前缀覆盖，SubPart 为 0 表示整列
+		if b[i].SubPart > 0 && (a[i].SubPart == 0 || a[i].SubPart > b[i].SubPart) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasIndexColumn 判断索引中是否包含某列
+func hasIndexColumn(cols []database.TableIndexRow, name string) bool {
+	for _, col := range cols {
+		if strings.EqualFold(col.ColumnName, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinIndexColumns 将索引中的列名合并，前缀索引会带上长度
+func joinIndexColumns(cols []database.TableIndexRow) string {
+	var names []string
+	for _, col := range cols {
+		if col.SubPart > 0 {
+			names = append(names, fmt.Sprintf("%s(%d)", col.ColumnName, col.SubPart))
+		} else {
+			names = append(names, col.ColumnName)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// UnusedIndexChecker 根据 performance_schema 中的统计信息检查线上环境中未被使用的索引
+func UnusedIndexChecker(conn *database.Connector, databases ...string) map[string]Rule {
+	common.Log.Debug("Enter:  UnusedIndexChecker, Caller: %s", common.Caller())
+	// 复制一份online connector,防止环境切换影响其他功能的使用
+	tmpOnline := *conn
+	ruleMap := make(map[string]Rule)
+	number := 1
+
+	// 错误处理，用于汇总所有的错误
+	funcErrCheck := func(err error) {
+		if err != nil {
+			if sug, ok := ruleMap["ERR.003"]; ok {
+				sug.Content += fmt.Sprintf("; %s", err.Error())
+				ruleMap["ERR.003"] = sug
+			} else {
+				ruleMap["ERR.003"] = Rule{
+					Item:     "ERR.003",
+					Severity: "L8",
+					Content:  err.Error(),
 				}
-				number++
 			}
 		}
 	}
 
+	// 不指定 DB 的时候检查 online dsn 中的 DB
+	if len(databases) == 0 {
+		databases = append(databases, tmpOnline.Database)
+	}
+
+	for _, db := range databases {
+		tmpOnline.Database = db
+		rows, err := tmpOnline.ShowUnusedIndexes(db)
+		if err != nil {
+			funcErrCheck(err)
+			if !common.Config.DryRun {
+				return ruleMap
+			}
+		}
+
+		// 按表整理未使用的索引
+		var tables []string
+		unused := make(map[string][]string)
+		for _, row := range rows {
+			if _, ok := unused[row.Table]; !ok {
+				tables = append(tables, row.Table)
+			}
+			unused[row.Table] = append(unused[row.Table], row.Index)
+		}
+
+		for _, tb := range tables {
+			idxInfo, err := tmpOnline.ShowIndex(tb)
+			if err != nil {
+				funcErrCheck(err)
+				continue
+			}
+
+			// innodb_index_stats 中没有统计信息时按 SHOW TABLE STATUS 中的二级索引总大小估算
+			indexSize, err := tmpOnline.ShowIndexSize(db, tb)
+			if err != nil {
+				common.Log.Debug("ShowIndexSize Error: %s", err.Error())
+			}
+			var indexLength int64
+			if status, err := tmpOnline.ShowTableStatus(tb); err == nil && len(status.Rows) > 0 {
+				indexLength = database.NullInt(status.Rows[0].IndexLength)
+			} else {
+				funcErrCheck(err)
+			}
+
+			content, drops := unusedIndexes(idxInfo, unused[tb], indexSize, indexLength)
+			if len(drops) == 0 {
+				continue
+			}
+
+			var alterSQLs []string
+			for _, name := range drops {
+				alterSQLs = append(alterSQLs, fmt.Sprintf("alter table `%s`.`%s` drop index `%s`", db, tb, name))
+			}
+			var ddl string
+			for _, alter := range ast.MergeAlterTables(alterSQLs...) {
+				ddl = strings.TrimSpace(alter)
+			}
+
+			key := fmt.Sprintf("IDX.%03d", number)
+			ruleMap[key] = Rule{
+				Item:     key,
+				Severity: "L2",
+				Summary:  fmt.Sprintf("%s.%s存在未使用的索引", db, tb),
+				Content:  content,
+				Case:     ddl,
+			}
+			number++
+		}
+	}
+
 	return ruleMap
 }
+
+// unusedIndexes 过滤掉主键和唯一索引等约束条件，返回检查结果的描述和可以删除的索引
+// indexSize 为 innodb_index_stats 中每个索引的大小，没有统计信息时按 SHOW TABLE STATUS 中二级索引总大小 indexLength 的平均值估算
+func unusedIndexes(idxInfo *database.TableIndexInfo, unused []string, indexSize map[string]int64, indexLength int64) (string, []string) {
+	var content string
+	var drops []string
+	if idxInfo == nil {
+		return content, drops
+	}
+
+	secondary := make(map[string]bool)
+	for _, row := range idxInfo.Rows {
+		if row.KeyName != "PRIMARY" {
+			secondary[row.KeyName] = true
+		}
+	}
+
+	for _, name := range unused {
+		rows := idxInfo.FindIndex(database.IndexKeyName, name)
+		if len(rows) == 0 || name == "PRIMARY" || rows[0].NonUnique == 0 {
+			continue
+		}
+		var cols []string
+		for _, row := range rows {
+			cols = append(cols, row.ColumnName)
+		}
+		content += fmt.Sprintf("索引%s(%s)自实例启动以来未被使用", name, strings.Join(cols, ", "))
+		if size := indexSize[name]; size > 0 {
+			content += fmt.Sprintf("，可回收空间%s", common.HumanBytes(size))
+		} else if indexLength > 0 && len(secondary) > 0 {
+			content += fmt.Sprintf("，按二级索引平均大小估算可回收空间%s", common.HumanBytes(indexLength/int64(len(secondary))))
+		}
+		content += ";"
+		drops = append(drops, name)
+	}
+
+	if len(drops) > 0 {
+		content += " performance_schema 中的统计信息在实例重启后会被清空，删除索引前请确认统计周期足够长，并检查从库上是否有查询依赖这些索引。"
+	}
+	return content, drops
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 
@@ -517,3 +518,182 @@ func TestGetRandomIndexSuffix(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestExtendableIndex(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	indexMeta := &database.TableIndexInfo{
+		TableName: "city",
+		Rows: []database.TableIndexRow{
+			{Table: "city", NonUnique: 0, KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "city_id", IndexType: "BTREE"},
+			{Table: "city", NonUnique: 1, KeyName: "idx_country_id", SeqInIndex: 1, ColumnName: "country_id", IndexType: "BTREE"},
+			{Table: "city", NonUnique: 1, KeyName: "idx_city", SeqInIndex: 1, ColumnName: "city", IndexType: "BTREE", SubPart: 10},
+		},
+	}
+
+	cases := []struct {
+		cols   []string
+		expect string
+	}{
+		{[]string{"country_id", "city"}, "idx_country_id"},
+		{[]string{"country_id"}, ""},
+		{[]string{"city_id", "city"}, ""},
+		{[]string{"city", "country_id"}, ""},
+		{[]string{"last_update", "country_id"}, ""},
+	}
+	for _, c := range cases {
+		idx := IndexInfo{Name: "idx_" + strings.Join(c.cols, "_"), Database: "sakila", Table: "city"}
+		for _, col := range c.cols {
+			idx.ColumnDetails = append(idx.ColumnDetails, &common.Column{Name: col, Table: "city", DB: "sakila"})
+		}
+		if name := extendableIndex(indexMeta, idx); name != c.expect {
+			t.Errorf("cols: %v, want: '%s', got: '%s'", c.cols, c.expect, name)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	row := func(key string, nonUnique, seq int, col, typ string) database.TableIndexRow {
+		return database.TableIndexRow{Table: "tb", NonUnique: nonUnique, KeyName: key, SeqInIndex: seq, ColumnName: col, IndexType: typ}
+	}
+	idxInfo := &database.TableIndexInfo{
+		TableName: "tb",
+		Rows: []database.TableIndexRow{
+			row("PRIMARY", 0, 1, "id", "BTREE"),
+			row("idx_id", 1, 1, "id", "BTREE"),
+			row("idx_a", 1, 1, "a", "BTREE"),
+			row("idx_a_id", 1, 1, "a", "BTREE"),
+			row("idx_a_id", 1, 2, "id", "BTREE"),
+			row("idx_b", 1, 1, "b", "BTREE"),
+			row("idx_b_c", 1, 1, "b", "BTREE"),
+			row("idx_b_c", 1, 2, "c", "BTREE"),
+			row("uk_c", 0, 1, "c", "BTREE"),
+			row("idx_c", 1, 1, "c", "BTREE"),
+			row("uk_c_d", 0, 1, "c", "BTREE"),
+			row("uk_c_d", 0, 2, "d", "BTREE"),
+			row("idx_e", 1, 1, "e", "BTREE"),
+			row("idx_e_f", 1, 1, "e", "BTREE"),
+			row("idx_e_f", 1, 2, "f", "BTREE"),
+			row("ft_title", 1, 1, "title", "FULLTEXT"),
+			row("ft_title_2", 1, 1, "title", "FULLTEXT"),
+		},
+	}
+	// idx_b 被外键 fk_b 使用，但 idx_b_c 同样可以被外键使用
+	fks := []fkIndexCols{{Name: "fk_b", Columns: []string{"b"}}}
+
+	content, drops := duplicateKeys(idxInfo, true, fks)
+	sort.Strings(drops)
+	expect := []string{"idx_a_id", "idx_b", "idx_c", "idx_e", "idx_id"}
+	if strings.Join(drops, ",") != strings.Join(expect, ",") {
+		t.Errorf("want: %v, got: %v, content: %s", expect, drops, content)
+	}
+
+	// 非 InnoDB 表中没有隐含的主键列
+	idxInfo.Rows = []database.TableIndexRow{
+		row("idx_a", 1, 1, "a", "BTREE"),
+		row("idx_a_b", 1, 1, "a", "BTREE"),
+		row("idx_a_b", 1, 2, "b", "BTREE"),
+	}
+	fks = []fkIndexCols{{Name: "fk_a_b", Columns: []string{"a", "b"}}}
+	_, drops = duplicateKeys(idxInfo, false, fks)
+	if len(drops) != 1 || drops[0] != "idx_a" {
+		t.Errorf("want: [idx_a], got: %v", drops)
+	}
+
+	// 外键需要使用的索引不能删除，互相覆盖时保留外键需要的索引
+	idxInfo.Rows = []database.TableIndexRow{
+		row("PRIMARY", 0, 1, "id", "BTREE"),
+		row("idx_a_id", 1, 1, "a", "BTREE"),
+		row("idx_a_id", 1, 2, "id", "BTREE"),
+		row("idx_a", 1, 1, "a", "BTREE"),
+	}
+	fks = []fkIndexCols{{Name: "fk_a_id", Columns: []string{"a", "id"}}}
+	content, drops = duplicateKeys(idxInfo, true, fks)
+	if len(drops) != 1 || drops[0] != "idx_a" {
+		t.Errorf("got drops: %v, content: %s", drops, content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestUnusedIndexes(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	idxInfo := &database.TableIndexInfo{
+		TableName: "city",
+		Rows: []database.TableIndexRow{
+			{Table: "city", NonUnique: 0, KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "city_id", IndexType: "BTREE"},
+			{Table: "city", NonUnique: 0, KeyName: "uk_city", SeqInIndex: 1, ColumnName: "city", IndexType: "BTREE"},
+			{Table: "city", NonUnique: 1, KeyName: "idx_fk_country_id", SeqInIndex: 1, ColumnName: "country_id", IndexType: "BTREE"},
+			{Table: "city", NonUnique: 1, KeyName: "idx_last_update", SeqInIndex: 1, ColumnName: "last_update", IndexType: "BTREE"},
+		},
+	}
+
+	content, drops := unusedIndexes(idxInfo, []string{"uk_city", "idx_last_update", "idx_not_exist"}, nil, 3*1024*1024)
+	if len(drops) != 1 || drops[0] != "idx_last_update" || !strings.Contains(content, "估算可回收空间1.00 MB") {
+		t.Errorf("got drops: %v, content: %s", drops, content)
+	}
+
+	// 优先使用 innodb_index_stats 中的索引大小
+	indexSize := map[string]int64{"PRIMARY": 4 * 1024 * 1024, "idx_last_update": 512 * 1024}
+	content, drops = unusedIndexes(idxInfo, []string{"idx_last_update"}, indexSize, 3*1024*1024)
+	if len(drops) != 1 || !strings.Contains(content, "，可回收空间512.00 KB") {
+		t.Errorf("got drops: %v, content: %s", drops, content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestMergeIndexes(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTestDSNDisable := common.Config.TestDSN.Disable
+	orgMaxIdxCount := common.Config.MaxIdxCount
+	defer func() {
+		common.Config.TestDSN.Disable = orgTestDSNDisable
+		common.Config.MaxIdxCount = orgMaxIdxCount
+	}()
+	common.Config.TestDSN.Disable = false
+	common.Config.MaxIdxCount = 2
+
+	idxAdv := &IndexAdvisor{
+		vEnv: vEnv,
+		IndexMeta: map[string]map[string]*database.TableIndexInfo{
+			"sakila": {
+				"city": {
+					TableName: "city",
+					Rows: []database.TableIndexRow{
+						{Table: "city", NonUnique: 0, KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "city_id", IndexType: "BTREE"},
+						{Table: "city", NonUnique: 1, KeyName: "idx_country_id", SeqInIndex: 1, ColumnName: "country_id", IndexType: "BTREE"},
+					},
+				},
+			},
+		},
+	}
+	newIndex := func(cols ...string) IndexInfo {
+		idx := IndexInfo{Name: "idx_" + strings.Join(cols, "_"), Database: "sakila", Table: "city"}
+		for _, col := range cols {
+			idx.ColumnDetails = append(idx.ColumnDetails, &common.Column{Name: col, Table: "city", DB: "sakila"})
+		}
+		idx.DDL = fmt.Sprintf("alter table `sakila`.`city` add index `%s` (`%s`)", idx.Name, strings.Join(cols, "`,`"))
+		return idx
+	}
+
+	cases := [][]IndexInfo{
+		// 扩展已有索引，索引个数不变
+		{newIndex("country_id", "city")},
+		// 新增索引超过 max-index-count
+		{newIndex("last_update")},
+	}
+	err := common.GoldenDiff(func() {
+		for _, c := range cases {
+			for _, idx := range idxAdv.mergeIndexes(c) {
+				fmt.Println(idx.Name, idx.DDL)
+				if idx.Warning != "" {
+					fmt.Println(idx.Warning)
+				}
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+)
+
+// OSCTable 一张表的在线表结构变更信息
+type OSCTable struct {
+	Database string
+	Table    string
+	Alter    string   // 去除 ALTER TABLE db.tbl 前缀后的变更子句
+	Checks   []string // 前置条件检查结果
+	GhOst    bool     // 是否可以使用 gh-ost
+	PtOSC    bool     // 是否可以使用 pt-online-schema-change
+	// 表作为父表被其他表的外键引用，pt-osc 需要指定 --alter-foreign-keys-method
+	Referenced bool
+	// 表上存在触发器，pt-osc 需要指定 --preserve-triggers
+	Triggers bool
+}
+
+// oscAlterPrefixExp 合并后的 ALTER TABLE 语句前缀
+var oscAlterPrefixExp = regexp.MustCompile("(?is)^\\s*alter\\s+table\\s+((`[^`]*`|[^\\s.`]+)\\.)?(`[^`]*`|[^\\s.`]+)\\s*")
+
+// OnlineSchemaChange 将待评审 SQL 中的 ALTER 语句按表合并，生成 gh-ost 及 pt-online-schema-change 命令
+// 线上环境可用时检查工具的前置条件：主键或唯一键，触发器，外键
+func OnlineSchemaChange(conn *database.Connector, buf string) []OSCTable {
+	common.Log.Debug("Enter:  OnlineSchemaChange, Caller: %s", common.Caller())
+	var alterSQLs []string
+	for buf = strings.TrimSpace(buf); buf != ""; {
+		_, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(common.Config.Delimiter))
+		if len(bufBytes) == len(buf) {
+			buf = ""
+		} else {
+			buf = string(bufBytes)
+		}
+		sql = strings.TrimSpace(database.RemoveSQLComments(sql))
+		lower := strings.ToLower(sql)
+		if strings.HasPrefix(lower, "alter") || strings.HasPrefix(lower, "create") {
+			alterSQLs = append(alterSQLs, sql)
+		}
+	}
+
+	var tables []OSCTable
+	for _, merged := range ast.MergeAlterTables(alterSQLs...) {
+		tb, err := newOSCTable(strings.TrimSpace(merged), conn.Database)
+		if err != nil {
+			common.Log.Warn("OnlineSchemaChange Error: %s", err.Error())
+			continue
+		}
+		tb.check(conn)
+		tables = append(tables, tb)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].Database != tables[j].Database {
+			return tables[i].Database < tables[j].Database
+		}
+		return tables[i].Table < tables[j].Table
+	})
+	return tables
+}
+
+// newOSCTable 解析合并后的 ALTER TABLE 语句，未指定库名时使用 defaultDB
+func newOSCTable(alterSQL, defaultDB string) (OSCTable, error) {
+	tb := OSCTable{GhOst: true, PtOSC: true}
+	stmts, err := ast.TiParse(strings.TrimSuffix(alterSQL, common.Config.Delimiter), "", "")
+	if err != nil {
+		return tb, err
+	}
+	if len(stmts) == 0 {
+		return tb, fmt.Errorf("not an ALTER TABLE statement: %s", alterSQL)
+	}
+	node, ok := stmts[0].(*tidb.AlterTableStmt)
+	if !ok {
+		return tb, fmt.Errorf("not an ALTER TABLE statement: %s", alterSQL)
+	}
+
+	tb.Database = node.Table.Schema.O
+	if tb.Database == "" {
+		tb.Database = defaultDB
+	}
+	tb.Table = node.Table.Name.O
+	tb.Alter = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(oscAlterPrefixExp.ReplaceAllString(alterSQL, "")),
+		common.Config.Delimiter))
+
+	for _, spec := range node.Specs {
+		if spec.Tp == tidb.AlterTableRenameTable {
+			tb.GhOst, tb.PtOSC = false, false
+			tb.Checks = append(tb.Checks, "变更中包含 RENAME 表操作，gh-ost 和 pt-online-schema-change 均不支持，请单独执行 RENAME TABLE")
+		}
+	}
+	return tb, nil
+}
+
+// check 在线上环境检查工具的前置条件
+func (tb *OSCTable) check(conn *database.Connector) {
+	if conn == nil || common.Config.OnlineDSN.Disable || common.Config.TestDSN.Disable {
+		tb.Checks = append(tb.Checks, "未配置线上环境，未检查主键、触发器及外键等前置条件")
+		return
+	}
+	tmpConn := *conn
+	tmpConn.Database = tb.Database
+
+	// KEY.002 没有主键或唯一键的表无法使用在线变更工具
+	ddl, err := tmpConn.ShowCreateTable(tb.Table)
+	if err != nil {
+		tb.Checks = append(tb.Checks, fmt.Sprintf("获取表结构失败: %s", err.Error()))
+	} else if q, err := NewQuery4Audit(ddl); err == nil {
+		if r := q.RuleNoOSCKey(); r.Item == "KEY.002" {
+			tb.GhOst, tb.PtOSC = false, false
+			tb.Checks = append(tb.Checks, fmt.Sprintf("%s %s", r.Item, r.Summary))
+		}
+	}
+
+	// gh-ost 不支持有触发器的表，pt-osc 需要 MySQL 5.7.2 及以上版本并指定 --preserve-triggers
+	triggers, err := tmpConn.ShowTriggers(tb.Database, tb.Table)
+	if err != nil {
+		tb.Checks = append(tb.Checks, fmt.Sprintf("获取触发器失败: %s", err.Error()))
+	} else if len(triggers) > 0 {
+		tb.GhOst, tb.Triggers = false, true
+		if version, err := tmpConn.Version(); err == nil && version < 50702 {
+			tb.PtOSC = false
+			tb.Checks = append(tb.Checks, fmt.Sprintf("表上存在触发器 %s，gh-ost 不支持，pt-online-schema-change 需要 MySQL 5.7.2 及以上版本",
+				strings.Join(triggers, ", ")))
+		} else {
+			tb.Checks = append(tb.Checks, fmt.Sprintf("表上存在触发器 %s，gh-ost 不支持，pt-online-schema-change 使用 --preserve-triggers 保留触发器",
+				strings.Join(triggers, ", ")))
+		}
+	}
+
+	// gh-ost 不支持外键，pt-osc 在表被其他表引用时需要指定 --alter-foreign-keys-method
+	fks, err := tmpConn.ShowReference(tb.Database, tb.Table)
+	var refs []database.ReferenceValue
+	if err == nil {
+		refs, err = tmpConn.ShowReferenced(tb.Database, tb.Table)
+	}
+	if err != nil {
+		tb.Checks = append(tb.Checks, fmt.Sprintf("获取外键失败: %s", err.Error()))
+	} else if len(fks)+len(refs) > 0 {
+		tb.GhOst = false
+		tb.Referenced = len(refs) > 0
+		var names []string
+		for _, fk := range append(fks, refs...) {
+			names = append(names, fk.ConstraintName)
+		}
+		tb.Checks = append(tb.Checks, fmt.Sprintf("表上存在外键 %s，gh-ost 不支持", strings.Join(names, ", ")))
+	}
+}
+
+// GhOstCommand 生成 gh-ost 命令，密码使用 ******** 代替
+func (tb OSCTable) GhOstCommand(dsn *common.Dsn) string {
+	host, port := oscHostPort(dsn)
+	args := []string{
+		"gh-ost",
+		fmt.Sprintf("--host=%s --port=%s --user=%s --password=%s", host, port, shellQuote(dsn.User), shellQuote("********")),
+		fmt.Sprintf("--database=%s --table=%s", shellQuote(tb.Database), shellQuote(tb.Table)),
+		fmt.Sprintf("--alter=%s", shellQuote(tb.Alter)),
+		"--max-load=Threads_running=25 --critical-load=Threads_running=100 --chunk-size=1000",
+		"--exact-rowcount --concurrent-rowcount --verbose",
+		"--execute",
+	}
+	return strings.Join(args, " \\\n  ")
+}
+
+// PtOSCCommand 生成 pt-online-schema-change 命令，密码使用 ******** 代替
+func (tb OSCTable) PtOSCCommand(dsn *common.Dsn) string {
+	host, port := oscHostPort(dsn)
+	args := []string{
+		"pt-online-schema-change",
+		fmt.Sprintf("--alter=%s", shellQuote(tb.Alter)),
+		shellQuote(fmt.Sprintf("h=%s,P=%s,u=%s,p=********,D=%s,t=%s", host, port, dsn.User, tb.Database, tb.Table)),
+	}
+	if dsn.Charset != "" {
+		args = append(args, fmt.Sprintf("--charset=%s", dsn.Charset))
+	}
+	if tb.Referenced {
+		args = append(args, "--alter-foreign-keys-method=auto")
+	}
+	if tb.Triggers {
+		args = append(args, "--preserve-triggers")
+	}
+	args = append(args, "--max-load=Threads_running=25 --critical-load=Threads_running=100 --chunk-size=1000",
+		"--execute")
+	return strings.Join(args, " \\\n  ")
+}
+
+// FormatOSC 输出在线表结构变更命令，不满足前置条件的工具命令会被注释掉
+func FormatOSC(tables []OSCTable, dsn *common.Dsn) string {
+	var buf []string
+	for _, tb := range tables {
+		buf = append(buf, fmt.Sprintf("# `%s`.`%s`", tb.Database, tb.Table))
+		for _, c := range tb.Checks {
+			buf = append(buf, "# "+c)
+		}
+		buf = append(buf, commentCommand(tb.GhOstCommand(dsn), !tb.GhOst))
+		buf = append(buf, commentCommand(tb.PtOSCCommand(dsn), !tb.PtOSC))
+		buf = append(buf, "")
+	}
+	return strings.TrimSpace(strings.Join(buf, "\n"))
+}
+
+func commentCommand(cmd string, comment bool) string {
+	if !comment {
+		return cmd
+	}
+	return "# " + strings.Replace(cmd, "\n", "\n# ", -1)
+}
+
+// oscHostPort 从 DSN 中获取主机和端口
+func oscHostPort(dsn *common.Dsn) (string, string) {
+	host, port, err := net.SplitHostPort(dsn.Addr)
+	if err != nil {
+		return dsn.Addr, "3306"
+	}
+	return host, port
+}
+
+// shellQuote 使用单引号包裹 shell 参数，防止反引号等字符被 shell 解释
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestOnlineSchemaChange(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	buf := "alter table film add column c int comment 'it''s';\n" +
+		"create index idx_c on film(c);\n" +
+		"alter table sakila.actor rename to actor_new;\n" +
+		"alter table `sakila`.`city` drop foreign key fk_city_country;"
+	tables := OnlineSchemaChange(rEnv, buf)
+	if len(tables) != 3 {
+		t.Errorf("got %d tables, want 3", len(tables))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatOSC(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := []string{
+		"ALTER TABLE `sakila`.`film` add column c int comment 'it''s', ADD INDEX idx_c (c) ;",
+		"ALTER TABLE `sakila`.`actor` rename to actor_new ;",
+		"ALTER TABLE city drop foreign key fk_city_country ;",
+		"ALTER TABLE payment add column c int ;",
+	}
+	var tables []OSCTable
+	for _, sql := range sqls {
+		tb, err := newOSCTable(sql, "sakila")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		tables = append(tables, tb)
+	}
+	tables[2].GhOst = false
+	tables[2].Referenced = true
+	tables[2].Checks = append(tables[2].Checks, "表上存在外键 fk_address_city, fk_city_country，gh-ost 不支持")
+	tables[3].GhOst = false
+	tables[3].Triggers = true
+	tables[3].Checks = append(tables[3].Checks, "表上存在触发器 payment_date，gh-ost 不支持，pt-online-schema-change 使用 --preserve-triggers 保留触发器")
+
+	dsn := &common.Dsn{User: "root", Password: "secret", Addr: "127.0.0.1:3306", Charset: "utf8mb4"}
+	err := common.GoldenDiff(func() {
+		fmt.Println(FormatOSC(tables, dsn))
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"github.com/percona/go-mysql/query"
+)
+
+// PlanBaseline 一条 SQL 的执行计划基线
+type PlanBaseline struct {
+	Query       string   `json:"query"`       // SQL 指纹
+	Fingerprint string   `json:"fingerprint"` // 执行计划指纹
+	Plan        []string `json:"plan"`        // 按连接顺序每张表的 select_type, table, type, key
+	Rows        int64    `json:"rows"`        // 预估扫描行数之和
+	Cost        float64  `json:"cost"`        // 预估代价，没有代价信息时为 0
+}
+
+// PlanBaselines 执行计划基线，key 为 SQL 指纹 ID
+type PlanBaselines map[string]PlanBaseline
+
+// treeOperationDetailExp FORMAT=TREE 迭代器描述中的过滤条件、索引范围、排序列等信息，可能包含 SQL 中的常量
+// 如：Filter: (film.length > 100), Index range scan on film using idx_length over (100 < length)
+var treeOperationDetailExp = regexp.MustCompile(`(:\s.*| over \(.*|\s*\(.*)$`)
+
+// PlanFingerprint 根据每张表的访问类型、使用的索引、连接顺序及 select_type 生成执行计划指纹
+// 扫描行数及代价会随数据量变化，不参与指纹计算
+func PlanFingerprint(exp *database.ExplainInfo) string {
+	return query.Id(strings.Join(planSignature(exp), "\n"))
+}
+
+// planSignature 执行计划中与数据量无关的部分，每张表或每个迭代器一行
+func planSignature(exp *database.ExplainInfo) []string {
+	var plan []string
+	if exp.ExplainTree != nil {
+		// FORMAT=TREE 中只保留迭代器类型、表名及索引，去掉代价、行数及条件信息
+		exp.ExplainTree.Walk(func(node *database.ExplainNode, depth int) {
+			plan = append(plan, strings.Repeat("  ", depth)+treeOperationDetailExp.ReplaceAllString(node.Operation, ""))
+		})
+		return plan
+	}
+	for _, row := range explainCompareRows(exp) {
+		plan = append(plan, fmt.Sprintf("%d %s %s %s %s", row.ID, row.SelectType, row.TableName,
+			row.AccessType, explainCompareKey(row.Key)))
+	}
+	return plan
+}
+
+// planRowsCost 预估扫描行数之和及预估代价
+func planRowsCost(exp *database.ExplainInfo) (int64, float64) {
+	var rows int64
+	cost := exp.QueryCost
+	if exp.ExplainTree != nil {
+		exp.ExplainTree.Walk(func(node *database.ExplainNode, depth int) {
+			// 只统计叶子节点，即真正读取数据的迭代器
+			if len(node.Children) == 0 {
+				rows += int64(node.Rows)
+			}
+		})
+		if cost == 0 {
+			cost = exp.ExplainTree.Cost
+		}
+		return rows, cost
+	}
+	for _, row := range explainCompareRows(exp) {
+		rows += row.Rows
+	}
+	if cost == 0 && exp.ExplainFormat == database.JSONFormatExplain && exp.ExplainJSON != nil {
+		cost, _ = strconv.ParseFloat(exp.ExplainJSON.QueryBlock.CostInfo.QueryCost, 64)
+	}
+	return rows, cost
+}
+
+// NewPlanBaseline 根据 EXPLAIN 信息生成执行计划基线
+func NewPlanBaseline(sql string, exp *database.ExplainInfo) PlanBaseline {
+	rows, cost := planRowsCost(exp)
+	return PlanBaseline{
+		Query:       query.Fingerprint(sql),
+		Fingerprint: PlanFingerprint(exp),
+		Plan:        planSignature(exp),
+		Rows:        rows,
+		Cost:        cost,
+	}
+}
+
+// LoadPlanBaselines 读取执行计划基线文件，文件不存在时返回空的基线
+func LoadPlanBaselines(file string) (PlanBaselines, error) {
+	baselines := make(PlanBaselines)
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return baselines, nil
+		}
+		return baselines, err
+	}
+	err = json.Unmarshal(buf, &baselines)
+	return baselines, err
+}
+
+// Save 保存执行计划基线文件
+func (baselines PlanBaselines) Save(file string) error {
+	buf, err := json.MarshalIndent(baselines, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0644)
+}
+
+// Check 与基线对比执行计划，指纹不同或扫描行数、代价相差超过 plan-max-drift 倍时返回 EXP.008
+func (baselines PlanBaselines) Check(id string, current PlanBaseline) Rule {
+	baseline, ok := baselines[id]
+	if !ok {
+		return Rule{}
+	}
+
+	var severity string
+	var buf []string
+	if baseline.Fingerprint != current.Fingerprint {
+		severity = "L3"
+		buf = append(buf, fmt.Sprintf("* 执行计划指纹由 %s 变为 %s", baseline.Fingerprint, current.Fingerprint))
+	}
+	if planDrift(float64(baseline.Rows), float64(current.Rows)) {
+		if severity == "" {
+			severity = "L1"
+		}
+		buf = append(buf, fmt.Sprintf("* 预估扫描行数由 %d 变为 %d", baseline.Rows, current.Rows))
+	}
+	if baseline.Cost > 0 && current.Cost > 0 && planDrift(baseline.Cost, current.Cost) {
+		if severity == "" {
+			severity = "L1"
+		}
+		buf = append(buf, fmt.Sprintf("* 预估代价由 %.2f 变为 %.2f", baseline.Cost, current.Cost))
+	}
+	if len(buf) == 0 {
+		return Rule{}
+	}
+	if baseline.Fingerprint != current.Fingerprint {
+		buf = append(buf, "", "```text", "-- baseline")
+		buf = append(buf, baseline.Plan...)
+		buf = append(buf, "-- current")
+		buf = append(buf, current.Plan...)
+		buf = append(buf, "```")
+	}
+
+	return Rule{
+		Item:     "EXP.008",
+		Severity: severity,
+		Summary:  "执行计划与基线不一致",
+		Content:  "与 -plan-baseline 中保存的执行计划相比，访问类型、索引、连接顺序或 select_type 发生变化会导致执行计划指纹不同，扫描行数及代价的变化超过 plan-max-drift 倍时同样给出提醒。请确认变化是否符合预期，确认后可以使用 -save-plans 更新基线。",
+		Case:     strings.Join(buf, "\n"),
+		Func:     (*Query4Audit).RuleOK,
+	}
+}
+
+// planDrift 两个值相差超过 plan-max-drift 倍
+func planDrift(a, b float64) bool {
+	if common.Config.PlanMaxDrift <= 1 {
+		return false
+	}
+	if a < 1 {
+		a = 1
+	}
+	if b < 1 {
+		b = 1
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a/b > common.Config.PlanMaxDrift
+}
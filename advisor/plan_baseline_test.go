@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"github.com/kr/pretty"
+)
+
+var planBaselineExp = []string{
+	`+----+-------------+---------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+-------+
+| id | select_type | table   | partitions | type | possible_keys     | key               | key_len | ref                       | rows | filtered | Extra |
++----+-------------+---------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+-------+
+|  1 | SIMPLE      | country | NULL       | ALL  | PRIMARY           | NULL              | NULL    | NULL                      |  109 |   100.00 | NULL  |
+|  1 | SIMPLE      | city    | NULL       | ref  | idx_fk_country_id | idx_fk_country_id | 2       | sakila.country.country_id |    5 |   100.00 | NULL  |
++----+-------------+---------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+-------+`,
+	// 数据量增长，执行计划不变
+	`+----+-------------+---------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+-------+
+| id | select_type | table   | partitions | type | possible_keys     | key               | key_len | ref                       | rows | filtered | Extra |
++----+-------------+---------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+-------+
+|  1 | SIMPLE      | country | NULL       | ALL  | PRIMARY           | NULL              | NULL    | NULL                      | 1090 |   100.00 | NULL  |
+|  1 | SIMPLE      | city    | NULL       | ref  | idx_fk_country_id | idx_fk_country_id | 2       | sakila.country.country_id |    5 |   100.00 | NULL  |
++----+-------------+---------+------------+------+-------------------+-------------------+---------+---------------------------+------+----------+-------+`,
+	// 连接顺序及访问类型变化
+	`+----+-------------+---------+------------+--------+-------------------+---------+---------+------------------------+------+----------+-------------+
+| id | select_type | table   | partitions | type   | possible_keys     | key     | key_len | ref                    | rows | filtered | Extra       |
++----+-------------+---------+------------+--------+-------------------+---------+---------+------------------------+------+----------+-------------+
+|  1 | SIMPLE      | city    | NULL       | ALL    | idx_fk_country_id | NULL    | NULL    | NULL                   |  600 |   100.00 | Using where |
+|  1 | SIMPLE      | country | NULL       | eq_ref | PRIMARY           | PRIMARY | 2       | sakila.city.country_id |    1 |   100.00 | NULL        |
++----+-------------+---------+------------+--------+-------------------+---------+---------+------------------------+------+----------+-------------+`,
+}
+
+func TestPlanBaseline(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sql := "select * from city join country using(country_id)"
+	var plans []PlanBaseline
+	for _, text := range planBaselineExp {
+		exp, err := database.ParseExplainText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plans = append(plans, NewPlanBaseline(sql, exp))
+	}
+	if plans[0].Fingerprint != plans[1].Fingerprint || plans[0].Fingerprint == plans[2].Fingerprint {
+		t.Errorf("got plans: %s", pretty.Sprint(plans))
+	}
+
+	dir, err := ioutil.TempDir("", "soar-plan-baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "plans.json")
+
+	baselines, err := LoadPlanBaselines(file)
+	if err != nil || len(baselines) != 0 {
+		t.Errorf("got baselines: %s, err: %v", pretty.Sprint(baselines), err)
+	}
+	baselines["B95017DB61875675"] = plans[0]
+	if err = baselines.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	baselines, err = LoadPlanBaselines(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = common.GoldenDiff(func() {
+		buf, _ := ioutil.ReadFile(file)
+		fmt.Print(string(buf))
+		for _, plan := range plans {
+			r := baselines.Check("B95017DB61875675", plan)
+			fmt.Println(r.Item, r.Severity, r.Summary)
+			fmt.Println(r.Case)
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if r := baselines.Check("0000000000000000", plans[2]); r.Item != "" {
+		t.Errorf("no baseline got rule: %s", pretty.Sprint(r))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestPlanSignatureTree(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	treeExp := []string{
+		`-> Nested loop inner join  (cost=1.20 rows=1)
+    -> Filter: (film.length > 100)  (cost=0.85 rows=1)
+        -> Index range scan on film using idx_length over (100 < length)  (cost=0.85 rows=1)
+    -> Single-row index lookup on language using PRIMARY (language_id=film.language_id)  (cost=0.35 rows=1)`,
+		// 常量不同，执行计划相同
+		`-> Nested loop inner join  (cost=90.50 rows=600)
+    -> Filter: (film.length > 50)  (cost=60.25 rows=600)
+        -> Index range scan on film using idx_length over (50 < length)  (cost=60.25 rows=600)
+    -> Single-row index lookup on language using PRIMARY (language_id=film.language_id)  (cost=0.25 rows=1)`,
+		// 访问方式变化
+		`-> Nested loop inner join  (cost=250.25 rows=1000)
+    -> Filter: (film.length > 10)  (cost=103.00 rows=1000)
+        -> Table scan on film  (cost=103.00 rows=1000)
+    -> Single-row index lookup on language using PRIMARY (language_id=film.language_id)  (cost=0.25 rows=1)`,
+	}
+	var fingerprints []string
+	err := common.GoldenDiff(func() {
+		for _, text := range treeExp {
+			exp, err := database.ParseExplainText(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fingerprints = append(fingerprints, PlanFingerprint(exp))
+			fmt.Println(strings.Join(planSignature(exp), "\n"))
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	if fingerprints[0] != fingerprints[1] || fingerprints[0] == fingerprints[2] {
+		t.Errorf("got fingerprints: %v", fingerprints)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// ProfilingAdvisor 根据 performance_schema 中的语句统计信息给出建议，PRO.001 为完整的 Profiling 信息
+func ProfilingAdvisor(p *database.StatementProfiling) map[string]Rule {
+	proRules := map[string]Rule{
+		"PRO.001": {
+			Item:     "PRO.001",
+			Severity: "L0",
+			Content:  database.FormatStatementProfiling(p),
+		},
+	}
+
+	if common.Config.MaxRowsExamined > 0 && p.RowsExamined > common.Config.MaxRowsExamined {
+		proRules["PRO.002"] = Rule{
+			Item:     "PRO.002",
+			Severity: "L2",
+			Summary:  "实际扫描行数过多",
+			Content:  fmt.Sprintf("SQL 执行时实际扫描的行数超过了 %d 行，扫描行数远大于返回行数时说明过滤条件没有用上合适的索引。", common.Config.MaxRowsExamined),
+			Case:     fmt.Sprintf("* rows_examined: %d\n* rows_sent: %d", p.RowsExamined, p.RowsSent),
+		}
+	}
+
+	if p.TmpDiskTables > 0 {
+		proRules["PRO.003"] = Rule{
+			Item:     "PRO.003",
+			Severity: "L2",
+			Summary:  "使用了磁盘临时表",
+			Content:  "内存临时表超过 tmp_table_size 或 max_heap_table_size，或者临时表中包含 BLOB/TEXT 列时会转为磁盘临时表，严重影响性能。建议为 GROUP BY, DISTINCT, UNION 等操作添加合适的索引，或者减少查询返回的列。",
+			Case:     fmt.Sprintf("* tmp_tables: %d\n* tmp_disk_tables: %d", p.TmpTables, p.TmpDiskTables),
+		}
+	}
+
+	if p.SortMergePasses > 0 {
+		proRules["PRO.004"] = Rule{
+			Item:     "PRO.004",
+			Severity: "L1",
+			Summary:  "排序时进行了多次归并",
+			Content:  "sort_buffer_size 不足以容纳需要排序的数据时会使用磁盘文件进行多次归并排序。建议使用索引避免排序，减少参与排序的行数或列，或者适当调大 sort_buffer_size。",
+			Case:     fmt.Sprintf("* sort_merge_passes: %d", p.SortMergePasses),
+		}
+	}
+
+	if p.SelectScan > 0 || p.SelectFullJoin > 0 {
+		severity := "L1"
+		content := "SQL 执行时对第一张表进行了全表扫描，请确认过滤条件是否可以使用索引。"
+		if p.SelectFullJoin > 0 {
+			// 关联查询中被驱动表没有索引，每一行都需要全表扫描被驱动表
+			severity = "L3"
+			content = "关联查询中被驱动表没有使用索引，驱动表的每一行都需要对被驱动表进行全表扫描。建议为关联条件中的列添加索引。"
+		}
+		proRules["PRO.005"] = Rule{
+			Item:     "PRO.005",
+			Severity: severity,
+			Summary:  "执行时发生了全表扫描",
+			Content:  content,
+			Case:     fmt.Sprintf("* select_scan: %d\n* select_full_join: %d\n* no_index_used: %v", p.SelectScan, p.SelectFullJoin, p.NoIndexUsed),
+		}
+	}
+
+	if common.Config.MaxLockTime > 0 && p.LockTime > common.Config.MaxLockTime {
+		proRules["PRO.006"] = Rule{
+			Item:     "PRO.006",
+			Severity: "L1",
+			Summary:  "锁等待时间过长",
+			Content:  fmt.Sprintf("SQL 执行时等待锁的时间超过了 %s 秒，测试环境中可能有其他会话持有相关的锁。", formatTraceNumber(common.Config.MaxLockTime)),
+			Case:     fmt.Sprintf("* lock_time: %f\n* duration: %f", p.LockTime, p.Duration),
+		}
+	}
+	return proRules
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestProfilingAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	profilings := []*database.StatementProfiling{
+		// select 1
+		{Duration: 0.0002, RowsSent: 1},
+		// select * from film f join film_actor fa on f.title = fa.last_update order by f.description
+		{
+			Duration:        1.5,
+			LockTime:        0.2,
+			RowsExamined:    5463000,
+			RowsSent:        0,
+			TmpTables:       1,
+			TmpDiskTables:   1,
+			SortMergePasses: 3,
+			SelectScan:      1,
+			SelectFullJoin:  1,
+			NoIndexUsed:     true,
+		},
+	}
+	err := common.GoldenDiff(func() {
+		for _, p := range profilings {
+			rules := ProfilingAdvisor(p)
+			var items []string
+			for item := range rules {
+				items = append(items, item)
+			}
+			sort.Strings(items)
+			for _, item := range items {
+				if item == "PRO.001" {
+					fmt.Println(item)
+					continue
+				}
+				fmt.Println(rules[item].Item, rules[item].Severity, rules[item].Summary)
+				fmt.Println(rules[item].Content)
+				fmt.Println(rules[item].Case)
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
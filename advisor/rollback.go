@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// Rollback 为输入的 DDL 语句生成回滚语句，回滚语句按与输入相反的顺序输出
+// 删除、修改等会丢失信息的操作使用线上环境中变更前的表结构生成回滚语句，无法精确回滚时输出警告
+// 前面语句的变更会应用到内存中的表结构上，后面的语句基于变更后的表结构生成回滚语句
+func Rollback(conn *database.Connector, buf string) string {
+	common.Log.Debug("Enter:  Rollback, Caller: %s", common.Caller())
+	showCreate := func(db, table string) string {
+		if conn == nil || common.Config.OnlineDSN.Disable || common.Config.TestDSN.Disable {
+			return ""
+		}
+		tmpConn := *conn
+		if db != "" {
+			tmpConn.Database = db
+		}
+		create, err := tmpConn.ShowCreateTableRaw(table)
+		if err != nil {
+			common.Log.Debug("Rollback ShowCreateTableRaw Error: %s", err.Error())
+			return ""
+		}
+		return create
+	}
+
+	var blocks []string
+	ddlRollback := ast.NewDDLRollback(showCreate)
+	for buf = strings.TrimSpace(buf); buf != ""; {
+		_, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(common.Config.Delimiter))
+		if len(bufBytes) == len(buf) {
+			buf = ""
+		} else {
+			buf = string(bufBytes)
+		}
+		sql = strings.TrimSpace(database.RemoveSQLComments(sql))
+		if sql == "" {
+			continue
+		}
+
+		block := []string{"-- 回滚: " + ast.Compress(sql)}
+		rollback, warnings, err := ddlRollback.Rollback(sql)
+		if err != nil {
+			block = append(block, "-- 错误: "+err.Error())
+		}
+		for _, w := range warnings {
+			block = append(block, "-- 警告: "+w)
+		}
+		block = append(block, rollback...)
+		// 回滚时后执行的语句先回滚
+		blocks = append([]string{strings.Join(block, "\n")}, blocks...)
+	}
+	return strings.Join(blocks, "\n\n")
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestRollback(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	conn := *rEnv
+	conn.Database = "sakila"
+	buf := "ALTER TABLE film DROP COLUMN rating; CREATE TABLE t1 (id int primary key);"
+	rollback := Rollback(&conn, buf)
+	// 后执行的语句先回滚
+	if strings.Index(rollback, "DROP TABLE `t1`") > strings.Index(rollback, "ADD COLUMN `rating`") {
+		t.Errorf("got rollback: %s", rollback)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -181,6 +181,14 @@ func InitHeuristicRules() {
 			Case:     "ALTER TABLE tbl DROP PRIMARY KEY;",
 			Func:     (*Query4Audit).RuleAlterDropKey,
 		},
+		"ALT.005": {
+			Item:     "ALT.005",
+			Severity: "L0",
+			Summary:  "ALTER TABLE 执行方式及影响评估",
+			Content:  `根据 MySQL 版本评估每个 ALTER TABLE 子句的执行方式（INSTANT, INPLACE, COPY）、需要的锁级别及是否重建表，并结合表的行数和大小估算耗时及风险。COPY 方式或需要重建的大表变更会长时间占用 IO 并导致主从延迟，建议使用 gh-ost 或 pt-online-schema-change 等在线表结构变更工具。`,
+			Case:     "ALTER TABLE tbl MODIFY COLUMN col bigint;",
+			Func:     (*Query4Audit).RuleOK, // 该建议在 AlterImpactAdvise 中给
+		},
 		"ARG.001": {
 			Item:     "ARG.001",
 			Severity: "L4",
@@ -1018,6 +1026,14 @@ func InitHeuristicRules() {
 			Case:     "UPDATE category SET name='ActioN', last_update=last_update WHERE category_id=1",
 			Func:     (*Query4Audit).RuleOK, // 该建议在indexAdvisor中给 RuleUpdateOnUpdate
 		},
+		"RES.012": {
+			Item:     "RES.012",
+			Severity: "L0",
+			Summary:  "DML 语句影响行数评估",
+			Content:  `将 UPDATE, DELETE, INSERT ... SELECT 改写为 SELECT 后通过 EXPLAIN 预估影响行数，配置 affected-count-limit 后使用 COUNT(*) 统计影响行数，并与表的总行数比较。影响行数占大表总行数的比例超过 max-affected-ratio 时会长时间持有大量行锁并产生大事务，导致锁等待和主从延迟，建议按主键范围分批执行。`,
+			Case:     "DELETE FROM film WHERE length > 0",
+			Func:     (*Query4Audit).RuleOK, // 该建议在 AffectedRowsAdvise 中给
+		},
 		"SEC.001": {
 			Item:     "SEC.001",
 			Severity: "L0",
@@ -1316,7 +1332,7 @@ func FormatSuggest(sql string, currentDB string, format string, suggests ...map[
 			}
 		}
 
-	case "markdown", "html", "explain-digest", "duplicate-key-checker":
+	case "markdown", "html", "explain-digest", "duplicate-key-checker", "unused-index":
 		if sql != "" && len(suggest) > 0 {
 			switch common.Config.ExplainSQLReportType {
 			case "fingerprint":
@@ -1386,7 +1402,13 @@ func FormatSuggest(sql string, currentDB string, format string, suggests ...map[
 			buf = append(buf, "## Profiling信息\n")
 		}
 		for _, item := range sortedProfilingSuggest {
+			if item != "PRO.001" {
+				buf = append(buf, fmt.Sprintln("### ", suggest[item].Summary))
+			}
 			buf = append(buf, fmt.Sprintln(suggest[item].Content))
+			if suggest[item].Case != "" {
+				buf = append(buf, fmt.Sprint(suggest[item].Case, "\n"))
+			}
 			delete(suggest, item)
 		}
 
@@ -1403,7 +1425,13 @@ func FormatSuggest(sql string, currentDB string, format string, suggests ...map[
 			buf = append(buf, "## Trace信息\n")
 		}
 		for _, item := range sortedTraceSuggest {
+			if item != "TRA.001" {
+				buf = append(buf, fmt.Sprintln("### ", suggest[item].Summary))
+			}
 			buf = append(buf, fmt.Sprintln(suggest[item].Content))
+			if suggest[item].Case != "" {
+				buf = append(buf, fmt.Sprint(suggest[item].Case, "\n"))
+			}
 			delete(suggest, item)
 		}
 
@@ -1429,9 +1457,12 @@ func FormatSuggest(sql string, currentDB string, format string, suggests ...map[
 			}
 			buf = append(buf, fmt.Sprintln("* **Content:** ", common.MarkdownEscape(suggest[item].Content)))
 
-			if format == "duplicate-key-checker" {
+			switch format {
+			case "duplicate-key-checker":
 				buf = append(buf, fmt.Sprintf("* **原建表语句:** \n```sql\n%s\n```\n", suggest[item].Case), "\n\n")
-			} else {
+			case "unused-index":
+				buf = append(buf, fmt.Sprintf("* **删除语句:** \n```sql\n%s\n```\n", suggest[item].Case), "\n\n")
+			default:
 				buf = append(buf, fmt.Sprint("* **Case:** ", common.MarkdownEscape(suggest[item].Case), "\n\n"))
 			}
 		}
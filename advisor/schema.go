@@ -0,0 +1,393 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+)
+
+// SchemaAuditReport 一个数据库的表结构审计结果
+type SchemaAuditReport struct {
+	Database string               `json:"Database"` // 数据库名
+	Tables   int                  `json:"Tables"`   // 审计的表数量
+	Findings []SchemaAuditFinding `json:"Findings"` // 按严重程度排序的审计结果
+}
+
+// SchemaAuditFinding 同一条规则在多张表上的审计结果
+type SchemaAuditFinding struct {
+	Item     string   `json:"Item"`     // 规则代号
+	Severity string   `json:"Severity"` // 危险等级
+	Summary  string   `json:"Summary"`  // 规则摘要
+	Content  string   `json:"Content"`  // 规则解释或具体的检查结果
+	Tables   []string `json:"Tables"`   // 命中规则的表
+}
+
+// SchemaAudit 对指定数据库中的所有表进行表结构审计
+// 对 SHOW CREATE TABLE 的结果执行所有启发式规则，并补充重复索引、外键检查
+func SchemaAudit(conn *database.Connector, databases ...string) []SchemaAuditReport {
+	common.Log.Debug("Enter:  SchemaAudit, Caller: %s", common.Caller())
+	// 复制一份online connector,防止环境切换影响其他功能的使用
+	tmpOnline := *conn
+	var reports []SchemaAuditReport
+
+	// 不指定 DB 的时候检查 online dsn 中的 DB
+	if len(databases) == 0 {
+		databases = append(databases, tmpOnline.Database)
+	}
+
+	for _, db := range databases {
+		tmpOnline.Database = db
+		report := SchemaAuditReport{Database: db}
+		var results []auditResult
+
+		tables, err := tmpOnline.ShowTables()
+		if err != nil {
+			results = append(results, auditResult{Rule: RuleMySQLError("ERR.003", err)})
+		}
+
+		for _, tb := range tables {
+			if tmpOnline.IsView(tb) {
+				continue
+			}
+			report.Tables++
+			results = append(results, auditTable(&tmpOnline, db, tb)...)
+		}
+
+		report.Findings = mergeAuditResults(results)
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// auditResult 单张表命中的一条规则
+type auditResult struct {
+	Table string
+	Rule  Rule
+}
+
+// autoIncrementExp SHOW CREATE TABLE 中的 AUTO_INCREMENT 是当前的自增值，不是建表时的初始值
+var autoIncrementExp = regexp.MustCompile(`(?i)\s+AUTO_INCREMENT\s*=\s*\d+`)
+
+// auditTable 对单张表进行审计
+func auditTable(conn *database.Connector, db, tb string) []auditResult {
+	var results []auditResult
+	funcErrCheck := func(err error) {
+		if err != nil {
+			results = append(results, auditResult{Table: tb, Rule: RuleMySQLError("ERR.003", err)})
+		}
+	}
+
+	// 启发式规则
+	ddl, err := conn.ShowCreateTable(tb)
+	if err != nil {
+		funcErrCheck(err)
+		return results
+	}
+	ddl = autoIncrementExp.ReplaceAllString(ddl, "")
+	q, err := NewQuery4Audit(ddl)
+	if err != nil {
+		results = append(results, auditResult{Table: tb, Rule: RuleMySQLError("ERR.000", err)})
+	} else {
+		for item, rule := range HeuristicRules {
+			if item == "OK" || IsIgnoreRule(item) {
+				continue
+			}
+			if r := rule.Func(q); r.Item == item {
+				results = append(results, auditResult{Table: tb, Rule: r})
+			}
+		}
+	}
+
+	// ShowCreateTable 会去除外键，外键检查单独进行
+	fks, err := conn.ShowReference(db, tb)
+	funcErrCheck(err)
+	var fkCols []fkIndexCols
+	var fkContent []string
+	for _, fk := range fks {
+		fkCols = append(fkCols, fkIndexCols{Name: fk.ConstraintName, Columns: fk.Columns})
+		fkContent = append(fkContent, fmt.Sprintf("%s(%s) REFERENCES %s.%s(%s)", fk.ConstraintName,
+			strings.Join(fk.Columns, ", "), fk.ReferencedTableSchema, fk.ReferencedTableName,
+			strings.Join(fk.ReferencedColumns, ", ")))
+	}
+	refs, err := conn.ShowReferenced(db, tb)
+	funcErrCheck(err)
+	for _, fk := range refs {
+		fkCols = append(fkCols, fkIndexCols{Name: fk.ConstraintName, Columns: fk.ReferencedColumns})
+	}
+	if len(fkContent) > 0 && !IsIgnoreRule("KEY.003") {
+		rule := HeuristicRules["KEY.003"]
+		rule.Content = fmt.Sprintf("表中存在外键: %s;", strings.Join(fkContent, "; "))
+		results = append(results, auditResult{Table: tb, Rule: rule})
+	}
+
+	// 重复索引检查
+	if !IsIgnoreRule("IDX.001") {
+		idxInfo, err := conn.ShowIndex(tb)
+		funcErrCheck(err)
+		isInnoDB := false
+		if status, err := conn.ShowTableStatus(tb); err == nil && len(status.Rows) > 0 {
+			isInnoDB = strings.EqualFold(string(status.Rows[0].Engine), "InnoDB")
+		}
+		if content, _ := duplicateKeys(idxInfo, isInnoDB, fkCols); content != "" {
+			results = append(results, auditResult{Table: tb, Rule: Rule{
+				Item:     "IDX.001",
+				Severity: "L2",
+				Summary:  "存在重复的索引",
+				Content:  content,
+			}})
+		}
+	}
+	return results
+}
+
+// mergeAuditResults 将相同规则且内容相同的结果合并，按严重程度从高到低排序
+func mergeAuditResults(results []auditResult) []SchemaAuditFinding {
+	var findings []SchemaAuditFinding
+	merged := make(map[string]int)
+	for _, r := range results {
+		key := r.Rule.Item + r.Rule.Content
+		if i, ok := merged[key]; ok {
+			if r.Table != "" {
+				findings[i].Tables = append(findings[i].Tables, r.Table)
+			}
+			continue
+		}
+		finding := SchemaAuditFinding{
+			Item:     r.Rule.Item,
+			Severity: r.Rule.Severity,
+			Summary:  r.Rule.Summary,
+			Content:  r.Rule.Content,
+		}
+		if r.Table != "" {
+			finding.Tables = []string{r.Table}
+		}
+		merged[key] = len(findings)
+		findings = append(findings, finding)
+	}
+
+	for _, f := range findings {
+		sort.Strings(f.Tables)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		si, _ := strconv.Atoi(strings.TrimPrefix(findings[i].Severity, "L"))
+		sj, _ := strconv.Atoi(strings.TrimPrefix(findings[j].Severity, "L"))
+		if si != sj {
+			return si > sj
+		}
+		if findings[i].Item != findings[j].Item {
+			return findings[i].Item < findings[j].Item
+		}
+		return strings.Join(findings[i].Tables, ",") < strings.Join(findings[j].Tables, ",")
+	})
+	return findings
+}
+
+// FormatSchemaAudit 格式化输出表结构审计报告，支持 markdown, html, json, lint, text
+func FormatSchemaAudit(reports []SchemaAuditReport, format string) string {
+	var buf []string
+	switch format {
+	case "json":
+		js, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			common.Log.Error("FormatSchemaAudit json.Marshal Error: %v", err)
+		}
+		return string(js)
+
+	case "lint":
+		for _, report := range reports {
+			for _, f := range report.Findings {
+				if len(f.Tables) == 0 {
+					buf = append(buf, fmt.Sprintf("%s %s %s", report.Database, f.Item, f.Summary))
+				}
+				for _, tb := range f.Tables {
+					buf = append(buf, fmt.Sprintf("%s.%s %s %s", report.Database, tb, f.Item, f.Summary))
+				}
+			}
+		}
+
+	case "text":
+		for _, report := range reports {
+			buf = append(buf, fmt.Sprintln("Database: ", report.Database))
+			buf = append(buf, fmt.Sprintln("Tables: ", report.Tables))
+			for _, f := range report.Findings {
+				buf = append(buf, fmt.Sprintln("Item: ", f.Item))
+				buf = append(buf, fmt.Sprintln("Severity: ", f.Severity))
+				buf = append(buf, fmt.Sprintln("Summary: ", f.Summary))
+				buf = append(buf, fmt.Sprintln("Tables: ", strings.Join(f.Tables, ", ")))
+				buf = append(buf, fmt.Sprintln("Content: ", f.Content))
+			}
+		}
+
+	case "html":
+		return common.Markdown2HTML(FormatSchemaAudit(reports, "markdown"))
+
+	default:
+		for _, report := range reports {
+			buf = append(buf, fmt.Sprintf("# 数据库 %s 表结构审计\n", report.Database))
+			buf = append(buf, fmt.Sprintf("共审计 %d 张表，发现 %d 类问题\n", report.Tables, len(report.Findings)))
+			for _, f := range report.Findings {
+				buf = append(buf, fmt.Sprintln("##", common.MarkdownEscape(f.Summary)))
+				buf = append(buf, fmt.Sprintln("* **Item:** ", f.Item))
+				buf = append(buf, fmt.Sprintln("* **Severity:** ", f.Severity))
+				if len(f.Tables) > 0 {
+					buf = append(buf, fmt.Sprintln("* **Tables:** ", common.MarkdownEscape(strings.Join(f.Tables, ", "))))
+				}
+				buf = append(buf, fmt.Sprintln("* **Content:** ", common.MarkdownEscape(f.Content)))
+			}
+		}
+	}
+	return strings.Join(buf, "\n")
+}
+
+// SchemaDiff 比较 OnlineDsn 与 source 中的表结构，生成将 OnlineDsn 中的表结构变更为 source 中表结构的 DDL
+// source 可以是另一个 DSN，也可以是存放建表语句的目录（*.sql）
+// 生成的 DDL 会再经过 ALT, COL, KEY 类启发式规则检查，检查结果以注释形式输出在对应语句之前
+func SchemaDiff(conn *database.Connector, source string) (string, error) {
+	common.Log.Debug("Enter:  SchemaDiff, Caller: %s", common.Caller())
+	if source == "" {
+		return "", fmt.Errorf("schema-diff-source is empty, please specify a DSN or a schema directory")
+	}
+
+	current, err := showCreateTables(conn)
+	if err != nil {
+		return "", err
+	}
+
+	var desired map[string]string
+	if fi, err := os.Stat(source); err == nil && fi.IsDir() {
+		desired, err = readCreateTables(source)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		srcConn, err := database.NewConnector(common.ParseDSN(source, nil))
+		if err != nil {
+			return "", err
+		}
+		defer srcConn.Conn.Close()
+		desired, err = showCreateTables(srcConn)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	ddl, err := ast.DiffCreateTables(conn.Database, current, desired)
+	if err != nil {
+		return "", err
+	}
+
+	var buf []string
+	for _, sql := range ddl {
+		for _, r := range checkSchemaDiffDDL(sql) {
+			buf = append(buf, fmt.Sprintf("-- %s %s %s", r.Item, r.Severity, r.Summary))
+		}
+		buf = append(buf, sql)
+	}
+	return strings.Join(buf, "\n"), nil
+}
+
+// showCreateTables 获取数据库中所有表的建表语句，保留外键，不包含视图
+func showCreateTables(conn *database.Connector) (map[string]string, error) {
+	creates := make(map[string]string)
+	tables, err := conn.ShowTables()
+	if err != nil {
+		return creates, err
+	}
+	for _, tb := range tables {
+		if conn.IsView(tb) {
+			continue
+		}
+		ddl, err := conn.ShowCreateTableRaw(tb)
+		if err != nil {
+			return creates, err
+		}
+		creates[tb] = ddl
+	}
+	return creates, nil
+}
+
+// readCreateTables 读取目录中所有 .sql 文件中的建表语句，其他语句会被忽略
+func readCreateTables(dir string) (map[string]string, error) {
+	creates := make(map[string]string)
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return creates, err
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return creates, err
+		}
+		buf := strings.TrimSpace(string(data))
+		for buf != "" {
+			_, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(common.Config.Delimiter))
+			if len(bufBytes) == len(buf) {
+				buf = ""
+			} else {
+				buf = string(bufBytes)
+			}
+
+			sql = database.RemoveSQLComments(sql)
+			q, err := NewQuery4Audit(sql)
+			if err != nil || len(q.TiStmt) == 0 {
+				continue
+			}
+			if node, ok := q.TiStmt[0].(*tidb.CreateTableStmt); ok {
+				creates[node.Table.Name.L] = sql
+			}
+		}
+	}
+	return creates, nil
+}
+
+// checkSchemaDiffDDL 对生成的 DDL 进行 ALT, COL, KEY 类启发式规则检查
+func checkSchemaDiffDDL(sql string) []Rule {
+	var rules []Rule
+	q, err := NewQuery4Audit(sql)
+	if err != nil {
+		return append(rules, RuleMySQLError("ERR.000", err))
+	}
+
+	var items []string
+	for item := range HeuristicRules {
+		if (strings.HasPrefix(item, "ALT.") || strings.HasPrefix(item, "COL.") || strings.HasPrefix(item, "KEY.")) &&
+			!IsIgnoreRule(item) {
+			items = append(items, item)
+		}
+	}
+	sort.Strings(items)
+	for _, item := range items {
+		if r := HeuristicRules[item].Func(q); r.Item == item {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
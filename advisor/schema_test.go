@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestSchemaAudit(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	reports := SchemaAudit(rEnv, "sakila")
+	if len(reports) != 1 || reports[0].Tables == 0 {
+		t.Errorf("got reports: %s", pretty.Sprint(reports))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatSchemaAudit(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	results := []auditResult{
+		{Table: "film", Rule: HeuristicRules["COL.004"]},
+		{Table: "actor", Rule: HeuristicRules["COL.004"]},
+		{Table: "city", Rule: HeuristicRules["KEY.002"]},
+		{Table: "film", Rule: Rule{Item: "IDX.001", Severity: "L2", Summary: "存在重复的索引", Content: "索引idx_a(a)与idx_a_b(a, b)重复;"}},
+	}
+	reports := []SchemaAuditReport{
+		{
+			Database: "sakila",
+			Tables:   3,
+			Findings: mergeAuditResults(results),
+		},
+	}
+	err := common.GoldenDiff(func() {
+		for _, format := range []string{"markdown", "json", "lint", "text"} {
+			fmt.Println(FormatSchemaAudit(reports, format))
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestSchemaDiff(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar-schema-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conn := *rEnv
+	conn.Database = "sakila"
+	current, err := showCreateTables(&conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf []string
+	for _, ddl := range current {
+		buf = append(buf, ddl+";")
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "sakila.sql"), []byte(strings.Join(buf, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := SchemaDiff(&conn, dir)
+	if err != nil || diff != "" {
+		t.Errorf("got diff: %s, err: %v", diff, err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestReadCreateTables(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar-schema-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sqls := `-- actor
+CREATE TABLE actor (id int NOT NULL, PRIMARY KEY (id));
+INSERT INTO actor VALUES (1);
+CREATE TABLE film (id int NOT NULL, title varchar(255));`
+	err = ioutil.WriteFile(filepath.Join(dir, "sakila.sql"), []byte(sqls), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("CREATE TABLE readme (id int);"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creates, err := readCreateTables(dir)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(creates) != 2 || creates["actor"] == "" || creates["film"] == "" {
+		t.Errorf("got creates: %s", pretty.Sprint(creates))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestCheckSchemaDiffDDL(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	// 评审结果与阈值配置有关，使用默认配置
+	orgConfig := *common.Config
+	defer func() { *common.Config = orgConfig }()
+	common.Config.IgnoreRules = []string{"COL.011"}
+	common.Config.MaxColCount = 40
+	common.Config.MaxIdxCount = 10
+	common.Config.MaxIdxColsCount = 5
+	sqls := []string{
+		"ALTER TABLE `sakila`.`actor` DROP COLUMN `nick_name`, ADD COLUMN `middle_name` VARCHAR(45) DEFAULT NULL AFTER `first_name`;",
+		"CREATE TABLE `sakila`.`new_table` (`id` INT(11) NOT NULL,`c` VARCHAR(10) DEFAULT '',PRIMARY KEY(`id`));",
+		"-- 高危操作: 将删除表 `sakila`.`old_table` 及其全部数据，执行前请确认并备份\nDROP TABLE `sakila`.`old_table`;",
+	}
+	err := common.GoldenDiff(func() {
+		for _, sql := range sqls {
+			for _, r := range checkSchemaDiffDDL(sql) {
+				fmt.Println(r.Item, r.Severity, r.Summary)
+			}
+			fmt.Println(sql)
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// SessionStatusAdvisor 根据执行前后 SESSION STATUS 的增量给出建议，PRO.008 为完整的增量信息
+func SessionStatusAdvisor(s *database.SessionStatus) map[string]Rule {
+	proRules := map[string]Rule{
+		"PRO.008": {
+			Item:     "PRO.008",
+			Severity: "L0",
+			Summary:  "SESSION STATUS 计数变化",
+			Content:  "在测试环境中执行 SQL 前后 SHOW SESSION STATUS 中各项计数的增量。",
+			Case:     database.FormatSessionStatus(s),
+		},
+	}
+
+	if s.Delta["Select_full_join"] > 0 {
+		proRules["PRO.009"] = Rule{
+			Item:     "PRO.009",
+			Severity: "L3",
+			Summary:  "关联查询的被驱动表进行了全表扫描",
+			Content:  "Select_full_join 增加说明关联查询中被驱动表没有可用的索引，驱动表的每一行都需要全表扫描一次被驱动表。建议为关联条件中的列添加索引。",
+			Case:     fmt.Sprintf("* Select_full_join: %d", s.Delta["Select_full_join"]),
+		}
+	}
+
+	if s.Delta["Created_tmp_disk_tables"] > 0 {
+		proRules["PRO.010"] = Rule{
+			Item:     "PRO.010",
+			Severity: "L2",
+			Summary:  "使用了磁盘临时表",
+			Content:  "Created_tmp_disk_tables 增加说明 SQL 执行过程中创建了磁盘临时表。建议为 GROUP BY, DISTINCT, UNION 等操作添加合适的索引，或者减少临时表中的列，避免包含 BLOB/TEXT 列。",
+			Case:     fmt.Sprintf("* Created_tmp_disk_tables: %d", s.Delta["Created_tmp_disk_tables"]),
+		}
+	}
+
+	// 返回 0 行时按 1 行计算
+	read, sent := s.HandlerRead(), s.RowsSent
+	if sent < 1 {
+		sent = 1
+	}
+	if common.Config.MaxHandlerReadRatio > 0 && float64(read) > float64(sent)*common.Config.MaxHandlerReadRatio {
+		proRules["PRO.011"] = Rule{
+			Item:     "PRO.011",
+			Severity: "L2",
+			Summary:  "存储引擎读取的行数远大于返回的行数",
+			Content: fmt.Sprintf("Handler_read%% 增量之和超过了返回行数的 %s 倍，大部分读取的行被过滤掉了。Handler_read_rnd_next 较大说明进行了全表扫描，Handler_read_next 较大说明索引范围扫描的区间过大，建议添加区分度更高的索引或联合索引。",
+				formatTraceNumber(common.Config.MaxHandlerReadRatio)),
+			Case: fmt.Sprintf("* Handler_read: %d\n* Handler_read_rnd_next: %d\n* Handler_read_next: %d\n* rows_sent: %d",
+				read, s.Delta["Handler_read_rnd_next"], s.Delta["Handler_read_next"], s.RowsSent),
+		}
+	}
+	return proRules
+}
+
+// sessionStatusDuplicates 与 performance_schema Profiling 重复的建议
+// Select_full_join, Created_tmp_disk_tables 与 PRO.005, PRO.003 中的 select_full_join, tmp_disk_tables 是同一个计数
+var sessionStatusDuplicates = map[string]string{
+	"PRO.009": "PRO.005",
+	"PRO.010": "PRO.003",
+}
+
+// MergeSessionStatusRules 将 SessionStatusAdvisor 给出的建议合并到 proRules 中，Profiling 中已给出的问题不再重复提醒
+func MergeSessionStatusRules(proRules, rules map[string]Rule) {
+	for item, r := range rules {
+		if dup, ok := sessionStatusDuplicates[item]; ok {
+			if _, ok = proRules[dup]; ok {
+				continue
+			}
+		}
+		proRules[item] = r
+	}
+}
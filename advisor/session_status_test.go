@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestSessionStatusAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	status := []*database.SessionStatus{
+		{
+			Query:    "select * from film where film_id = 1",
+			RowsSent: 1,
+			Delta:    map[string]int64{"Handler_read_key": 1, "Innodb_rows_read": 1},
+		},
+		{
+			Query:    "select distinct f.description from film f join film_text ft on f.title = ft.title where ft.title like '%A%'",
+			RowsSent: 3,
+			Delta: map[string]int64{
+				"Handler_read_first":      2,
+				"Handler_read_rnd_next":   1003002,
+				"Select_scan":             1,
+				"Select_full_join":        1,
+				"Created_tmp_disk_tables": 1,
+				"Innodb_rows_read":        1002000,
+			},
+		},
+	}
+	err := common.GoldenDiff(func() {
+		for _, s := range status {
+			rules := SessionStatusAdvisor(s)
+			var items []string
+			for item := range rules {
+				items = append(items, item)
+			}
+			sort.Strings(items)
+			for _, item := range items {
+				fmt.Println(rules[item].Item, rules[item].Severity, rules[item].Summary)
+				fmt.Println(rules[item].Content)
+				fmt.Println(rules[item].Case)
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestMergeSessionStatusRules(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	rules := map[string]Rule{
+		"PRO.008": {Item: "PRO.008"},
+		"PRO.009": {Item: "PRO.009"},
+		"PRO.010": {Item: "PRO.010"},
+	}
+	proRules := map[string]Rule{"PRO.001": {Item: "PRO.001"}, "PRO.005": {Item: "PRO.005"}}
+	MergeSessionStatusRules(proRules, rules)
+	var items []string
+	for item := range proRules {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	if strings.Join(items, ",") != "PRO.001,PRO.005,PRO.008,PRO.010" {
+		t.Errorf("got items: %v", items)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
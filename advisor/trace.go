@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// traceGuessFilteringPct 列上没有索引或直方图时优化器使用的默认过滤比例
+// 等值条件 10%，范围条件 33.33%，BETWEEN 11.11%
+var traceGuessFilteringPct = []float64{10, 33.3333, 11.1111}
+
+// traceMaxRanges 每个索引最多列出的范围条件个数
+const traceMaxRanges = 3
+
+// TraceAdvisor 解析 optimizer_trace，TRA.001 列出优化器的决策过程，TRA.002 ~ TRA.004 为优化器缺少统计信息只能估算的情况
+func TraceAdvisor(rows []database.TraceRow) map[string]Rule {
+	traceRules := make(map[string]Rule)
+	var buf, statsMissing, skipDives, guessFiltering []string
+	for _, row := range rows {
+		info, err := database.ParseTrace(row)
+		if err != nil {
+			common.Log.Warn("TraceAdvisor ParseTrace Error: %v", err)
+			continue
+		}
+
+		buf = append(buf, fmt.Sprintf("```sql\n%s\n```\n", info.Query))
+		for _, finding := range TraceFindings(info) {
+			buf = append(buf, "* "+finding)
+		}
+		buf = append(buf, "")
+
+		for _, path := range info.AccessPaths {
+			if path.StatsMissing {
+				statsMissing = append(statsMissing, fmt.Sprintf("* %s: %s", path.Table, path.Index))
+			}
+		}
+		for _, ra := range info.RangeAnalysis {
+			for _, index := range ra.Indexes {
+				if index.SkipDives {
+					skipDives = append(skipDives, fmt.Sprintf("* %s: %s", ra.Table, index.Index))
+				}
+			}
+		}
+		for _, filtering := range info.Filterings {
+			for _, pct := range traceGuessFilteringPct {
+				if math.Abs(filtering.Pct-pct) < 0.01 {
+					guessFiltering = append(guessFiltering, fmt.Sprintf("* %s: condition_filtering_pct %s", filtering.Table, formatTraceNumber(filtering.Pct)))
+				}
+			}
+		}
+	}
+
+	// verbose 模式下附带完整的 optimizer_trace
+	if len(buf) > 0 && common.Config.Verbose {
+		buf = append(buf, database.FormatTrace(rows))
+	}
+	if len(buf) > 0 {
+		traceRules["TRA.001"] = Rule{
+			Item:     "TRA.001",
+			Severity: "L0",
+			Content:  strings.Join(buf, "\n"),
+		}
+	}
+	if len(statsMissing) > 0 {
+		traceRules["TRA.002"] = Rule{
+			Item:     "TRA.002",
+			Severity: "L2",
+			Summary:  "索引统计信息缺失",
+			Content:  "优化器估算以下索引的 ref 访问行数时缺少索引统计信息（rec_per_key_stats_missing），只能使用默认值估算，生成的执行计划可能不是最优的。建议执行 ANALYZE TABLE 更新统计信息。",
+			Case:     strings.Join(common.RemoveDuplicatesItem(statsMissing), "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+	if len(skipDives) > 0 {
+		traceRules["TRA.003"] = Rule{
+			Item:     "TRA.003",
+			Severity: "L1",
+			Summary:  "等值范围过多，没有使用 index dive 估算行数",
+			Content:  "IN 列表等值范围的个数达到 eq_range_index_dive_limit 后，优化器使用索引统计信息估算行数，数据分布不均匀时偏差较大。建议拆分过长的 IN 列表，或适当调大 eq_range_index_dive_limit。",
+			Case:     strings.Join(common.RemoveDuplicatesItem(skipDives), "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+	if len(guessFiltering) > 0 {
+		traceRules["TRA.004"] = Rule{
+			Item:     "TRA.004",
+			Severity: "L1",
+			Summary:  "过滤比例为优化器的默认估算值",
+			Content:  "过滤条件中的列没有索引或直方图时，优化器按固定比例估算过滤后的行数（等值 10%，范围 33.33%，BETWEEN 11.11%），连接顺序可能因此选择错误。建议为过滤条件中的列添加索引，MySQL 8.0 可以使用 ANALYZE TABLE ... UPDATE HISTOGRAM 创建直方图。",
+			Case:     strings.Join(common.RemoveDuplicatesItem(guessFiltering), "\n"),
+			Func:     (*Query4Audit).RuleOK,
+		}
+	}
+	return traceRules
+}
+
+// TraceFindings 将 optimizer_trace 中的决策过程整理为简洁的结论
+func TraceFindings(info *database.TraceInfo) []string {
+	var findings []string
+
+	// 范围扫描分析
+	for _, ra := range info.RangeAnalysis {
+		if ra.ScanRows > 0 || ra.ScanCost > 0 {
+			findings = append(findings, fmt.Sprintf("%s table scan: rows %s, cost %s", ra.Table,
+				formatTraceNumber(ra.ScanRows), formatTraceNumber(ra.ScanCost)))
+		}
+		for _, index := range ra.Indexes {
+			switch {
+			case !index.Usable:
+				findings = append(findings, fmt.Sprintf("%s index %s not usable: %s", ra.Table, index.Index, index.Cause))
+			case index.Chosen:
+				continue
+			case index.Cause == "cost" && ra.ScanCost > 0 && index.Cost > ra.ScanCost:
+				findings = append(findings, fmt.Sprintf("%s index %s rejected: cost %s > table scan %s", ra.Table, index.Index,
+					formatTraceNumber(index.Cost), formatTraceNumber(ra.ScanCost)))
+			default:
+				findings = append(findings, fmt.Sprintf("%s index %s rejected: %s, rows %s, cost %s", ra.Table, index.Index,
+					index.Cause, formatTraceNumber(index.Rows), formatTraceNumber(index.Cost)))
+			}
+		}
+		if ra.ChosenType != "" {
+			finding := fmt.Sprintf("%s range analysis: %s on %s, rows %s, cost %s", ra.Table, ra.ChosenType, ra.ChosenKey,
+				formatTraceNumber(ra.ChosenRows), formatTraceNumber(ra.ChosenCost))
+			for _, index := range ra.Indexes {
+				if index.Chosen && index.Index == ra.ChosenKey && len(index.Ranges) > 0 {
+					ranges := index.Ranges
+					if len(ranges) > traceMaxRanges {
+						ranges = append(ranges[:traceMaxRanges:traceMaxRanges], "...")
+					}
+					finding += fmt.Sprintf(", ranges: %s", strings.Join(ranges, "; "))
+				}
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	// 生成执行计划时考虑过的访问方式
+	for _, path := range info.AccessPaths {
+		access := path.AccessType
+		if path.Index != "" {
+			access += " " + path.Index
+		}
+		finding := fmt.Sprintf("%s access path %s", path.Table, access)
+		// 按启发式规则排除的访问方式没有代价信息
+		if path.Rows > 0 || path.Cost > 0 {
+			finding += fmt.Sprintf(": rows %s, cost %s", formatTraceNumber(path.Rows), formatTraceNumber(path.Cost))
+		}
+		switch {
+		case path.Chosen:
+			finding += ", chosen"
+		case path.Cause != "":
+			finding += ", rejected: " + path.Cause
+		default:
+			finding += ", rejected"
+		}
+		findings = append(findings, finding)
+	}
+
+	for _, order := range info.JoinOrders {
+		findings = append(findings, "join order: "+strings.Join(order, " -> "))
+	}
+	return findings
+}
+
+func formatTraceNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
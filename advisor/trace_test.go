@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestTraceAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	// MySQL 5.7 optimizer_trace 节选
+	trace := `{"steps": [{"join_optimization": {"select#": 1, "steps": [
+  {"rows_estimation": [
+    {"table": "` + "`film`" + `", "range_analysis": {
+      "table_scan": {"rows": 1000, "cost": 215},
+      "potential_range_indexes": [
+        {"index": "PRIMARY", "usable": false, "cause": "not_applicable"},
+        {"index": "idx_release_year", "usable": true, "key_parts": ["release_year", "film_id"]}
+      ],
+      "analyzing_range_alternatives": {"range_scan_alternatives": [
+        {"index": "idx_release_year", "ranges": ["2006 <= release_year <= 2006", "2007 <= release_year <= 2007"],
+         "index_dives_for_eq_ranges": false, "rows": 1000, "cost": 1202, "chosen": false, "cause": "cost"}
+      ]}
+    }},
+    {"table": "` + "`film_actor`" + `", "range_analysis": {
+      "table_scan": {"rows": 5462, "cost": 1111.5},
+      "analyzing_range_alternatives": {"range_scan_alternatives": [
+        {"index": "PRIMARY", "ranges": ["10 < actor_id"], "index_dives_for_eq_ranges": true, "rows": 2731, "cost": 552.2, "chosen": true}
+      ]},
+      "chosen_range_access_summary": {"range_access_plan": {"type": "range_scan", "index": "PRIMARY"}, "rows_for_plan": 2731, "cost_for_plan": 552.2, "chosen": true}
+    }}
+  ]},
+  {"considered_execution_plans": [
+    {"plan_prefix": [], "table": "` + "`film`" + `",
+     "best_access_path": {"considered_access_paths": [{"rows_to_scan": 1000, "access_type": "scan", "cost": 213, "chosen": true}]},
+     "condition_filtering_pct": 10,
+     "rest_of_plan": [
+       {"plan_prefix": ["` + "`film`" + `"], "table": "` + "`film_actor`" + `",
+        "best_access_path": {"considered_access_paths": [
+          {"access_type": "ref", "index": "idx_fk_film_id", "rows": 5.4, "cost": 648, "chosen": true, "rec_per_key_stats_missing": true},
+          {"access_type": "range", "range_details": {"used_index": "PRIMARY"}, "chosen": false, "cause": "heuristic_index_cheaper"}
+        ]},
+        "condition_filtering_pct": 50}
+     ]}
+  ]},
+  {"refine_plan": [{"table": "` + "`film`" + `"}, {"table": "` + "`film_actor`" + `"}]}
+]}}]}`
+
+	err := common.GoldenDiff(func() {
+		rules := TraceAdvisor([]database.TraceRow{
+			{Query: "explain select * from film join film_actor using(film_id) where release_year in (2006, 2007) and actor_id > 10", Trace: trace},
+			{Query: "select 1", Trace: "{"},
+		})
+		var items []string
+		for item := range rules {
+			items = append(items, item)
+		}
+		sort.Strings(items)
+		for _, item := range items {
+			fmt.Println(rules[item].Item, rules[item].Severity, rules[item].Summary)
+			fmt.Println(rules[item].Content)
+			fmt.Println(rules[item].Case)
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// verbose 模式下 TRA.001 附带完整的 optimizer_trace
+	orgVerbose := common.Config.Verbose
+	defer func() { common.Config.Verbose = orgVerbose }()
+	common.Config.Verbose = true
+	rules := TraceAdvisor([]database.TraceRow{{Query: "explain select * from film", Trace: trace}})
+	if !strings.Contains(rules["TRA.001"].Content, "```json\n"+trace) {
+		t.Errorf("verbose TRA.001 without trace: %s", rules["TRA.001"].Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
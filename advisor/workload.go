@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// workloadMaxProblems 汇总表中每类 SQL 最多列出的问题个数
+const workloadMaxProblems = 5
+
+// FormatQueryDigests 按总执行时间列出每类 SQL 的执行统计及评审发现的问题，耗时越多的 SQL 问题影响越大
+func FormatQueryDigests(digests []*database.QueryDigest, suggests map[string]map[string]Rule) string {
+	var total float64
+	for _, digest := range digests {
+		total += digest.TotalTime
+	}
+
+	buf := []string{
+		"# SQL 执行统计",
+		"",
+		"| Rank | ID | Count | Total(s) | Share | P95(s) | Max(s) | Rows_examined/Count | Problems |",
+		"|---|---|---|---|---|---|---|---|---|",
+	}
+	for i, digest := range digests {
+		share := 0.0
+		if total > 0 {
+			share = digest.TotalTime / total * 100
+		}
+		var avgExamined int64
+		if digest.Count > 0 {
+			avgExamined = digest.RowsExamined / digest.Count
+		}
+		buf = append(buf, fmt.Sprintf("| %d | %s | %d | %.3f | %.1f%% | %.3f | %.3f | %d | %s |",
+			i+1, digest.ID, digest.Count, digest.TotalTime, share, digest.P95Time, digest.MaxTime,
+			avgExamined, common.MarkdownEscape(workloadProblems(suggests[digest.ID]))))
+	}
+	return strings.Join(buf, "\n")
+}
+
+// workloadProblems 按严重程度列出评审发现的问题
+func workloadProblems(suggest map[string]Rule) string {
+	var rules []Rule
+	for item, rule := range suggest {
+		// OK 及 L0 的提示信息不算问题
+		if item == "OK" || rule.Severity == "" || rule.Severity == "L0" {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Severity != rules[j].Severity {
+			return rules[i].Severity > rules[j].Severity
+		}
+		return rules[i].Item < rules[j].Item
+	})
+
+	var problems []string
+	for i, rule := range rules {
+		if i == workloadMaxProblems {
+			problems = append(problems, "...")
+			break
+		}
+		problems = append(problems, fmt.Sprintf("%s(%s)", rule.Item, rule.Severity))
+	}
+	return strings.Join(problems, ", ")
+}
+
+// QueryDigestRule 将日志或 performance_schema 中的执行统计作为 PRO.012 与评审结果一起输出
+func QueryDigestRule(digest *database.QueryDigest) Rule {
+	var avgTime float64
+	var avgSent, avgExamined int64
+	if digest.Count > 0 {
+		avgTime = digest.TotalTime / float64(digest.Count)
+		avgSent = digest.RowsSent / digest.Count
+		avgExamined = digest.RowsExamined / digest.Count
+	}
+	return Rule{
+		Item:     "PRO.012",
+		Severity: "L0",
+		Summary:  "执行统计",
+		Content:  "同一 SQL 指纹在日志或 performance_schema 中的执行统计，时间单位为秒，general log 及审计日志中没有执行时间。",
+		Case: strings.Join([]string{
+			"| Count | Total | Avg | P95 | Max | Lock | Rows_sent/Count | Rows_examined/Count |",
+			"|---|---|---|---|---|---|---|---|",
+			fmt.Sprintf("| %d | %.3f | %.3f | %.3f | %.3f | %.3f | %d | %d |", digest.Count, digest.TotalTime,
+				avgTime, digest.P95Time, digest.MaxTime, digest.LockTime, avgSent, avgExamined),
+		}, "\n"),
+	}
+}
+
+// FormatProcessDigests 按被采样到的次数列出 processlist 中的每类 SQL 及评审发现的问题
+func FormatProcessDigests(digests []*database.ProcessDigest, suggests map[string]map[string]Rule) string {
+	buf := []string{
+		"# 运行中的 SQL",
+		"",
+		"| Rank | ID | Seen | Executions | Max(s) | State | Problems |",
+		"|---|---|---|---|---|---|---|",
+	}
+	for i, digest := range digests {
+		buf = append(buf, fmt.Sprintf("| %d | %s | %d | %d | %s | %s | %s |",
+			i+1, digest.ID, digest.Seen, digest.Count, formatTraceNumber(digest.MaxTime),
+			common.MarkdownEscape(strings.Join(digest.States, ", ")),
+			common.MarkdownEscape(workloadProblems(suggests[digest.ID]))))
+	}
+	return strings.Join(buf, "\n")
+}
+
+// ProcessDigestRule 将 processlist 中的采样结果作为 PRO.013 与评审结果一起输出
+func ProcessDigestRule(digest *database.ProcessDigest) Rule {
+	return Rule{
+		Item:     "PRO.013",
+		Severity: "L0",
+		Summary:  "运行中的 SQL",
+		Content:  "同一 SQL 指纹在 processlist 中的采样结果，同一线程上连续采样到的同一条 SQL 算一次执行，时间单位为秒。",
+		Case: strings.Join([]string{
+			"| Seen | Executions | Max | State |",
+			"|---|---|---|---|",
+			fmt.Sprintf("| %d | %d | %s | %s |", digest.Seen, digest.Count, formatTraceNumber(digest.MaxTime),
+				common.MarkdownEscape(strings.Join(digest.States, ", "))),
+		}, "\n"),
+	}
+}
+
+// FormatBinlogTables 列出 binlog 中每张表的 DML 语句个数及影响的行数
+func FormatBinlogTables(stats []*database.BinlogTableStat) string {
+	buf := []string{
+		"# DML 统计",
+		"",
+		"| Table | Statements | Rows_inserted | Rows_updated | Rows_deleted |",
+		"|---|---|---|---|---|",
+	}
+	for _, stat := range stats {
+		buf = append(buf, fmt.Sprintf("| %s | %d | %d | %d | %d |", common.MarkdownEscape(stat.Table),
+			stat.Statements, stat.Inserts, stat.Updates, stat.Deletes))
+	}
+	return strings.Join(buf, "\n")
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package advisor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestFormatQueryDigests(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	digests := []*database.QueryDigest{
+		{ID: "B591022786B4FFEA", Count: 2, TotalTime: 3.5, MaxTime: 2.5, P95Time: 2.5, RowsExamined: 32098},
+		{ID: "4A2A57D1ED419DEA", Count: 1, TotalTime: 0.9, MaxTime: 0.9, P95Time: 0.9, RowsExamined: 1000},
+		{ID: "F3A5739911236B8A", Count: 2, TotalTime: 0.5, MaxTime: 0.3, P95Time: 0.3, RowsExamined: 2},
+	}
+	suggests := map[string]map[string]Rule{
+		"B591022786B4FFEA": {
+			"ARG.001": HeuristicRules["ARG.001"],
+			"COL.001": HeuristicRules["COL.001"],
+			"EXP.000": {Item: "EXP.000", Severity: "L0"},
+		},
+		"4A2A57D1ED419DEA": {
+			"SEC.003": HeuristicRules["SEC.003"],
+		},
+		"F3A5739911236B8A": {
+			"OK": HeuristicRules["OK"],
+		},
+	}
+	err := common.GoldenDiff(func() {
+		fmt.Println(FormatQueryDigests(digests, suggests))
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestQueryDigestRule(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	digest := &database.QueryDigest{
+		ID:           "B591022786B4FFEA",
+		Count:        2,
+		TotalTime:    3.5,
+		MaxTime:      2.5,
+		P95Time:      2.5,
+		LockTime:     0.0002,
+		RowsSent:     20,
+		RowsExamined: 32098,
+	}
+	err := common.GoldenDiff(func() {
+		r := QueryDigestRule(digest)
+		fmt.Println(r.Item, r.Severity, r.Summary)
+		fmt.Println(r.Content)
+		fmt.Println(r.Case)
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatProcessDigests(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	digests := []*database.ProcessDigest{
+		{
+			QueryDigest: database.QueryDigest{ID: "B591022786B4FFEA", Count: 1, TotalTime: 12, MaxTime: 12},
+			Seen:        5,
+			States:      []string{"Sending data"},
+		},
+		{
+			QueryDigest: database.QueryDigest{ID: "4A2A57D1ED419DEA", Count: 2, TotalTime: 3, MaxTime: 2},
+			Seen:        2,
+			States:      []string{"Creating sort index", "Waiting for table metadata lock"},
+		},
+	}
+	suggests := map[string]map[string]Rule{
+		"B591022786B4FFEA": {
+			"ARG.001": HeuristicRules["ARG.001"],
+		},
+	}
+	err := common.GoldenDiff(func() {
+		fmt.Println(FormatProcessDigests(digests, suggests))
+		r := ProcessDigestRule(digests[1])
+		fmt.Println(r.Item, r.Severity, r.Summary)
+		fmt.Println(r.Content)
+		fmt.Println(r.Case)
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatBinlogTables(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	stats := []*database.BinlogTableStat{
+		{Table: "sakila.film_actor", Statements: 1, Deletes: 2},
+		{Table: "sakila.film", Statements: 3, Inserts: 1, Updates: 1},
+	}
+	err := common.GoldenDiff(func() {
+		fmt.Println(FormatBinlogTables(stats))
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,323 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/pingcap/parser/ast"
+	tiformat "github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/model"
+)
+
+// tableSchema 从 CREATE TABLE 语句中提取的用于比较的表结构，名称均为小写
+type tableSchema struct {
+	Name       string
+	Columns    []string          // 按定义顺序排列的列名
+	ColumnDefs map[string]string // 列名 -> 列定义
+	Indexes    []string          // 按定义顺序排列的索引名，主键为 primary
+	IndexDefs  map[string]string // 索引名 -> 索引定义
+	Options    []string          // 按定义顺序排列的表属性
+	OptionDefs map[string]string // 表属性 -> 属性定义
+	FKNames    []string          // 按定义顺序排列的外键名
+	ForeignKey map[string]string // 外键名 -> 外键定义
+	Create     *ast.CreateTableStmt
+}
+
+// restoreNode 将 TiDB AST 节点还原为 SQL 文本
+func restoreNode(node interface {
+	Restore(ctx *tiformat.RestoreCtx) error
+}) string {
+	var sb strings.Builder
+	ctx := tiformat.NewRestoreCtx(tiformat.DefaultRestoreFlags|tiformat.RestoreStringWithoutCharset, &sb)
+	if err := node.Restore(ctx); err != nil {
+		common.Log.Warn("restoreNode Error: %s", err.Error())
+		return ""
+	}
+	return sb.String()
+}
+
+// newTableSchema 解析 CREATE TABLE 语句
+func newTableSchema(sql string) (*tableSchema, error) {
+	stmts, err := TiParse(sql, "", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range stmts {
+		if node, ok := stmt.(*ast.CreateTableStmt); ok {
+			return buildTableSchema(node, func(cons *ast.Constraint) string {
+				return foreignKeyDef(sql, cons)
+			}), nil
+		}
+	}
+	return nil, fmt.Errorf("no create table statement found: %s", sql)
+}
+
+// buildTableSchema 从 CREATE TABLE 的语法树中提取表结构，fkDef 用于获取外键定义
+func buildTableSchema(node *ast.CreateTableStmt, fkDef func(cons *ast.Constraint) string) *tableSchema {
+	tb := &tableSchema{
+		Name:       node.Table.Name.L,
+		ColumnDefs: make(map[string]string),
+		IndexDefs:  make(map[string]string),
+		OptionDefs: make(map[string]string),
+		ForeignKey: make(map[string]string),
+		Create:     node,
+	}
+	for _, col := range node.Cols {
+		tb.Columns = append(tb.Columns, col.Name.Name.L)
+		tb.ColumnDefs[col.Name.Name.L] = restoreNode(col)
+	}
+	for _, cons := range node.Constraints {
+		var name string
+		switch cons.Tp {
+		case ast.ConstraintPrimaryKey:
+			name = "primary"
+		case ast.ConstraintKey, ast.ConstraintIndex, ast.ConstraintUniq, ast.ConstraintUniqKey,
+			ast.ConstraintUniqIndex, ast.ConstraintFulltext:
+			name = strings.ToLower(cons.Name)
+		case ast.ConstraintForeignKey:
+			name = strings.ToLower(cons.Name)
+			tb.FKNames = append(tb.FKNames, name)
+			tb.ForeignKey[name] = fkDef(cons)
+			continue
+		default:
+			continue
+		}
+		tb.Indexes = append(tb.Indexes, name)
+		tb.IndexDefs[name] = restoreNode(cons)
+	}
+	for _, opt := range node.Options {
+		var name string
+		switch opt.Tp {
+		case ast.TableOptionEngine:
+			name = "engine"
+		case ast.TableOptionCharset:
+			name = "charset"
+		case ast.TableOptionCollate:
+			name = "collate"
+		case ast.TableOptionComment:
+			name = "comment"
+		case ast.TableOptionRowFormat:
+			name = "row_format"
+		default:
+			// AUTO_INCREMENT 等属性与表结构无关
+			continue
+		}
+		tb.Options = append(tb.Options, name)
+		tb.OptionDefs[name] = restoreNode(opt)
+	}
+	return tb
+}
+
+// foreignKeyDef 获取外键定义，TiDB parser 会丢失 ON UPDATE 子句，优先使用 SHOW CREATE TABLE 中的原始定义
+func foreignKeyDef(sql string, cons *ast.Constraint) string {
+	exp := regexp.MustCompile("(?im)^\\s*(CONSTRAINT\\s+`?" + regexp.QuoteMeta(cons.Name) + "`?\\s+FOREIGN\\s+KEY\\s.*?),?\\s*$")
+	if m := exp.FindStringSubmatch(sql); len(m) > 1 {
+		return m[1]
+	}
+	return restoreNode(cons)
+}
+
+// DiffCreateTables 比较两组建表语句，生成将 current 变更为 desired 的 DDL 语句
+// current 和 desired 的 key 为表名，value 为 CREATE TABLE 语句，生成的 DDL 中使用 db 作为库名
+// 输出顺序为 CREATE TABLE，删除外键，按表合并后的 ALTER TABLE，最后是带有高危操作警告注释的 DROP TABLE
+// 同名外键不能在同一条 ALTER TABLE 中删除后重建，所以删除外键单独输出；新建表的外键在所有表创建后添加
+func DiffCreateTables(db string, current, desired map[string]string) ([]string, error) {
+	var creates, dropFKs, alters, drops []string
+	curSchemas, err := parseTableSchemas(current)
+	if err != nil {
+		return nil, err
+	}
+	desSchemas, err := parseTableSchemas(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	tableName := fmt.Sprintf("`%s`", db)
+	for _, name := range sortedSchemaNames(desSchemas) {
+		des := desSchemas[name]
+		cur, ok := curSchemas[name]
+		if !ok {
+			// 新建表
+			var constraints []*ast.Constraint
+			for _, cons := range des.Create.Constraints {
+				if cons.Tp != ast.ConstraintForeignKey {
+					constraints = append(constraints, cons)
+				}
+			}
+			des.Create.Constraints = constraints
+			des.Create.Table.Schema = model.NewCIStr(db)
+			des.Create.IfNotExists = false
+			creates = append(creates, restoreNode(des.Create)+common.Config.Delimiter)
+			cur = &tableSchema{ForeignKey: make(map[string]string)}
+		}
+
+		drop, add := diffForeignKeys(cur, des)
+		for _, merged := range MergeAlterTables(alterSpecs(tableName, name, drop)...) {
+			dropFKs = append(dropFKs, strings.TrimSpace(merged))
+		}
+
+		var specs []string
+		if ok {
+			specs = alterSpecs(tableName, name, diffTableSchema(cur, des))
+		}
+		specs = append(specs, alterSpecs(tableName, name, add)...)
+		for _, merged := range MergeAlterTables(specs...) {
+			alters = append(alters, strings.TrimSpace(merged))
+		}
+	}
+
+	for _, name := range sortedSchemaNames(curSchemas) {
+		if _, ok := desSchemas[name]; !ok {
+			// 删表不可恢复，在语句前加上醒目的警告注释
+			drops = append(drops, fmt.Sprintf("-- 高危操作: 将删除表 %s.`%s` 及其全部数据，执行前请确认并备份\nDROP TABLE %s.`%s`%s",
+				tableName, name, tableName, name, common.Config.Delimiter))
+		}
+	}
+
+	return append(append(append(creates, dropFKs...), alters...), drops...), nil
+}
+
+// alterSpecs 为每个子句生成一条 ALTER TABLE 语句，便于使用 MergeAlterTables 合并
+func alterSpecs(tableName, name string, specs []string) []string {
+	var sqls []string
+	for _, spec := range specs {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s.`%s` %s", tableName, name, spec))
+	}
+	return sqls
+}
+
+// parseTableSchemas 批量解析建表语句
+func parseTableSchemas(tables map[string]string) (map[string]*tableSchema, error) {
+	schemas := make(map[string]*tableSchema)
+	for name, sql := range tables {
+		tb, err := newTableSchema(sql)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %s", name, err.Error())
+		}
+		schemas[tb.Name] = tb
+	}
+	return schemas, nil
+}
+
+func sortedSchemaNames(schemas map[string]*tableSchema) []string {
+	var names []string
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// diffTableSchema 比较同名表的结构，返回 ALTER TABLE 子句
+// 子句顺序：删除索引，删除列，修改列，添加列，添加索引，修改表属性
+func diffTableSchema(cur, des *tableSchema) []string {
+	var dropIdx, dropCol, modifyCol, addCol, addIdx, options []string
+
+	for _, idx := range cur.Indexes {
+		if def, ok := des.IndexDefs[idx]; !ok || def != cur.IndexDefs[idx] {
+			if idx == "primary" {
+				dropIdx = append(dropIdx, "DROP PRIMARY KEY")
+			} else {
+				dropIdx = append(dropIdx, fmt.Sprintf("DROP INDEX `%s`", idx))
+			}
+		}
+	}
+
+	for _, col := range cur.Columns {
+		if _, ok := des.ColumnDefs[col]; !ok {
+			dropCol = append(dropCol, fmt.Sprintf("DROP COLUMN `%s`", col))
+		}
+	}
+
+	for i, col := range des.Columns {
+		curDef, ok := cur.ColumnDefs[col]
+		switch {
+		case !ok:
+			position := "FIRST"
+			if i > 0 {
+				position = fmt.Sprintf("AFTER `%s`", des.Columns[i-1])
+			}
+			addCol = append(addCol, fmt.Sprintf("ADD COLUMN %s %s", des.ColumnDefs[col], position))
+		case curDef != des.ColumnDefs[col]:
+			modifyCol = append(modifyCol, fmt.Sprintf("MODIFY COLUMN %s", des.ColumnDefs[col]))
+		}
+	}
+
+	for _, idx := range des.Indexes {
+		if def, ok := cur.IndexDefs[idx]; !ok || def != des.IndexDefs[idx] {
+			addIdx = append(addIdx, "ADD "+des.IndexDefs[idx])
+		}
+	}
+
+	for _, opt := range des.Options {
+		if des.OptionDefs[opt] != cur.OptionDefs[opt] {
+			options = append(options, des.OptionDefs[opt])
+		}
+	}
+	// desired 中去掉的表属性需要恢复为默认值，ENGINE 和 CHARSET 没有确定的默认值，不做处理
+	for _, opt := range cur.Options {
+		if _, ok := des.OptionDefs[opt]; ok {
+			continue
+		}
+		switch opt {
+		case "comment":
+			options = append(options, "COMMENT = ''")
+		case "row_format":
+			options = append(options, "ROW_FORMAT = DEFAULT")
+		case "collate":
+			// 重新指定字符集时会使用字符集的默认排序规则
+			if def, ok := des.OptionDefs["charset"]; ok && def == cur.OptionDefs["charset"] {
+				options = append(options, def)
+			}
+		}
+	}
+
+	var specs []string
+	for _, s := range [][]string{dropIdx, dropCol, modifyCol, addCol, addIdx, options} {
+		specs = append(specs, s...)
+	}
+	return specs
+}
+
+// diffForeignKeys 比较外键，返回需要删除及添加的 ALTER TABLE 子句，定义发生变化的外键先删除再添加
+func diffForeignKeys(cur, des *tableSchema) (drop, add []string) {
+	for _, fk := range cur.FKNames {
+		if def, ok := des.ForeignKey[fk]; !ok || !sameForeignKey(def, cur.ForeignKey[fk]) {
+			drop = append(drop, fmt.Sprintf("DROP FOREIGN KEY `%s`", fk))
+		}
+	}
+	for _, fk := range des.FKNames {
+		if def, ok := cur.ForeignKey[fk]; !ok || !sameForeignKey(def, des.ForeignKey[fk]) {
+			add = append(add, "ADD "+des.ForeignKey[fk])
+		}
+	}
+	return drop, add
+}
+
+// sameForeignKey 忽略大小写、反引号及空白的差异比较外键定义
+func sameForeignKey(a, b string) bool {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(strings.Replace(s, "`", "", -1))), " ")
+	}
+	return normalize(a) == normalize(b)
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestDiffCreateTables(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	current := map[string]string{
+		"actor": "CREATE TABLE `actor` (\n" +
+			"  `actor_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `first_name` varchar(45) NOT NULL,\n" +
+			"  `last_name` varchar(45) NOT NULL,\n" +
+			"  `nick_name` varchar(45) DEFAULT NULL,\n" +
+			"  PRIMARY KEY (`actor_id`),\n" +
+			"  KEY `idx_actor_last_name` (`last_name`),\n" +
+			"  KEY `idx_nick_name` (`nick_name`)\n" +
+			") ENGINE=InnoDB AUTO_INCREMENT=201 DEFAULT CHARSET=utf8",
+		"language": "CREATE TABLE `language` (\n" +
+			"  `language_id` tinyint(3) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `name` char(20) NOT NULL,\n" +
+			"  PRIMARY KEY (`language_id`)\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+		"old_table": "CREATE TABLE `old_table` (`id` int(11) NOT NULL, PRIMARY KEY (`id`)) ENGINE=InnoDB",
+	}
+	desired := map[string]string{
+		"actor": "CREATE TABLE `actor` (\n" +
+			"  `actor_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `first_name` varchar(64) NOT NULL,\n" +
+			"  `middle_name` varchar(45) DEFAULT NULL,\n" +
+			"  `last_name` varchar(45) NOT NULL,\n" +
+			"  PRIMARY KEY (`actor_id`),\n" +
+			"  KEY `idx_actor_last_name` (`last_name`, `first_name`)\n" +
+			") ENGINE=InnoDB AUTO_INCREMENT=1000 DEFAULT CHARSET=utf8mb4",
+		"language": "CREATE TABLE `language` (\n" +
+			"  `language_id` tinyint(3) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `name` char(20) NOT NULL,\n" +
+			"  PRIMARY KEY (`language_id`)\n" +
+			") ENGINE=InnoDB AUTO_INCREMENT=7 DEFAULT CHARSET=utf8",
+		"new_table": "CREATE TABLE IF NOT EXISTS new_table (id int(11) NOT NULL, c varchar(10) DEFAULT '', PRIMARY KEY (id))",
+	}
+
+	err := common.GoldenDiff(func() {
+		ddl, err := DiffCreateTables("sakila", current, desired)
+		if err != nil {
+			t.Error(err)
+		}
+		for _, sql := range ddl {
+			fmt.Println(sql)
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestDiffCreateTablesForeignKey(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	current := map[string]string{
+		"city": "CREATE TABLE `city` (\n" +
+			"  `city_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `country_id` smallint(5) unsigned NOT NULL,\n" +
+			"  `store_id` tinyint(3) unsigned NOT NULL,\n" +
+			"  PRIMARY KEY (`city_id`),\n" +
+			"  KEY `idx_fk_country_id` (`country_id`),\n" +
+			"  KEY `idx_fk_store_id` (`store_id`),\n" +
+			"  CONSTRAINT `fk_city_country` FOREIGN KEY (`country_id`) REFERENCES `country` (`country_id`) ON UPDATE CASCADE,\n" +
+			"  CONSTRAINT `fk_city_store` FOREIGN KEY (`store_id`) REFERENCES `store` (`store_id`)\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8 ROW_FORMAT=COMPACT COMMENT='city'",
+		"country": "CREATE TABLE `country` (\n" +
+			"  `country_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  PRIMARY KEY (`country_id`)\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin",
+	}
+	desired := map[string]string{
+		"city": "CREATE TABLE `city` (\n" +
+			"  `city_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `country_id` smallint(5) unsigned NOT NULL,\n" +
+			"  `store_id` tinyint(3) unsigned NOT NULL,\n" +
+			"  `address_id` smallint(5) unsigned NOT NULL,\n" +
+			"  PRIMARY KEY (`city_id`),\n" +
+			"  KEY `idx_fk_country_id` (`country_id`),\n" +
+			"  KEY `idx_fk_store_id` (`store_id`),\n" +
+			"  KEY `idx_fk_address_id` (`address_id`),\n" +
+			"  CONSTRAINT `fk_city_country` FOREIGN KEY (`country_id`) REFERENCES `country` (`country_id`) ON DELETE CASCADE ON UPDATE CASCADE,\n" +
+			"  CONSTRAINT `fk_city_address` FOREIGN KEY (`address_id`) REFERENCES `address` (`address_id`)\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+		"country": "CREATE TABLE `country` (\n" +
+			"  `country_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  PRIMARY KEY (`country_id`)\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+		"address": "CREATE TABLE `address` (\n" +
+			"  `address_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `city_id` smallint(5) unsigned NOT NULL,\n" +
+			"  PRIMARY KEY (`address_id`),\n" +
+			"  KEY `idx_fk_city_id` (`city_id`),\n" +
+			"  CONSTRAINT `fk_address_city` FOREIGN KEY (`city_id`) REFERENCES `city` (`city_id`) ON UPDATE CASCADE\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+	}
+
+	err := common.GoldenDiff(func() {
+		ddl, err := DiffCreateTables("sakila", current, desired)
+		if err != nil {
+			t.Error(err)
+		}
+		for _, sql := range ddl {
+			fmt.Println(sql)
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -302,6 +302,55 @@ func (rw *Rewrite) Rewrite() *Rewrite {
 	return rw
 }
 
+// RewriteVariant 单条重写规则或全部重写规则生成的 SQL
+type RewriteVariant struct {
+	Rule string // 重写规则名称，全部规则同时生效时为 rewrite
+	SQL  string
+}
+
+// RewriteVariants 依次单独执行每一条重写规则，返回与原 SQL 不同的重写结果，最后是全部规则同时生效的结果
+func RewriteVariants(sql string, columns common.TableColumns) []RewriteVariant {
+	var variants []RewriteVariant
+	org := NewRewrite(sql)
+	if org == nil {
+		return variants
+	}
+	// 按 vitess 格式化后对比，过滤掉只有格式、分号变化的重写结果
+	seen := map[string]bool{sqlparser.String(org.Stmt): true}
+	add := func(rule string, rw *Rewrite) {
+		stmt, err := sqlparser.Parse(rw.NewSQL)
+		if rw.NewSQL == "" || err != nil {
+			return
+		}
+		if key := sqlparser.String(stmt); !seen[key] {
+			seen[key] = true
+			variants = append(variants, RewriteVariant{Rule: rule, SQL: strings.TrimSpace(rw.NewSQL)})
+		}
+	}
+
+	for _, rule := range RewriteRules {
+		if !RewriteRuleMatch(rule.Name) || rule.Func == nil {
+			continue
+		}
+		rw := NewRewrite(sql)
+		rw.Columns = columns
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					common.Log.Error("Query rewrite Error: %s, rule: %s, Query: %s", err, rule.Name, sql)
+				}
+			}()
+			rule.Func(rw)
+		}()
+		add(rule.Name, rw)
+	}
+
+	rw := NewRewrite(sql)
+	rw.Columns = columns
+	add("rewrite", rw.Rewrite())
+	return variants
+}
+
 // RewriteDelimiter delimiter: 补分号，可以指定不同的DELIMITER
 func (rw *Rewrite) RewriteDelimiter() *Rewrite {
 	if rw.NewSQL != "" {
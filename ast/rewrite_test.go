@@ -825,3 +825,24 @@ func TestListRewriteRules(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestRewriteVariants(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := []string{
+		"select title, count(*) from film group by title having title = 'abc'",
+		"select distinct * from film",
+		"select film_id from film",
+	}
+	err := common.GoldenDiff(func() {
+		for _, sql := range sqls {
+			fmt.Println(sql)
+			for _, v := range RewriteVariants(sql, nil) {
+				fmt.Printf("  %s: %s\n", v.Rule, v.SQL)
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
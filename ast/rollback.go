@@ -0,0 +1,905 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/pingcap/parser/ast"
+)
+
+// DDLRollback 按执行顺序为一批 DDL 语句生成回滚语句
+// 每条语句生成回滚语句后，其变更会应用到内存中的表结构上，后面的语句基于前面语句执行后的表结构生成回滚语句
+type DDLRollback struct {
+	showCreate func(db, table string) string
+	tables     map[string]*tableSchema // 库名.表名 -> 当前表结构，nil 表示表不存在或无法获取
+	creates    map[string]string       // 库名.表名 -> 未被修改过的原始建表语句，包括视图
+	droppedDBs map[string]bool
+}
+
+// NewDDLRollback showCreate 用于获取变更前的建表语句，db 为空时表示未指定库名，获取不到时返回空字符串
+func NewDDLRollback(showCreate func(db, table string) string) *DDLRollback {
+	return &DDLRollback{
+		showCreate: showCreate,
+		tables:     make(map[string]*tableSchema),
+		creates:    make(map[string]string),
+		droppedDBs: make(map[string]bool),
+	}
+}
+
+// RollbackDDL 生成单条 DDL 语句的回滚语句，多条语句需要依次回滚时使用 DDLRollback
+func RollbackDDL(sql string, showCreate func(db, table string) string) (rollback []string, warnings []string, err error) {
+	return NewDDLRollback(showCreate).Rollback(sql)
+}
+
+// Rollback 生成 DDL 语句的回滚语句，并将语句的变更应用到内存中的表结构上
+// 返回的回滚语句按执行顺序排列，无法精确回滚的情况通过 warnings 返回
+func (r *DDLRollback) Rollback(sql string) (rollback []string, warnings []string, err error) {
+	stmts, err := TiParse(sql, "", "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	warn := func(format string, a ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, a...))
+	}
+
+	for _, stmt := range stmts {
+		switch node := stmt.(type) {
+		case *ast.CreateTableStmt:
+			if node.IfNotExists && r.create(node.Table) != "" {
+				warn("表 %s 已存在，CREATE TABLE IF NOT EXISTS 不会执行，无需回滚", restoreNode(node.Table))
+				continue
+			}
+			rollback = append(rollback, "DROP TABLE "+restoreNode(node.Table))
+
+		case *ast.DropTableStmt:
+			for _, table := range node.Tables {
+				create := r.create(table)
+				if create == "" {
+					warn("无法获取 %s 的定义，无法生成回滚语句", restoreNode(table))
+					continue
+				}
+				if table.Schema.O != "" {
+					create = qualifyCreate(create, table)
+				}
+				rollback = append(rollback, create)
+				if !node.IsView {
+					warn("DROP TABLE 删除的数据无法通过 DDL 恢复，请提前备份 %s 中的数据", restoreNode(table))
+				}
+			}
+
+		case *ast.TruncateTableStmt:
+			warn("TRUNCATE TABLE 删除的数据无法通过 DDL 恢复，请提前备份 %s 中的数据", restoreNode(node.Table))
+
+		case *ast.CreateIndexStmt:
+			rollback = append(rollback, fmt.Sprintf("ALTER TABLE %s DROP INDEX `%s`", restoreNode(node.Table), node.IndexName))
+
+		case *ast.DropIndexStmt:
+			cur := r.current(node.Table)
+			if cur == nil {
+				warn("无法获取表 %s 的定义，无法回滚 DROP INDEX `%s`", restoreNode(node.Table), node.IndexName)
+				continue
+			}
+			def, ok := cur.IndexDefs[strings.ToLower(node.IndexName)]
+			if !ok {
+				warn("表 %s 中不存在索引 `%s`，无需回滚", restoreNode(node.Table), node.IndexName)
+				continue
+			}
+			rollback = append(rollback, fmt.Sprintf("ALTER TABLE %s ADD %s", restoreNode(node.Table), def))
+
+		case *ast.RenameTableStmt:
+			var pairs []string
+			for i := len(node.TableToTables) - 1; i >= 0; i-- {
+				t := node.TableToTables[i]
+				pairs = append(pairs, fmt.Sprintf("%s TO %s", restoreNode(t.NewTable), restoreNode(t.OldTable)))
+			}
+			rollback = append(rollback, "RENAME TABLE "+strings.Join(pairs, ", "))
+
+		case *ast.CreateDatabaseStmt:
+			if node.IfNotExists {
+				warn("CREATE DATABASE IF NOT EXISTS 的库 `%s` 如果已经存在，请不要执行回滚语句", node.Name)
+			}
+			rollback = append(rollback, fmt.Sprintf("DROP DATABASE `%s`", node.Name))
+
+		case *ast.DropDatabaseStmt:
+			warn("DROP DATABASE `%s` 会删除库中所有的表及数据，无法通过 DDL 回滚，请提前备份", node.Name)
+
+		case *ast.CreateViewStmt:
+			if node.OrReplace {
+				if create := r.create(node.ViewName); create != "" {
+					rollback = append(rollback, create)
+					r.apply(node, sql)
+					continue
+				}
+			}
+			rollback = append(rollback, "DROP VIEW "+restoreNode(node.ViewName))
+
+		case *ast.AlterTableStmt:
+			rollback = append(rollback, rollbackAlterTable(node, r.current(node.Table), warn)...)
+
+		default:
+			warn("不支持生成该语句的回滚语句: %s", sql)
+		}
+		r.apply(stmt, sql)
+	}
+
+	for i := range rollback {
+		rollback[i] += common.Config.Delimiter
+	}
+	return rollback, warnings, nil
+}
+
+// rollbackAlterTable 生成 ALTER TABLE 的回滚语句，子句按与原语句相反的顺序排列
+func rollbackAlterTable(node *ast.AlterTableStmt, cur *tableSchema, warn func(format string, a ...interface{})) []string {
+	table := restoreNode(node.Table)
+	var specs []string
+	var renameTo string
+	// needCurrent 需要依赖原表结构的子句，获取不到原表结构时给出警告
+	needCurrent := func(spec *ast.AlterTableSpec) bool {
+		if cur == nil {
+			warn("无法获取表 %s 的定义，无法回滚 %s", table, restoreNode(spec))
+			return false
+		}
+		return true
+	}
+
+	// 同一条语句中删除的列，删除列时 MySQL 会同时修改包含这些列的索引
+	droppedColumns := make(map[string]bool)
+	for _, spec := range node.Specs {
+		if spec.Tp == ast.AlterTableDropColumn {
+			droppedColumns[spec.OldColumnName.Name.L] = true
+		}
+	}
+	columnsRestored := false
+
+	for _, spec := range node.Specs {
+		var inverse []string
+		switch spec.Tp {
+		case ast.AlterTableAddColumns:
+			for _, col := range spec.NewColumns {
+				inverse = append(inverse, fmt.Sprintf("DROP COLUMN `%s`", col.Name.Name.O))
+			}
+
+		case ast.AlterTableDropColumn:
+			if !needCurrent(spec) {
+				continue
+			}
+			name := spec.OldColumnName.Name.L
+			if _, ok := cur.ColumnDefs[name]; !ok {
+				warn("表 %s 中不存在列 `%s`，无需回滚", table, spec.OldColumnName.Name.O)
+				continue
+			}
+			// 所有被删除的列在第一个 DROP COLUMN 子句中按原表中的顺序加回，回滚时该子句最后执行
+			if !columnsRestored {
+				columnsRestored = true
+				for _, col := range cur.Columns {
+					if droppedColumns[col] {
+						inverse = append(inverse, fmt.Sprintf("ADD COLUMN %s %s", cur.ColumnDefs[col], cur.columnPosition(col)))
+					}
+				}
+				inverse = append(inverse, restoreKeyColumns(cur, droppedColumns)...)
+			}
+			warn("列 `%s` 被删除后数据无法通过 DDL 恢复，回滚后该列的值为默认值，请提前备份 %s 中该列的数据",
+				spec.OldColumnName.Name.O, table)
+
+		case ast.AlterTableModifyColumn, ast.AlterTableChangeColumn:
+			if !needCurrent(spec) {
+				continue
+			}
+			for _, col := range spec.NewColumns {
+				oldName := col.Name.Name.L
+				if spec.OldColumnName != nil {
+					oldName = spec.OldColumnName.Name.L
+				}
+				def, ok := cur.ColumnDefs[oldName]
+				if !ok {
+					warn("表 %s 中不存在列 `%s`，无法回滚 %s", table, oldName, restoreNode(spec))
+					continue
+				}
+				var position string
+				if spec.Position != nil && spec.Position.Tp != ast.ColumnPositionNone {
+					position = " " + cur.columnPosition(oldName)
+				}
+				if spec.Tp == ast.AlterTableChangeColumn {
+					inverse = append(inverse, fmt.Sprintf("CHANGE COLUMN `%s` %s%s", col.Name.Name.O, def, position))
+				} else {
+					inverse = append(inverse, fmt.Sprintf("MODIFY COLUMN %s%s", def, position))
+				}
+				warn("修改列 `%s` 的数据类型、长度或字符集可能导致数据被截断或转换，回滚无法恢复变更前的原始数据", oldName)
+			}
+
+		case ast.AlterTableRenameColumn:
+			inverse = append(inverse, fmt.Sprintf("RENAME COLUMN `%s` TO `%s`",
+				spec.NewColumnName.Name.O, spec.OldColumnName.Name.O))
+
+		case ast.AlterTableAlterColumn:
+			if !needCurrent(spec) {
+				continue
+			}
+			col := cur.column(spec.NewColumns[0].Name.Name.L)
+			if col == nil {
+				warn("表 %s 中不存在列 `%s`，无法回滚 %s", table, spec.NewColumns[0].Name.Name.O, restoreNode(spec))
+				continue
+			}
+			inverse = append(inverse, alterColumnDefault(col))
+
+		case ast.AlterTableAddConstraint:
+			inverse = append(inverse, dropConstraint(spec.Constraint, warn)...)
+
+		case ast.AlterTableDropPrimaryKey:
+			if !needCurrent(spec) {
+				continue
+			}
+			if def, ok := cur.IndexDefs["primary"]; ok {
+				inverse = append(inverse, "ADD "+def)
+			}
+
+		case ast.AlterTableDropIndex:
+			if !needCurrent(spec) {
+				continue
+			}
+			def, ok := cur.IndexDefs[strings.ToLower(spec.Name)]
+			if !ok {
+				warn("表 %s 中不存在索引 `%s`，无需回滚", table, spec.Name)
+				continue
+			}
+			inverse = append(inverse, "ADD "+def)
+
+		case ast.AlterTableDropForeignKey:
+			if !needCurrent(spec) {
+				continue
+			}
+			def, ok := cur.ForeignKey[strings.ToLower(spec.Name)]
+			if !ok {
+				warn("表 %s 中不存在外键 `%s`，无需回滚", table, spec.Name)
+				continue
+			}
+			inverse = append(inverse, "ADD "+def)
+
+		case ast.AlterTableRenameIndex:
+			inverse = append(inverse, fmt.Sprintf("RENAME INDEX `%s` TO `%s`", spec.ToKey.O, spec.FromKey.O))
+
+		case ast.AlterTableIndexInvisible:
+			visibility := "INVISIBLE"
+			if spec.Visibility == ast.IndexVisibilityInvisible {
+				visibility = "VISIBLE"
+			}
+			inverse = append(inverse, fmt.Sprintf("ALTER INDEX `%s` %s", spec.IndexName.O, visibility))
+
+		case ast.AlterTableRenameTable:
+			renameTo = restoreNode(spec.NewTable)
+
+		case ast.AlterTableOption:
+			if !needCurrent(spec) {
+				continue
+			}
+			charset := false
+			for _, opt := range spec.Options {
+				if opt.Tp == ast.TableOptionCharset || opt.Tp == ast.TableOptionCollate {
+					if charset {
+						continue
+					}
+					charset = true
+				}
+				inverse = append(inverse, rollbackTableOption(opt, cur, warn)...)
+			}
+
+		case ast.AlterTableLock, ast.AlterTableAlgorithm, ast.AlterTableForce:
+			// 不改变表结构，无需回滚
+
+		default:
+			warn("不支持生成 %s 的回滚语句", restoreNode(spec))
+		}
+		specs = append(inverse, specs...)
+	}
+
+	if renameTo != "" {
+		// 回滚语句需要在重命名后的表上执行，再将表名改回
+		specs = append(specs, "RENAME TO "+table)
+		table = renameTo
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s %s", table, strings.Join(specs, ", "))}
+}
+
+// restoreKeyColumns 恢复删除列时被 MySQL 修改的索引，只包含被删除列的索引会被删除，其他索引会去掉被删除的列
+func restoreKeyColumns(cur *tableSchema, dropped map[string]bool) []string {
+	var specs []string
+	for _, cons := range cur.Create.Constraints {
+		if cons.Tp == ast.ConstraintForeignKey || cons.Tp == ast.ConstraintCheck {
+			continue
+		}
+		index := strings.ToLower(cons.Name)
+		drop := fmt.Sprintf("DROP INDEX `%s`", cons.Name)
+		if cons.Tp == ast.ConstraintPrimaryKey {
+			index, drop = "primary", "DROP PRIMARY KEY"
+		}
+		def, ok := cur.IndexDefs[index]
+		if !ok {
+			continue
+		}
+		contains, remain := false, false
+		for _, key := range cons.Keys {
+			if key.Column != nil && dropped[key.Column.Name.L] {
+				contains = true
+			} else {
+				remain = true
+			}
+		}
+		if !contains {
+			continue
+		}
+		// 索引中还有其他列时，删除列后的索引依然存在
+		if remain {
+			specs = append(specs, drop)
+		}
+		specs = append(specs, "ADD "+def)
+	}
+	return specs
+}
+
+// dropConstraint 生成删除约束的子句
+func dropConstraint(cons *ast.Constraint, warn func(format string, a ...interface{})) []string {
+	switch cons.Tp {
+	case ast.ConstraintPrimaryKey:
+		return []string{"DROP PRIMARY KEY"}
+	case ast.ConstraintForeignKey:
+		if cons.Name == "" {
+			warn("添加外键时未指定名称，MySQL 会自动生成 tbl_ibfk_N 形式的名称，请使用 SHOW CREATE TABLE 确认后删除")
+			return nil
+		}
+		return []string{fmt.Sprintf("DROP FOREIGN KEY `%s`", cons.Name)}
+	case ast.ConstraintCheck:
+		if cons.Name == "" {
+			warn("添加 CHECK 约束时未指定名称，请使用 SHOW CREATE TABLE 确认名称后删除")
+			return nil
+		}
+		return []string{fmt.Sprintf("DROP CHECK `%s`", cons.Name)}
+	default:
+		name := cons.Name
+		if name == "" && len(cons.Keys) > 0 && cons.Keys[0].Column != nil {
+			// 未指定索引名时 MySQL 使用第一列的列名作为索引名
+			name = cons.Keys[0].Column.Name.O
+			warn("添加索引时未指定索引名，按 MySQL 默认命名规则推测索引名为 `%s`，如有同名索引实际名称会添加 _2 等后缀", name)
+		}
+		if name == "" {
+			warn("无法确定索引名，无法回滚 %s", restoreNode(cons))
+			return nil
+		}
+		return []string{fmt.Sprintf("DROP INDEX `%s`", name)}
+	}
+}
+
+// rollbackTableOption 根据原表属性生成回滚子句
+func rollbackTableOption(opt *ast.TableOption, cur *tableSchema, warn func(format string, a ...interface{})) []string {
+	var origin *ast.TableOption
+	var charset, collate string
+	for _, o := range cur.Create.Options {
+		if o.Tp == opt.Tp {
+			origin = o
+		}
+		switch o.Tp {
+		case ast.TableOptionCharset:
+			charset = o.StrValue
+		case ast.TableOptionCollate:
+			collate = o.StrValue
+		}
+	}
+
+	switch opt.Tp {
+	case ast.TableOptionCharset, ast.TableOptionCollate:
+		// 字符集和排序规则一起回滚
+		if charset == "" {
+			warn("无法获取表原来的字符集，无法回滚 %s", restoreNode(opt))
+			return nil
+		}
+		clause := "DEFAULT CHARACTER SET = " + charset
+		if opt.UintValue == ast.TableOptionCharsetWithConvertTo {
+			clause = "CONVERT TO CHARACTER SET " + charset
+			warn("CONVERT TO CHARACTER SET 会修改所有字符串列的字符集，回滚无法恢复各列原来单独指定的字符集")
+		}
+		if collate != "" {
+			clause += " COLLATE " + collate
+		}
+		return []string{clause}
+	case ast.TableOptionComment:
+		if origin == nil {
+			return []string{"COMMENT = ''"}
+		}
+	case ast.TableOptionAutoIncrement:
+		warn("AUTO_INCREMENT 的值无法回滚")
+		return nil
+	}
+
+	if origin == nil {
+		warn("表原来未指定 %s，请手动确认回滚方式", restoreNode(opt))
+		return nil
+	}
+	return []string{restoreNode(origin)}
+}
+
+// alterColumnDefault 根据原列定义生成 SET DEFAULT 或 DROP DEFAULT 子句
+func alterColumnDefault(col *ast.ColumnDef) string {
+	for _, opt := range col.Options {
+		if opt.Tp == ast.ColumnOptionDefaultValue {
+			return fmt.Sprintf("ALTER COLUMN `%s` SET DEFAULT %s", col.Name.Name.O,
+				strings.TrimPrefix(restoreNode(opt), "DEFAULT "))
+		}
+	}
+	return fmt.Sprintf("ALTER COLUMN `%s` DROP DEFAULT", col.Name.Name.O)
+}
+
+// column 按列名查找列定义
+func (tb *tableSchema) column(name string) *ast.ColumnDef {
+	for _, col := range tb.Create.Cols {
+		if col.Name.Name.L == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// columnPosition 列在原表中的位置
+func (tb *tableSchema) columnPosition(name string) string {
+	for i, col := range tb.Columns {
+		if col == name {
+			if i == 0 {
+				return "FIRST"
+			}
+			return fmt.Sprintf("AFTER `%s`", tb.Create.Cols[i-1].Name.Name.O)
+		}
+	}
+	return ""
+}
+
+// createNameExp SHOW CREATE TABLE 结果中的表名或视图名
+var createNameExp = regexp.MustCompile("(?is)^(\\s*CREATE\\s+(?:.*?\\s)?(?:TABLE|VIEW)\\s+)(`[^`]+`)(\\s)")
+
+// qualifyCreate 为 SHOW CREATE TABLE 获取到的建表语句加上库名，使用文本替换以保留原始定义
+func qualifyCreate(create string, table *ast.TableName) string {
+	return createNameExp.ReplaceAllString(create, "${1}"+restoreNode(table)+"${3}")
+}
+
+// tableKey 表在 DDLRollback 中的索引，未指定库名时库名为空
+func tableKey(table *ast.TableName) string {
+	return table.Schema.L + "." + table.Name.L
+}
+
+// load 获取变更前的表结构，每张表只获取一次
+func (r *DDLRollback) load(table *ast.TableName) {
+	key := tableKey(table)
+	if _, ok := r.tables[key]; ok {
+		return
+	}
+	r.tables[key] = nil
+	if r.droppedDBs[table.Schema.L] {
+		return
+	}
+	create := r.showCreate(table.Schema.O, table.Name.O)
+	if create == "" {
+		return
+	}
+	r.creates[key] = create
+	// 视图的定义无法解析为表结构，只保留原始语句
+	if tb, err := newTableSchema(create); err == nil {
+		r.tables[key] = tb
+	}
+}
+
+// current 获取表在当前语句执行前的表结构
+func (r *DDLRollback) current(table *ast.TableName) *tableSchema {
+	r.load(table)
+	return r.tables[tableKey(table)]
+}
+
+// create 获取表在当前语句执行前的建表语句，表被之前的语句修改过时由内存中的表结构生成
+func (r *DDLRollback) create(table *ast.TableName) string {
+	r.load(table)
+	key := tableKey(table)
+	if create, ok := r.creates[key]; ok {
+		return create
+	}
+	if tb := r.tables[key]; tb != nil {
+		return tb.createTable(fmt.Sprintf("`%s`", table.Name.O))
+	}
+	return ""
+}
+
+// set 更新内存中的表结构，表结构被修改后原始建表语句不再可用
+func (r *DDLRollback) set(table *ast.TableName, tb *tableSchema) {
+	key := tableKey(table)
+	delete(r.creates, key)
+	r.tables[key] = tb
+}
+
+// rename 将表结构移动到新表名下
+func (r *DDLRollback) rename(from, to *ast.TableName) {
+	tb := r.current(from)
+	r.set(from, nil)
+	if tb != nil {
+		tb.Name = to.Name.L
+	}
+	r.set(to, tb)
+}
+
+// apply 将语句的变更应用到内存中的表结构上
+func (r *DDLRollback) apply(stmt ast.StmtNode, sql string) {
+	switch node := stmt.(type) {
+	case *ast.CreateTableStmt:
+		if r.current(node.Table) != nil {
+			return
+		}
+		switch {
+		case node.ReferTable != nil:
+			// CREATE TABLE ... LIKE 不复制外键
+			if ref := r.current(node.ReferTable); ref != nil {
+				if tb, err := newTableSchema(ref.createTable(fmt.Sprintf("`%s`", node.Table.Name.O))); err == nil {
+					tb.dropConstraints(func(cons *ast.Constraint) bool { return cons.Tp == ast.ConstraintForeignKey })
+					r.set(node.Table, tb)
+				}
+			}
+		case node.Select == nil:
+			r.set(node.Table, buildTableSchema(node, func(cons *ast.Constraint) string {
+				return foreignKeyDef(sql, cons)
+			}))
+		}
+
+	case *ast.DropTableStmt:
+		for _, table := range node.Tables {
+			r.set(table, nil)
+		}
+
+	case *ast.CreateIndexStmt:
+		if tb := r.current(node.Table); tb != nil {
+			cons := &ast.Constraint{
+				Tp:   ast.ConstraintIndex,
+				Name: node.IndexName,
+				Keys: node.IndexPartSpecifications,
+			}
+			if node.IndexOption != nil && restoreNode(node.IndexOption) != "" {
+				cons.Option = node.IndexOption
+			}
+			switch node.KeyType {
+			case ast.IndexKeyTypeUnique:
+				cons.Tp = ast.ConstraintUniq
+			case ast.IndexKeyTypeFullText:
+				cons.Tp = ast.ConstraintFulltext
+			}
+			tb.Create.Constraints = append(tb.Create.Constraints, cons)
+			r.set(node.Table, tb.rebuild())
+		}
+
+	case *ast.DropIndexStmt:
+		if tb := r.current(node.Table); tb != nil {
+			tb.dropIndex(node.IndexName)
+			r.set(node.Table, tb.rebuild())
+		}
+
+	case *ast.RenameTableStmt:
+		for _, t := range node.TableToTables {
+			r.rename(t.OldTable, t.NewTable)
+		}
+
+	case *ast.DropDatabaseStmt:
+		r.droppedDBs[strings.ToLower(node.Name)] = true
+		for key := range r.tables {
+			if strings.HasPrefix(key, strings.ToLower(node.Name)+".") {
+				r.tables[key] = nil
+				delete(r.creates, key)
+			}
+		}
+
+	case *ast.CreateViewStmt:
+		view := *node
+		view.OrReplace = false
+		r.set(node.ViewName, nil)
+		r.creates[tableKey(node.ViewName)] = restoreNode(&view)
+
+	case *ast.AlterTableStmt:
+		tb := r.current(node.Table)
+		if tb == nil {
+			return
+		}
+		var renameTo *ast.TableName
+		for _, spec := range node.Specs {
+			if spec.Tp == ast.AlterTableRenameTable {
+				renameTo = spec.NewTable
+				continue
+			}
+			tb.alter(spec)
+		}
+		r.set(node.Table, tb.rebuild())
+		if renameTo != nil {
+			r.rename(node.Table, renameTo)
+		}
+	}
+}
+
+// alter 将 ALTER TABLE 子句应用到建表语句的语法树上，不影响表结构的子句会被忽略
+func (tb *tableSchema) alter(spec *ast.AlterTableSpec) {
+	create := tb.Create
+	switch spec.Tp {
+	case ast.AlterTableAddColumns:
+		for _, col := range spec.NewColumns {
+			create.Cols = insertColumn(create.Cols, col, spec.Position)
+		}
+		create.Constraints = append(create.Constraints, spec.NewConstraints...)
+
+	case ast.AlterTableDropColumn:
+		name := spec.OldColumnName.Name.L
+		create.Cols = removeColumn(create.Cols, name)
+		tb.dropKeyColumn(name)
+
+	case ast.AlterTableModifyColumn, ast.AlterTableChangeColumn:
+		col := spec.NewColumns[0]
+		oldName := col.Name.Name.L
+		if spec.OldColumnName != nil {
+			oldName = spec.OldColumnName.Name.L
+		}
+		i := columnIndex(create.Cols, oldName)
+		if i < 0 {
+			return
+		}
+		if spec.Position != nil && spec.Position.Tp != ast.ColumnPositionNone {
+			create.Cols = insertColumn(removeColumn(create.Cols, oldName), col, spec.Position)
+		} else {
+			create.Cols[i] = col
+		}
+		tb.renameKeyColumn(oldName, col.Name)
+
+	case ast.AlterTableRenameColumn:
+		i := columnIndex(create.Cols, spec.OldColumnName.Name.L)
+		if i < 0 {
+			return
+		}
+		col := *create.Cols[i]
+		col.Name = spec.NewColumnName
+		create.Cols[i] = &col
+		tb.renameKeyColumn(spec.OldColumnName.Name.L, spec.NewColumnName)
+
+	case ast.AlterTableAlterColumn:
+		i := columnIndex(create.Cols, spec.NewColumns[0].Name.Name.L)
+		if i < 0 {
+			return
+		}
+		// SET DEFAULT 时新的默认值在 spec.NewColumns[0].Options 中，DROP DEFAULT 时为空
+		col := *create.Cols[i]
+		col.Options = nil
+		for _, opt := range create.Cols[i].Options {
+			if opt.Tp != ast.ColumnOptionDefaultValue {
+				col.Options = append(col.Options, opt)
+			}
+		}
+		col.Options = append(col.Options, spec.NewColumns[0].Options...)
+		create.Cols[i] = &col
+
+	case ast.AlterTableAddConstraint:
+		cons := spec.Constraint
+		if cons.Name == "" && cons.Tp != ast.ConstraintPrimaryKey && cons.Tp != ast.ConstraintForeignKey &&
+			cons.Tp != ast.ConstraintCheck && len(cons.Keys) > 0 && cons.Keys[0].Column != nil {
+			// 与 dropConstraint 一致，未指定索引名时使用第一列的列名
+			named := *cons
+			named.Name = cons.Keys[0].Column.Name.O
+			cons = &named
+		}
+		create.Constraints = append(create.Constraints, cons)
+
+	case ast.AlterTableDropPrimaryKey:
+		tb.dropIndex("primary")
+
+	case ast.AlterTableDropIndex:
+		tb.dropIndex(spec.Name)
+
+	case ast.AlterTableDropForeignKey:
+		tb.dropConstraints(func(cons *ast.Constraint) bool {
+			return cons.Tp == ast.ConstraintForeignKey && strings.EqualFold(cons.Name, spec.Name)
+		})
+
+	case ast.AlterTableRenameIndex:
+		for i, cons := range create.Constraints {
+			if cons.Tp != ast.ConstraintForeignKey && strings.EqualFold(cons.Name, spec.FromKey.O) {
+				renamed := *cons
+				renamed.Name = spec.ToKey.O
+				create.Constraints[i] = &renamed
+			}
+		}
+
+	case ast.AlterTableOption:
+		collate := false
+		for _, opt := range spec.Options {
+			collate = collate || opt.Tp == ast.TableOptionCollate
+		}
+		for _, opt := range spec.Options {
+			o := *opt
+			if o.Tp == ast.TableOptionCharset {
+				// CONVERT TO CHARACTER SET 同样修改表的默认字符集，只修改字符集时排序规则恢复为字符集的默认值
+				o.UintValue = 0
+				if !collate {
+					tb.setTableOption(ast.TableOptionCollate, nil)
+				}
+			}
+			tb.setTableOption(o.Tp, &o)
+		}
+	}
+}
+
+// rebuild 根据修改后的语法树重新生成表结构，保留已有外键的原始定义
+func (tb *tableSchema) rebuild() *tableSchema {
+	fks := tb.ForeignKey
+	rebuilt := buildTableSchema(tb.Create, func(cons *ast.Constraint) string {
+		if def, ok := fks[strings.ToLower(cons.Name)]; ok {
+			return def
+		}
+		return restoreNode(cons)
+	})
+	rebuilt.Name = tb.Name
+	return rebuilt
+}
+
+// createTable 由表结构生成建表语句，外键使用原始定义
+func (tb *tableSchema) createTable(name string) string {
+	var defs, options []string
+	for _, col := range tb.Columns {
+		defs = append(defs, tb.ColumnDefs[col])
+	}
+	for _, idx := range tb.Indexes {
+		defs = append(defs, tb.IndexDefs[idx])
+	}
+	for _, fk := range tb.FKNames {
+		defs = append(defs, tb.ForeignKey[fk])
+	}
+	for _, opt := range tb.Create.Options {
+		options = append(options, restoreNode(opt))
+	}
+	return strings.TrimSpace(fmt.Sprintf("CREATE TABLE %s (\n  %s\n) %s", name, strings.Join(defs, ",\n  "),
+		strings.Join(options, " ")))
+}
+
+// dropConstraints 删除满足条件的约束
+func (tb *tableSchema) dropConstraints(match func(cons *ast.Constraint) bool) {
+	var constraints []*ast.Constraint
+	for _, cons := range tb.Create.Constraints {
+		if !match(cons) {
+			constraints = append(constraints, cons)
+		}
+	}
+	tb.Create.Constraints = constraints
+}
+
+// dropIndex 删除索引，primary 表示主键
+func (tb *tableSchema) dropIndex(name string) {
+	tb.dropConstraints(func(cons *ast.Constraint) bool {
+		switch cons.Tp {
+		case ast.ConstraintPrimaryKey:
+			return strings.EqualFold(name, "primary")
+		case ast.ConstraintForeignKey, ast.ConstraintCheck:
+			return false
+		default:
+			return strings.EqualFold(cons.Name, name)
+		}
+	})
+}
+
+// dropKeyColumn 删除列后，MySQL 会从索引中去掉该列，索引中没有列时删除索引
+func (tb *tableSchema) dropKeyColumn(name string) {
+	for i, cons := range tb.Create.Constraints {
+		if cons.Tp == ast.ConstraintForeignKey {
+			continue
+		}
+		var keys []*ast.IndexPartSpecification
+		for _, key := range cons.Keys {
+			if key.Column == nil || key.Column.Name.L != name {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) != len(cons.Keys) {
+			c := *cons
+			c.Keys = keys
+			tb.Create.Constraints[i] = &c
+		}
+	}
+	tb.dropConstraints(func(cons *ast.Constraint) bool {
+		return cons.Tp != ast.ConstraintForeignKey && cons.Tp != ast.ConstraintCheck && len(cons.Keys) == 0
+	})
+}
+
+// renameKeyColumn 列被重命名后，同步修改索引中的列名
+func (tb *tableSchema) renameKeyColumn(oldName string, newName *ast.ColumnName) {
+	if oldName == newName.Name.L {
+		return
+	}
+	for i, cons := range tb.Create.Constraints {
+		if cons.Tp == ast.ConstraintForeignKey {
+			continue
+		}
+		keys := make([]*ast.IndexPartSpecification, len(cons.Keys))
+		renamed := false
+		for j, key := range cons.Keys {
+			keys[j] = key
+			if key.Column != nil && key.Column.Name.L == oldName {
+				k := *key
+				k.Column = newName
+				keys[j], renamed = &k, true
+			}
+		}
+		if renamed {
+			c := *cons
+			c.Keys = keys
+			tb.Create.Constraints[i] = &c
+		}
+	}
+}
+
+// setTableOption 修改表属性，opt 为 nil 时删除该属性
+func (tb *tableSchema) setTableOption(tp ast.TableOptionType, opt *ast.TableOption) {
+	var options []*ast.TableOption
+	for _, o := range tb.Create.Options {
+		if o.Tp != tp {
+			options = append(options, o)
+		}
+	}
+	if opt != nil {
+		options = append(options, opt)
+	}
+	tb.Create.Options = options
+}
+
+// columnIndex 列在建表语句中的位置，不存在时返回 -1
+func columnIndex(cols []*ast.ColumnDef, name string) int {
+	for i, col := range cols {
+		if col.Name.Name.L == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeColumn 删除列
+func removeColumn(cols []*ast.ColumnDef, name string) []*ast.ColumnDef {
+	var result []*ast.ColumnDef
+	for _, col := range cols {
+		if col.Name.Name.L != name {
+			result = append(result, col)
+		}
+	}
+	return result
+}
+
+// insertColumn 按 FIRST 或 AFTER 指定的位置插入列，未指定位置时添加到最后
+func insertColumn(cols []*ast.ColumnDef, col *ast.ColumnDef, position *ast.ColumnPosition) []*ast.ColumnDef {
+	i := len(cols)
+	if position != nil {
+		switch position.Tp {
+		case ast.ColumnPositionFirst:
+			i = 0
+		case ast.ColumnPositionAfter:
+			if j := columnIndex(cols, position.RelativeColumn.Name.L); j >= 0 {
+				i = j + 1
+			}
+		}
+	}
+	result := append([]*ast.ColumnDef{}, cols[:i]...)
+	result = append(result, col)
+	return append(result, cols[i:]...)
+}
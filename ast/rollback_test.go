@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestRollbackDDL(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	creates := map[string]string{
+		"city": "CREATE TABLE `city` (\n" +
+			"  `city_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `city` varchar(50) NOT NULL,\n" +
+			"  `country_id` smallint(5) unsigned NOT NULL,\n" +
+			"  `last_update` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+			"  PRIMARY KEY (`city_id`),\n" +
+			"  KEY `idx_fk_country_id` (`country_id`),\n" +
+			"  CONSTRAINT `fk_city_country` FOREIGN KEY (`country_id`) REFERENCES `country` (`country_id`) ON UPDATE CASCADE\n" +
+			") ENGINE=InnoDB AUTO_INCREMENT=601 DEFAULT CHARSET=utf8 COMMENT='city'",
+		"t4": "CREATE TABLE `t4` (\n" +
+			"  `id` int(11) NOT NULL,\n" +
+			"  `a` int(11) DEFAULT NULL,\n" +
+			"  `b` int(11) DEFAULT NULL,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  KEY `idx_b` (`b`),\n" +
+			"  UNIQUE KEY `uk_a_b` (`a`,`b`)\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+	}
+	showCreate := func(db, table string) string {
+		return creates[table]
+	}
+
+	sqls := []string{
+		"CREATE TABLE t1 (id int primary key)",
+		"CREATE TABLE IF NOT EXISTS city (id int primary key)",
+		"DROP TABLE sakila.city, t2",
+		"TRUNCATE TABLE city",
+		"CREATE INDEX idx_city ON city(city)",
+		"DROP INDEX idx_fk_country_id ON city",
+		"RENAME TABLE a TO b, c TO d",
+		"CREATE DATABASE IF NOT EXISTS db1",
+		"DROP DATABASE db1",
+		"CREATE VIEW v1 AS SELECT 1",
+		"ALTER TABLE city ADD COLUMN c1 int, ADD INDEX (c1), ADD UNIQUE KEY uk_city (city)",
+		"ALTER TABLE city DROP COLUMN city, DROP COLUMN not_exists",
+		"ALTER TABLE city MODIFY COLUMN city varchar(100) NOT NULL AFTER country_id, CHANGE country_id cid int",
+		"ALTER TABLE city RENAME COLUMN city TO city_name, RENAME INDEX idx_fk_country_id TO idx_country",
+		"ALTER TABLE city ALTER COLUMN last_update DROP DEFAULT, ALTER COLUMN city SET DEFAULT 'x'",
+		"ALTER TABLE city DROP PRIMARY KEY, DROP INDEX idx_fk_country_id, DROP FOREIGN KEY fk_city_country",
+		"ALTER TABLE city ENGINE = MyISAM, COMMENT = 'new comment', DEFAULT CHARSET = utf8mb4, AUTO_INCREMENT = 1000",
+		"ALTER TABLE city CONVERT TO CHARACTER SET utf8mb4",
+		"ALTER TABLE city ADD CONSTRAINT FOREIGN KEY (c1) REFERENCES t1(id), RENAME TO city_new",
+		"ALTER TABLE t3 DROP COLUMN c",
+		// 删除列时只包含该列的索引被删除，联合索引中去掉该列
+		"ALTER TABLE t4 DROP COLUMN b",
+		"ALTER TABLE t4 DROP COLUMN a, DROP COLUMN b",
+	}
+	err := common.GoldenDiff(func() {
+		for _, sql := range sqls {
+			fmt.Println("-- " + sql)
+			rollback, warnings, err := RollbackDDL(sql, showCreate)
+			if err != nil {
+				t.Error(err)
+			}
+			for _, w := range warnings {
+				fmt.Println("-- 警告: " + w)
+			}
+			for _, r := range rollback {
+				fmt.Println(r)
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestDDLRollback(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	creates := map[string]string{
+		"city": "CREATE TABLE `city` (\n" +
+			"  `city_id` smallint(5) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `city` varchar(50) NOT NULL,\n" +
+			"  `country_id` smallint(5) unsigned NOT NULL,\n" +
+			"  PRIMARY KEY (`city_id`),\n" +
+			"  KEY `idx_fk_country_id` (`country_id`),\n" +
+			"  CONSTRAINT `fk_city_country` FOREIGN KEY (`country_id`) REFERENCES `country` (`country_id`) ON UPDATE CASCADE\n" +
+			") ENGINE=InnoDB AUTO_INCREMENT=601 DEFAULT CHARSET=utf8",
+	}
+	showCreate := func(db, table string) string {
+		return creates[table]
+	}
+
+	// 后面的语句基于前面语句执行后的表结构回滚
+	sqls := []string{
+		"ALTER TABLE city ADD COLUMN c int",
+		"ALTER TABLE city MODIFY c BIGINT NOT NULL",
+		"CREATE INDEX idx_c ON city(c)",
+		"ALTER TABLE city RENAME COLUMN c TO c1",
+		"DROP INDEX idx_c ON city",
+		"ALTER TABLE city CHANGE city city_name varchar(64) NOT NULL",
+		"ALTER TABLE city DROP COLUMN city_name",
+		"ALTER TABLE city DEFAULT CHARSET = utf8mb4",
+		"ALTER TABLE city DROP INDEX idx_fk_country_id",
+		"CREATE TABLE t1 (id int primary key, name varchar(10))",
+		"ALTER TABLE t1 DROP COLUMN name",
+		"RENAME TABLE t1 TO t2",
+		"DROP TABLE t2, city",
+	}
+	r := NewDDLRollback(showCreate)
+	err := common.GoldenDiff(func() {
+		for _, sql := range sqls {
+			fmt.Println("-- " + sql)
+			rollback, warnings, err := r.Rollback(sql)
+			if err != nil {
+				t.Error(err)
+			}
+			for _, w := range warnings {
+				fmt.Println("-- 警告: " + w)
+			}
+			for _, r := range rollback {
+				fmt.Println(r)
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -19,7 +19,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/XiaoMi/soar/advisor"
@@ -85,8 +87,49 @@ func main() {
 		return
 	}
 
-	// 读入待优化 SQL ，当配置文件或命令行参数未指定 SQL 时从管道读取
-	buf := initQuery(common.Config.Query)
+	// 根据 performance_schema 中的统计信息检查未使用的索引
+	if common.Config.ReportType == "unused-index" {
+		unusedSuggest := advisor.UnusedIndexChecker(rEnv)
+		if len(unusedSuggest) == 0 {
+			fmt.Printf("%s/%s 未发现未使用的索引\n", common.Config.OnlineDSN.Addr, common.Config.OnlineDSN.Schema)
+		} else {
+			_, str := advisor.FormatSuggest("", currentDB, common.Config.ReportType, unusedSuggest)
+			fmt.Println(str)
+		}
+		return
+	}
+
+	// 对指定的库进行表结构审计
+	if common.Config.ReportType == "schema-audit" {
+		if common.Config.SchemaAuditFormat == "html" {
+			fmt.Println(common.MarkdownHTMLHeader())
+		}
+		fmt.Println(advisor.FormatSchemaAudit(advisor.SchemaAudit(rEnv), common.Config.SchemaAuditFormat))
+		return
+	}
+
+	// 比较两个环境的表结构，生成变更语句
+	if common.Config.ReportType == "schema-diff" {
+		diff, err := advisor.SchemaDiff(rEnv, common.Config.SchemaDiffSource)
+		if err != nil {
+			common.Log.Error("SchemaDiff Error: %s", err.Error())
+			os.Exit(1)
+		}
+		if diff == "" {
+			fmt.Printf("%s/%s 与 %s 表结构一致\n", common.Config.OnlineDSN.Addr, common.Config.OnlineDSN.Schema,
+				regexp.MustCompile(`:.*@`).ReplaceAllString(common.Config.SchemaDiffSource, ":********@"))
+		} else {
+			fmt.Println(diff)
+		}
+		return
+	}
+
+	// 读入待优化 SQL ，当配置文件或命令行参数未指定 SQL 时从管道读取，指定 -source 时从线上环境获取
+	var buf string
+	// binlog 及 pcap 为二进制文件，直接读取 -query 中逗号分隔的文件
+	if common.Config.Source == "" && common.Config.InputFormat != "binlog" && common.Config.InputFormat != "pcap" {
+		buf = initQuery(common.Config.Query)
+	}
 	lineCounter += ast.LeftNewLines([]byte(buf))
 	buf = strings.TrimSpace(buf)
 
@@ -98,6 +141,118 @@ func main() {
 		os.Exit(exitCode)
 	}
 
+	// 输入为日志或从线上环境获取 SQL 时，按 SQL 指纹聚合后评审总执行时间最长的几类 SQL
+	var digests []*database.QueryDigest
+	var processes []*database.ProcessDigest
+	var binlogTables []*database.BinlogTableStat
+	switch {
+	case common.Config.Source == "digest":
+		if common.Config.OnlineDSN.Disable {
+			common.Log.Error("-source digest need -online-dsn")
+			os.Exit(1)
+		}
+		digests, err = rEnv.StatementDigests(common.Config.TopQueries)
+		if err != nil {
+			common.Log.Error("StatementDigests Error: %s", err.Error())
+			os.Exit(1)
+		}
+		buf = database.QueryDigests2SQL(digests)
+	case common.Config.Source == "processlist":
+		if common.Config.OnlineDSN.Disable {
+			common.Log.Error("-source processlist need -online-dsn")
+			os.Exit(1)
+		}
+		processes, err = sampleProcesslist(rEnv)
+		if err != nil {
+			common.Log.Error("SampleProcesslist Error: %s", err.Error())
+			os.Exit(1)
+		}
+		buf = database.ProcessDigests2SQL(processes)
+	case common.Config.InputFormat == "binlog":
+		var events []database.QueryEvent
+		events, binlogTables, err = database.ParseBinlogFiles(strings.Split(common.Config.Query, ","))
+		if err != nil {
+			common.Log.Error("ParseBinlogFiles Error: %s", err.Error())
+			os.Exit(1)
+		}
+		digests = database.TopQueryDigests(database.AggregateQueryEvents(events), common.Config.TopQueries)
+		buf = database.QueryDigests2SQL(digests)
+	case common.Config.InputFormat == "pcap":
+		events, err := database.ParsePcapFiles(strings.Split(common.Config.Query, ","), common.Config.PcapPort)
+		if err != nil {
+			common.Log.Error("ParsePcapFiles Error: %s", err.Error())
+			os.Exit(1)
+		}
+		digests = database.TopQueryDigests(database.AggregateQueryEvents(events), common.Config.TopQueries)
+		buf = database.QueryDigests2SQL(digests)
+	case common.Config.InputFormat != "sql":
+		events, err := database.ParseQueryLog(common.Config.InputFormat, strings.NewReader(buf))
+		if err != nil {
+			common.Log.Error("ParseQueryLog Error: %s", err.Error())
+			os.Exit(1)
+		}
+		digests = database.TopQueryDigests(database.AggregateQueryEvents(events), common.Config.TopQueries)
+		buf = database.QueryDigests2SQL(digests)
+	}
+	digestIndex := make(map[string]*database.QueryDigest)
+	for _, digest := range digests {
+		digestIndex[digest.ID] = digest
+	}
+	processIndex := make(map[string]*database.ProcessDigest)
+	for _, process := range processes {
+		processIndex[process.ID] = process
+	}
+
+	// 生成 DDL 的回滚语句
+	if common.Config.ReportType == "rollback" {
+		fmt.Println(advisor.Rollback(rEnv, buf))
+		return
+	}
+
+	// 备份 UPDATE, DELETE 影响的数据并生成闪回语句
+	if common.Config.ReportType == "flashback" {
+		flashback := advisor.FormatFlashback(advisor.Flashback(rEnv, buf))
+		if common.Config.FlashbackFile == "" {
+			fmt.Println(flashback)
+			return
+		}
+		if err = ioutil.WriteFile(common.Config.FlashbackFile, []byte(flashback+"\n"), 0600); err != nil {
+			common.Log.Error("write flashback file Error: %s", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("闪回语句已写入 %s\n", common.Config.FlashbackFile)
+		return
+	}
+
+	// 根据 ALTER 语句生成 gh-ost, pt-online-schema-change 命令
+	if common.Config.ReportType == "osc" {
+		fmt.Println(advisor.FormatOSC(advisor.OnlineSchemaChange(rEnv, buf), common.Config.OnlineDSN))
+		return
+	}
+
+	// 对比执行计划的目标环境，未指定 -compare-explain-dsn 时与测试环境对比
+	compareEnv := vEnv.Connector
+	if common.Config.CompareExplain && common.Config.CompareExplainDSN != "" {
+		compareEnv, err = database.NewConnector(common.ParseDSN(common.Config.CompareExplainDSN, nil))
+		if err != nil {
+			common.Log.Error("CompareExplainDSN NewConnector Error: %s", err.Error())
+			os.Exit(1)
+		}
+		if compareEnv.Database == "" {
+			compareEnv.Database = rEnv.Database
+		}
+	}
+
+	// 执行计划基线
+	var planBaselines advisor.PlanBaselines
+	if common.Config.SavePlans || common.Config.CheckPlans {
+		planBaselines, err = advisor.LoadPlanBaselines(common.Config.PlanBaseline)
+		if err != nil {
+			common.Log.Error("LoadPlanBaselines Error: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
 	// 逐条SQL给出优化建议
 	for ; ; sqlCounter++ {
 		var id string                                     // fingerprint.ID
@@ -241,6 +396,14 @@ func main() {
 				}
 			}
 		}
+		// ALTER TABLE 执行方式及影响评估
+		if r := advisor.AlterImpactAdvise(rEnv, q); r.Item == "ALT.005" {
+			heuristicSuggest["ALT.005"] = r
+		}
+		// DML 语句影响行数评估
+		if r := advisor.AffectedRowsAdvise(rEnv, q); r.Item == "RES.012" {
+			heuristicSuggest["RES.012"] = r
+		}
 		common.Log.Debug("end of heuristic advisor Query: %s", q.Query)
 		// +++++++++++++++++++++启发式规则建议[结束]+++++++++++++++++++++++}
 
@@ -297,11 +460,12 @@ func main() {
 		// +++++++++++++++++++++EXPLAIN 建议[开始]+++++++++++++++++++++++{
 		// 如果未配置 Online 或 Test 无法给 Explain 建议
 		common.Log.Debug("start of explain Query: %s", q.Query)
+		var explainInfo *database.ExplainInfo
 		if !common.Config.OnlineDSN.Disable && !common.Config.TestDSN.Disable {
 			// 因为 EXPLAIN 依赖数据库环境，所以把这段逻辑放在启发式建议和索引建议后面
 			if common.Config.Explain {
 				// 执行 EXPLAIN
-				explainInfo, err := rEnv.Explain(q.Query,
+				explainInfo, err = rEnv.Explain(q.Query,
 					database.ExplainType[common.Config.ExplainType],
 					database.ExplainFormatType[common.Config.ExplainFormat])
 				if err != nil {
@@ -323,13 +487,51 @@ func main() {
 					common.Log.Warn("rEnv&vEnv.Explain explainInfo nil, SQL: %s", q.Query)
 				}
 			}
+			// 与执行计划基线对比，或保存为新的基线
+			if explainInfo != nil && (common.Config.SavePlans || common.Config.CheckPlans) {
+				plan := advisor.NewPlanBaseline(q.Query, explainInfo)
+				if common.Config.CheckPlans {
+					if r := planBaselines.Check(id, plan); r.Item == "EXP.008" {
+						expSuggest["EXP.008"] = r
+					}
+				}
+				if common.Config.SavePlans {
+					planBaselines[id] = plan
+				}
+			}
+		}
+		// 对比线上环境与测试环境或指定环境中的执行计划，指定 -compare-explain-dsn 时不依赖测试环境
+		if common.Config.CompareExplain && !common.Config.OnlineDSN.Disable &&
+			(common.Config.CompareExplainDSN != "" || !common.Config.TestDSN.Disable) {
+			// 与测试环境对比时，屏蔽索引建议后测试环境中不会创建相关的库表，需要单独创建
+			if common.Config.CompareExplainDSN == "" && advisor.IsIgnoreRule("IDX.") && !vEnv.BuildVirtualEnv(rEnv, q.Query) {
+				common.Log.Error("vEnv.BuildVirtualEnv Error: prepare SQL '%s' in vEnv failed.", q.Query)
+			}
+			r, err := advisor.CompareExplainAdvise(rEnv, compareEnv, q.Query)
+			if err != nil {
+				// 对比环境 EXPLAIN 失败同样以 ERR.002 给出
+				if _, ok := mysqlSuggest["ERR.002"]; !ok {
+					mysqlSuggest["ERR.002"] = advisor.RuleMySQLError("ERR.002", err)
+				}
+			} else if r.Item == "EXP.007" {
+				expSuggest["EXP.007"] = r
+			}
 		}
 		common.Log.Debug("end of explain Query: %s", q.Query)
 		// +++++++++++++++++++++ EXPLAIN 建议[结束]+++++++++++++++++++++++}
 
 		// +++++++++++++++++++++ Profiling [开始]+++++++++++++++++++++++++{
 		common.Log.Debug("start of profiling Query: %s", q.Query)
-		if common.Config.Profiling {
+		if common.Config.Profiling && common.Config.ProfilingType == "performance_schema" {
+			res, err := vEnv.PerformanceSchemaProfiling(q.Query)
+			if err == nil {
+				proSuggest = advisor.ProfilingAdvisor(res)
+			} else {
+				common.Log.Debug("PerformanceSchemaProfiling Error: %v", err)
+			}
+		}
+		// performance_schema 不可用时使用 SHOW PROFILE
+		if common.Config.Profiling && len(proSuggest) == 0 {
 			res, err := vEnv.Profiling(q.Query)
 			if err == nil {
 				proSuggest["PRO.001"] = advisor.Rule{
@@ -342,6 +544,22 @@ func main() {
 			}
 		}
 		common.Log.Debug("end of profiling Query: %s", q.Query)
+		// 慢查询日志或 performance_schema 中的执行统计，processlist 中的采样结果
+		if digest, ok := digestIndex[id]; ok {
+			proSuggest["PRO.012"] = advisor.QueryDigestRule(digest)
+		}
+		if process, ok := processIndex[id]; ok {
+			proSuggest["PRO.013"] = advisor.ProcessDigestRule(process)
+		}
+		// 执行前后 SESSION STATUS 的变化
+		if common.Config.ShowSessionStatus {
+			res, err := vEnv.SessionStatus(q.Query)
+			if err == nil {
+				advisor.MergeSessionStatusRules(proSuggest, advisor.SessionStatusAdvisor(res))
+			} else {
+				common.Log.Debug("SessionStatus Error: %v", err)
+			}
+		}
 		// +++++++++++++++++++++ Profiling [结束]++++++++++++++++++++++++++}
 
 		// +++++++++++++++++++++ Trace [开始]+++++++++++++++++++++++++{
@@ -349,11 +567,7 @@ func main() {
 		if common.Config.Trace {
 			res, err := vEnv.Trace(q.Query)
 			if err == nil {
-				traceSuggest["TRA.001"] = advisor.Rule{
-					Item:     "TRA.001",
-					Severity: "L0",
-					Content:  database.FormatTrace(res),
-				}
+				traceSuggest = advisor.TraceAdvisor(res)
 			} else {
 				common.Log.Error("Trace Error: %v", err)
 			}
@@ -361,6 +575,21 @@ func main() {
 		common.Log.Debug("end of trace Query: %s", q.Query)
 		// +++++++++++++++++++++Trace [结束]++++++++++++++++++++++++++}
 
+		// +++++++++++++++++++++ Benchmark [开始]+++++++++++++++++++++++++{
+		common.Log.Debug("start of benchmark Query: %s", q.Query)
+		if common.Config.Benchmark > 0 && !common.Config.TestDSN.Disable {
+			// 与 SQL 重写一样，需要从测试环境中获取列信息
+			if rw := ast.NewRewrite(q.Query); rw != nil {
+				columns := vEnv.GenTableColumns(ast.GetMeta(rw.Stmt, nil))
+				variants := ast.RewriteVariants(q.Query, columns)
+				if r := advisor.BenchmarkAdvise(vEnv.Connector, q.Query, variants, common.Config.Benchmark); r.Item == "PRO.007" {
+					proSuggest["PRO.007"] = r
+				}
+			}
+		}
+		common.Log.Debug("end of benchmark Query: %s", q.Query)
+		// +++++++++++++++++++++ Benchmark [结束]++++++++++++++++++++++++++}
+
 		// +++++++++++++++++++++SQL 重写[开始]+++++++++++++++++++++++++{
 		common.Log.Debug("start of rewrite Query: %s", q.Query)
 		if common.Config.ReportType == "rewrite" {
@@ -432,6 +661,10 @@ func main() {
 			lineCounter += lc - llc
 		case "html":
 			fmt.Println(common.Markdown2HTML(str))
+		case "explain-dot", "explain-mermaid":
+			if explainInfo != nil {
+				fmt.Println(advisor.ExplainGraph(explainInfo, strings.TrimPrefix(common.Config.ReportType, "explain-")))
+			}
 		default:
 			fmt.Println(str)
 		}
@@ -439,6 +672,33 @@ func main() {
 		// +++++++++++++++++++++打印单条 SQL 优化建议[结束]++++++++++++++++++++++++++}
 	}
 
+	// 保存执行计划基线
+	if common.Config.SavePlans {
+		if err = planBaselines.Save(common.Config.PlanBaseline); err != nil {
+			common.Log.Error("PlanBaselines Save Error: %s", err.Error())
+		}
+	}
+
+	// 按总执行时间汇总日志中每类 SQL 的问题，processlist 按被采样到的次数汇总，binlog 中还有每张表的 DML 统计
+	var summary []string
+	if len(digests) > 0 {
+		summary = append(summary, advisor.FormatQueryDigests(digests, suggestMerged))
+	}
+	if len(processes) > 0 {
+		summary = append(summary, advisor.FormatProcessDigests(processes, suggestMerged))
+	}
+	if len(binlogTables) > 0 {
+		summary = append(summary, advisor.FormatBinlogTables(binlogTables))
+	}
+	if len(summary) > 0 {
+		switch common.Config.ReportType {
+		case "markdown":
+			fmt.Println(strings.Join(summary, "\n\n"))
+		case "html":
+			fmt.Println(common.Markdown2HTML(strings.Join(summary, "\n\n")))
+		}
+	}
+
 	// 同一张表的多条 ALTER 语句合并为一条
 	if ast.RewriteRuleMatch("mergealter") {
 		for _, v := range ast.MergeAlterTables(alterSQLs...) {
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/XiaoMi/soar/advisor"
 	"github.com/XiaoMi/soar/ast"
@@ -176,6 +177,13 @@ func reportTool(sql string, bom []byte) (isContinue bool, exitCode int) {
 		// 注意： 这里只能处理一条 SQL 的 EXPLAIN 信息，用户一次反馈多条 SQL 的 EXPLAIN 信息无法处理
 		advisor.DigestExplainText(sql)
 		return false, 0
+	case "explain-dot", "explain-mermaid":
+		// 输入为 EXPLAIN 信息时直接绘图，否则在测试环境中执行 EXPLAIN 后绘图
+		if database.IsExplainText(sql) {
+			advisor.DigestExplainText(sql)
+			return false, 0
+		}
+		return true, 0
 	case "chardet":
 		// Get charset of input
 		charset := common.CheckCharsetByBOM(bom)
@@ -231,6 +239,23 @@ func initQuery(query string) string {
 	return query
 }
 
+// sampleProcesslist 按 -sample-interval 及 -sample-duration 采样 -online-dsn 的 processlist，返回执行时间达到 -long-query-time 的 SQL
+func sampleProcesslist(rEnv *database.Connector) ([]*database.ProcessDigest, error) {
+	var durations []time.Duration
+	for _, s := range []string{common.Config.SampleInterval, common.Config.SampleDuration, common.Config.LongQueryTime} {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		durations = append(durations, d)
+	}
+	sampler, err := rEnv.SampleProcesslist(durations[0], durations[1])
+	if err != nil {
+		return nil, err
+	}
+	return sampler.Digests(durations[2].Seconds(), common.Config.TopQueries), nil
+}
+
 func shutdown(vEnv *env.VirtualEnv, rEnv *database.Connector) {
 	if common.Config.DropTestTemporary {
 		vEnv.CleanUp()
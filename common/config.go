@@ -62,7 +62,9 @@ type Configuration struct {
 	Sampling                bool   `yaml:"sampling"`                  // 数据采样开关
 	SamplingCondition       string `yaml:"sampling-condition"`        // 指定采样条件，如：WHERE xxx LIMIT xxx;
 	Profiling               bool   `yaml:"profiling"`                 // 在开启数据采样的情况下，在测试环境执行进行profile
+	ProfilingType           string `yaml:"profiling-type"`            // Profiling 的方式，支持 performance_schema, show-profile，performance_schema 不可用时使用 show-profile
 	Trace                   bool   `yaml:"trace"`                     // 在开启数据采样的情况下，在测试环境执行进行Trace
+	Benchmark               int    `yaml:"benchmark"`                 // 在测试环境中分别执行原 SQL 及重写后的 SQL 的次数，对比执行耗时，0 表示不对比
 	Explain                 bool   `yaml:"explain"`                   // Explain开关
 	Delimiter               string `yaml:"delimiter"`                 // SQL分隔符
 
@@ -79,8 +81,18 @@ type Configuration struct {
 	ReportCSS string `yaml:"report-css"`
 	// 当 ReportType 为 html 格式时使用的 javascript 脚本，如不指定默认会加载SQL pretty 使用的 javascript。像CSS一样可以是本地文件，也可以是一个URL
 	ReportJavascript string `yaml:"report-javascript"`
+	// 当 ReportType 为 html 格式时渲染执行计划图使用的 mermaid.js 地址，由浏览器加载，默认为空只输出图的源码
+	ReportMermaidJS string `yaml:"report-mermaid-js"`
 	// 当ReportType 为 html 格式时，HTML 的 title
 	ReportTitle string `yaml:"report-title"`
+	// 当 ReportType 为 schema-audit 时报告的输出格式，支持: markdown, html, json, lint, text
+	SchemaAuditFormat string `yaml:"schema-audit-format"`
+	// 当 ReportType 为 schema-diff 时期望的表结构来源，可以是 DSN 或存放建表语句的目录
+	SchemaDiffSource string `yaml:"schema-diff-source"`
+	// 当 ReportType 为 flashback 时允许备份的最大行数，超过时不生成闪回语句
+	FlashbackMaxRows int `yaml:"flashback-max-rows"`
+	// 当 ReportType 为 flashback 时闪回语句的输出文件，为空时输出到标准输出
+	FlashbackFile string `yaml:"flashback-file"`
 	// blackfriday markdown2html config
 	MarkdownExtensions int `yaml:"markdown-extensions"` // markdown 转 html 支持的扩展包, 参考blackfriday
 	MarkdownHTMLFlags  int `yaml:"markdown-html-flags"` // markdown 转 html 支持的 flag, 参考blackfriday, default 0
@@ -96,6 +108,9 @@ type Configuration struct {
 	MaxTextColsCount     int      `yaml:"max-text-cols-count"`       // 表中含有的 text/blob 列的最大数量
 	MaxTotalRows         uint64   `yaml:"max-total-rows"`            // 计算散粒度时，当数据行数大于 MaxTotalRows 即开启数据库保护模式，散粒度返回结果可信度下降
 	MaxQueryCost         int64    `yaml:"max-query-cost"`            // last_query_cost 超过该值时将给予警告
+	MaxAffectedRatio     float64  `yaml:"max-affected-ratio"`        // DML 影响行数占表总行数的百分比超过该值时给予警告，范围 0~100
+	MinAffectedTableRows int64    `yaml:"min-affected-table-rows"`   // 表总行数超过该值时才检查 DML 影响行数比例
+	AffectedCountLimit   int64    `yaml:"affected-count-limit"`      // 使用 SELECT COUNT(*) 统计 DML 影响行数时最多统计的行数，0 表示不统计
 	SpaghettiQueryLength int      `yaml:"spaghetti-query-length"`    // SQL最大长度警告，超过该长度会给警告
 	AllowDropIndex       bool     `yaml:"allow-drop-index"`          // 允许输出删除重复索引的建议
 	MaxInCount           int      `yaml:"max-in-count"`              // IN()最大数量
@@ -116,8 +131,8 @@ type Configuration struct {
 
 	// ++++++++++++++EXPLAIN检查项+++++++++++++
 	ExplainSQLReportType   string   `yaml:"explain-sql-report-type"`  // EXPLAIN markdown 格式输出 SQL 样式，支持 sample, fingerprint, pretty 等
-	ExplainType            string   `yaml:"explain-type"`             // EXPLAIN方式 [traditional, extended, partitions]
-	ExplainFormat          string   `yaml:"explain-format"`           // FORMAT=[json, traditional]
+	ExplainType            string   `yaml:"explain-type"`             // EXPLAIN方式 [traditional, extended, partitions, analyze]
+	ExplainFormat          string   `yaml:"explain-format"`           // FORMAT=[json, traditional, tree]
 	ExplainWarnSelectType  []string `yaml:"explain-warn-select-type"` // 哪些 select_type 不建议使用
 	ExplainWarnAccessType  []string `yaml:"explain-warn-access-type"` // 哪些 access type 不建议使用
 	ExplainMaxKeyLength    int      `yaml:"explain-max-keys"`         // 最大 key_len
@@ -125,11 +140,29 @@ type Configuration struct {
 	ExplainMaxRows         int64    `yaml:"explain-max-rows"`         // 最大扫描行数警告
 	ExplainWarnExtra       []string `yaml:"explain-warn-extra"`       // 哪些 extra 信息会给警告
 	ExplainMaxFiltered     float64  `yaml:"explain-max-filtered"`     // filtered 大于该配置给出警告
+	ExplainMaxRowsGap      float64  `yaml:"explain-max-rows-gap"`     // EXPLAIN ANALYZE 实际行数与预估行数，或对比执行计划时两个环境的预估行数相差超过该倍数时给出警告
 	ExplainWarnScalability []string `yaml:"explain-warn-scalability"` // 复杂度警告名单
 	ShowWarnings           bool     `yaml:"show-warnings"`            // explain extended with show warnings
 	ShowLastQueryCost      bool     `yaml:"show-last-query-cost"`     // switch with show status like 'last_query_cost'
+	ShowSessionStatus      bool     `yaml:"show-session-status"`      // 在测试环境中执行 SELECT，对比执行前后 SESSION STATUS 中 Handler_read% 等计数的变化
+	CompareExplain         bool     `yaml:"compare-explain"`          // 对比 OnlineDSN 与 TestDSN 或 CompareExplainDSN 中的执行计划
+	CompareExplainDSN      string   `yaml:"compare-explain-dsn"`      // 与 OnlineDSN 对比执行计划的 DSN，为空时与 TestDSN 对比
+	PlanBaseline           string   `yaml:"plan-baseline"`            // 执行计划基线文件，以 SQL 指纹 ID 为 key 保存执行计划
+	SavePlans              bool     `yaml:"save-plans"`               // 将执行计划保存到 PlanBaseline 文件中
+	CheckPlans             bool     `yaml:"check-plans"`              // 与 PlanBaseline 文件中的执行计划对比，发现执行计划的变化
+	PlanMaxDrift           float64  `yaml:"plan-max-drift"`           // 扫描行数或代价与基线相差超过该倍数时给出警告
+	MaxRowsExamined        int64    `yaml:"max-rows-examined"`        // Profiling 时实际扫描行数超过该值给出警告
+	MaxLockTime            float64  `yaml:"max-lock-time"`            // Profiling 时锁等待时间超过该值（秒）给出警告
+	MaxHandlerReadRatio    float64  `yaml:"max-handler-read-ratio"`   // Handler_read% 增量之和超过返回行数的该倍数时给出警告
 	// ++++++++++++++其他配置项+++++++++++++++
 	Query              string `yaml:"query"`                 // 需要进行调优的SQL
+	InputFormat        string `yaml:"input-format"`          // 输入的格式，支持 sql, slowlog, general, audit, binlog, pcap
+	Source             string `yaml:"source"`                // 不从 query 读取 SQL，而是从线上环境中获取，支持 digest, processlist
+	TopQueries         int    `yaml:"top-queries"`           // 输入为日志或从线上环境获取 SQL 时，只评审总执行时间最长的 N 类 SQL，0 表示全部评审
+	SampleInterval     string `yaml:"sample-interval"`       // Source 为 processlist 时的采样间隔
+	SampleDuration     string `yaml:"sample-duration"`       // Source 为 processlist 时的采样时长
+	LongQueryTime      string `yaml:"long-query-time"`       // Source 为 processlist 时只评审执行时间达到该值的 SQL
+	PcapPort           int    `yaml:"pcap-port"`             // InputFormat 为 pcap 时 MySQL 的服务端口
 	ListHeuristicRules bool   `yaml:"list-heuristic-rules"`  // 打印支持的评审规则列表
 	ListRewriteRules   bool   `yaml:"list-rewrite-rules"`    // 打印重写规则
 	ListTestSqls       bool   `yaml:"list-test-sqls"`        // 打印测试case用于测试
@@ -151,7 +184,9 @@ var Config = &Configuration{
 	SamplingStatisticTarget: 100,
 	Sampling:                false,
 	Profiling:               false,
+	ProfilingType:           "performance_schema",
 	Trace:                   false,
+	Benchmark:               0,
 	Explain:                 true,
 	Delimiter:               ";",
 	MinCardinality:          0,
@@ -165,6 +200,9 @@ var Config = &Configuration{
 	MaxIdxBytes:          3072,
 	MaxTotalRows:         9999999,
 	MaxQueryCost:         9999,
+	MaxAffectedRatio:     10,
+	MinAffectedTableRows: 100000,
+	AffectedCountLimit:   0,
 	SpaghettiQueryLength: 2048,
 	AllowDropIndex:       false,
 	LogLevel:             3,
@@ -172,7 +210,12 @@ var Config = &Configuration{
 	ReportType:           "markdown",
 	ReportCSS:            "",
 	ReportJavascript:     "",
+	ReportMermaidJS:      "",
 	ReportTitle:          "SQL优化分析报告",
+	SchemaAuditFormat:    "markdown",
+	SchemaDiffSource:     "",
+	FlashbackMaxRows:     1000,
+	FlashbackFile:        "",
 	BlackList:            "",
 	AllowCharsets:        []string{"utf8", "utf8mb4"},
 	AllowCollates:        []string{},
@@ -200,9 +243,20 @@ var Config = &Configuration{
 	ExplainMaxRows:         10000,
 	ExplainWarnExtra:       []string{"Using temporary", "Using filesort"},
 	ExplainMaxFiltered:     100.0,
+	ExplainMaxRowsGap:      10.0,
 	ExplainWarnScalability: []string{"O(n)"},
 	ShowWarnings:           false,
 	ShowLastQueryCost:      false,
+	ShowSessionStatus:      false,
+	CompareExplain:         false,
+	CompareExplainDSN:      "",
+	PlanBaseline:           "soar_plans.json",
+	SavePlans:              false,
+	CheckPlans:             false,
+	PlanMaxDrift:           2.0,
+	MaxRowsExamined:        10000,
+	MaxLockTime:            0.1,
+	MaxHandlerReadRatio:    100,
 
 	IgnoreRules: []string{
 		"COL.011",
@@ -218,6 +272,13 @@ var Config = &Configuration{
 		"distinctstar",
 	},
 
+	InputFormat:        "sql",
+	Source:             "",
+	TopQueries:         10,
+	SampleInterval:     "1s",
+	SampleDuration:     "10s",
+	LongQueryTime:      "1s",
+	PcapPort:           3306,
 	ListHeuristicRules: false,
 	ListRewriteRules:   false,
 	ListTestSqls:       false,
@@ -546,6 +607,8 @@ func PrintConfiguration() {
 	if !Config.Verbose {
 		Config.OnlineDSN.Password = "********"
 		Config.TestDSN.Password = "********"
+		Config.SchemaDiffSource = regexp.MustCompile(`:.*@`).ReplaceAllString(Config.SchemaDiffSource, ":********@")
+		Config.CompareExplainDSN = regexp.MustCompile(`:.*@`).ReplaceAllString(Config.CompareExplainDSN, ":********@")
 	}
 	data, _ := yaml.Marshal(Config)
 	fmt.Print(string(data))
@@ -590,7 +653,9 @@ func readCmdFlags() error {
 	cleanupTestDatabase := flag.Bool("cleanup-test-database", Config.CleanupTestDatabase, "单次运行清理历史1小时前残余的测试库。")
 	onlySyntaxCheck := flag.Bool("only-syntax-check", Config.OnlySyntaxCheck, "OnlySyntaxCheck, 只做语法检查不输出优化建议")
 	profiling := flag.Bool("profiling", Config.Profiling, "Profiling, 开启数据采样的情况下在测试环境执行Profile")
+	profilingType := flag.String("profiling-type", Config.ProfilingType, "ProfilingType, Profiling 的方式，支持 performance_schema, show-profile")
 	trace := flag.Bool("trace", Config.Trace, "Trace, 开启数据采样的情况下在测试环境执行Trace")
+	benchmark := flag.Int("benchmark", Config.Benchmark, "Benchmark, 在测试环境中分别执行原 SQL 及重写后的 SQL 的次数，对比执行耗时，0 表示不对比")
 	explain := flag.Bool("explain", Config.Explain, "Explain, 是否开启Explain执行计划分析")
 	sampling := flag.Bool("sampling", Config.Sampling, "Sampling, 数据采样开关")
 	samplingStatisticTarget := flag.Int("sampling-statistic-target", Config.SamplingStatisticTarget, "SamplingStatisticTarget, 数据采样因子，对应 PostgreSQL 的 default_statistics_target")
@@ -603,7 +668,12 @@ func readCmdFlags() error {
 	reportType := flag.String("report-type", Config.ReportType, "ReportType, 优化建议输出格式，目前支持: json, text, markdown, html等")
 	reportCSS := flag.String("report-css", Config.ReportCSS, "ReportCSS, 当 ReportType 为 html 格式时使用的 css 风格，如不指定会提供一个默认风格。CSS可以是本地文件，也可以是一个URL")
 	reportJavascript := flag.String("report-javascript", Config.ReportJavascript, "ReportJavascript, 当 ReportType 为 html 格式时使用的javascript脚本，如不指定默认会加载SQL pretty 使用的 javascript。像CSS一样可以是本地文件，也可以是一个URL")
+	reportMermaidJS := flag.String("report-mermaid-js", Config.ReportMermaidJS, "ReportMermaidJS, 当 ReportType 为 html 格式时渲染执行计划图使用的 mermaid.js 地址，由浏览器加载，默认为空只输出图的源码")
 	reportTitle := flag.String("report-title", Config.ReportTitle, "ReportTitle, 当 ReportType 为 html 格式时，HTML 的 title")
+	flashbackMaxRows := flag.Int("flashback-max-rows", Config.FlashbackMaxRows, "FlashbackMaxRows, 当 ReportType 为 flashback 时允许备份的最大行数，超过时不生成闪回语句")
+	flashbackFile := flag.String("flashback-file", Config.FlashbackFile, "FlashbackFile, 当 ReportType 为 flashback 时闪回语句的输出文件，为空时输出到标准输出")
+	schemaDiffSource := flag.String("schema-diff-source", Config.SchemaDiffSource, "SchemaDiffSource, 当 ReportType 为 schema-diff 时期望的表结构来源，可以是 DSN 或存放建表语句的目录")
+	schemaAuditFormat := flag.String("schema-audit-format", Config.SchemaAuditFormat, "SchemaAuditFormat, 当 ReportType 为 schema-audit 时报告的输出格式 [markdown, html, json, lint, text]")
 	// +++++++++++++++markdown+++++++++++++++++
 	markdownExtensions := flag.Int("markdown-extensions", Config.MarkdownExtensions, "MarkdownExtensions, markdown 转 html支持的扩展包, 参考blackfriday")
 	markdownHTMLFlags := flag.Int("markdown-html-flags", Config.MarkdownHTMLFlags, "MarkdownHTMLFlags, markdown 转 html 支持的 flag, 参考blackfriday")
@@ -618,6 +688,9 @@ func readCmdFlags() error {
 	maxTextColsCount := flag.Int("max-text-cols-count", Config.MaxTextColsCount, "MaxTextColsCount, 表中含有的 text/blob 列的最大数量")
 	maxTotalRows := flag.Uint64("max-total-rows", Config.MaxTotalRows, "MaxTotalRows, 计算散粒度时，当数据行数大于MaxTotalRows即开启数据库保护模式，不计算散粒度")
 	maxQueryCost := flag.Int64("max-query-cost", Config.MaxQueryCost, "MaxQueryCost, last_query_cost 超过该值时将给予警告")
+	maxAffectedRatio := flag.Float64("max-affected-ratio", Config.MaxAffectedRatio, "MaxAffectedRatio, DML 影响行数占表总行数的百分比超过该值时给予警告，范围0.0 ~ 100.0")
+	minAffectedTableRows := flag.Int64("min-affected-table-rows", Config.MinAffectedTableRows, "MinAffectedTableRows, 表总行数超过该值时才检查 DML 影响行数比例")
+	affectedCountLimit := flag.Int64("affected-count-limit", Config.AffectedCountLimit, "AffectedCountLimit, 使用 SELECT COUNT(*) 统计 DML 影响行数时最多统计的行数，0 表示不统计")
 	spaghettiQueryLength := flag.Int("spaghetti-query-length", Config.SpaghettiQueryLength, "SpaghettiQueryLength, SQL最大长度警告，超过该长度会给警告")
 	allowDropIdx := flag.Bool("allow-drop-index", Config.AllowDropIndex, "AllowDropIndex, 允许输出删除重复索引的建议")
 	maxInCount := flag.Int("max-in-count", Config.MaxInCount, "MaxInCount, IN()最大数量")
@@ -636,8 +709,8 @@ func readCmdFlags() error {
 	columnNotAllowType := flag.String("column-not-allow-type", strings.Join(Config.ColumnNotAllowType, ","), "ColumnNotAllowType")
 	// ++++++++++++++EXPLAIN检查项+++++++++++++
 	explainSQLReportType := flag.String("explain-sql-report-type", strings.ToLower(Config.ExplainSQLReportType), "ExplainSQLReportType [pretty, sample, fingerprint]")
-	explainType := flag.String("explain-type", strings.ToLower(Config.ExplainType), "ExplainType [extended, partitions, traditional]")
-	explainFormat := flag.String("explain-format", strings.ToLower(Config.ExplainFormat), "ExplainFormat [json, traditional]")
+	explainType := flag.String("explain-type", strings.ToLower(Config.ExplainType), "ExplainType [extended, partitions, traditional, analyze]，analyze 会在 MySQL 8.0.18 及以上版本真实执行查询")
+	explainFormat := flag.String("explain-format", strings.ToLower(Config.ExplainFormat), "ExplainFormat [json, traditional, tree]")
 	explainWarnSelectType := flag.String("explain-warn-select-type", strings.Join(Config.ExplainWarnSelectType, ","), "ExplainWarnSelectType, 哪些select_type不建议使用")
 	explainWarnAccessType := flag.String("explain-warn-access-type", strings.Join(Config.ExplainWarnAccessType, ","), "ExplainWarnAccessType, 哪些access type不建议使用")
 	explainMaxKeyLength := flag.Int("explain-max-keys", Config.ExplainMaxKeyLength, "ExplainMaxKeyLength, 最大key_len")
@@ -645,14 +718,32 @@ func readCmdFlags() error {
 	explainMaxRows := flag.Int64("explain-max-rows", Config.ExplainMaxRows, "ExplainMaxRows, 最大扫描行数警告")
 	explainWarnExtra := flag.String("explain-warn-extra", strings.Join(Config.ExplainWarnExtra, ","), "ExplainWarnExtra, 哪些extra信息会给警告")
 	explainMaxFiltered := flag.Float64("explain-max-filtered", Config.ExplainMaxFiltered, "ExplainMaxFiltered, filtered大于该配置给出警告")
+	explainMaxRowsGap := flag.Float64("explain-max-rows-gap", Config.ExplainMaxRowsGap, "ExplainMaxRowsGap, EXPLAIN ANALYZE 实际行数与预估行数，或对比执行计划时两个环境的预估行数相差超过该倍数时给出警告")
 	explainWarnScalability := flag.String("explain-warn-scalability", strings.Join(Config.ExplainWarnScalability, ","), "ExplainWarnScalability, 复杂度警告名单, 支持O(n),O(log n),O(1),O(?)")
 	showWarnings := flag.Bool("show-warnings", Config.ShowWarnings, "ShowWarnings")
 	showLastQueryCost := flag.Bool("show-last-query-cost", Config.ShowLastQueryCost, "ShowLastQueryCost")
+	showSessionStatus := flag.Bool("show-session-status", Config.ShowSessionStatus, "ShowSessionStatus, 在测试环境中执行 SELECT，对比执行前后 SESSION STATUS 中 Handler_read% 等计数的变化")
+	compareExplain := flag.Bool("compare-explain", Config.CompareExplain, "CompareExplain, 对比 OnlineDSN 与 TestDSN 或 CompareExplainDSN 中的执行计划，用于发现环境差异或版本升级带来的执行计划变化")
+	compareExplainDSN := flag.String("compare-explain-dsn", Config.CompareExplainDSN, "CompareExplainDSN, 与 OnlineDSN 对比执行计划的 DSN，为空时与 TestDSN 对比")
+	planBaseline := flag.String("plan-baseline", Config.PlanBaseline, "PlanBaseline, 执行计划基线文件，以 SQL 指纹 ID 为 key 保存执行计划")
+	savePlans := flag.Bool("save-plans", Config.SavePlans, "SavePlans, 将执行计划保存到 -plan-baseline 文件中，已存在的 SQL 会被覆盖")
+	checkPlans := flag.Bool("check-plans", Config.CheckPlans, "CheckPlans, 与 -plan-baseline 文件中的执行计划对比，发现执行计划的变化")
+	planMaxDrift := flag.Float64("plan-max-drift", Config.PlanMaxDrift, "PlanMaxDrift, 扫描行数或代价与基线相差超过该倍数时给出警告")
+	maxRowsExamined := flag.Int64("max-rows-examined", Config.MaxRowsExamined, "MaxRowsExamined, Profiling 时实际扫描行数超过该值给出警告")
+	maxLockTime := flag.Float64("max-lock-time", Config.MaxLockTime, "MaxLockTime, Profiling 时锁等待时间超过该值（秒）给出警告")
+	maxHandlerReadRatio := flag.Float64("max-handler-read-ratio", Config.MaxHandlerReadRatio, "MaxHandlerReadRatio, Handler_read% 增量之和超过返回行数的该倍数时给出警告")
 	// +++++++++++++++++其他+++++++++++++++++++
 	printConfig := flag.Bool("print-config", false, "Print configs")
 	checkConfig := flag.Bool("check-config", false, "Check configs")
 	printVersion := flag.Bool("version", false, "Print version info")
 	query := flag.String("query", Config.Query, "待评审的 SQL 或 SQL 文件，如 SQL 中包含特殊字符建议使用文件名。")
+	inputFormat := flag.String("input-format", Config.InputFormat, "InputFormat, 输入的格式，支持 sql, slowlog, general, audit, binlog, pcap")
+	source := flag.String("source", Config.Source, "Source, 不从 -query 读取 SQL，而是从 -online-dsn 中获取，digest: performance_schema 中总执行时间最长的 -top-queries 类 SQL, processlist: 采样 processlist 中正在执行的 SQL")
+	topQueries := flag.Int("top-queries", Config.TopQueries, "TopQueries, 输入为日志时，只评审总执行时间最长的 N 类 SQL，0 表示全部评审")
+	sampleInterval := flag.String("sample-interval", Config.SampleInterval, "SampleInterval, -source processlist 时的采样间隔")
+	sampleDuration := flag.String("sample-duration", Config.SampleDuration, "SampleDuration, -source processlist 时的采样时长")
+	longQueryTime := flag.String("long-query-time", Config.LongQueryTime, "LongQueryTime, -source processlist 时只评审执行时间达到该值的 SQL")
+	pcapPort := flag.Int("pcap-port", Config.PcapPort, "PcapPort, -input-format pcap 时 MySQL 的服务端口")
 	listHeuristicRules := flag.Bool("list-heuristic-rules", Config.ListHeuristicRules, "ListHeuristicRules, 打印支持的评审规则列表")
 	listRewriteRules := flag.Bool("list-rewrite-rules", Config.ListRewriteRules, "ListRewriteRules, 打印支持的重写规则列表")
 	listTestSQLs := flag.Bool("list-test-sqls", Config.ListTestSqls, "ListTestSqls, 打印测试case用于测试")
@@ -674,7 +765,9 @@ func readCmdFlags() error {
 	Config.CleanupTestDatabase = *cleanupTestDatabase
 	Config.OnlySyntaxCheck = *onlySyntaxCheck
 	Config.Profiling = *profiling
+	Config.ProfilingType = *profilingType
 	Config.Trace = *trace
+	Config.Benchmark = *benchmark
 	Config.Explain = *explain
 	Config.Sampling = *sampling
 	Config.SamplingStatisticTarget = *samplingStatisticTarget
@@ -694,7 +787,12 @@ func readCmdFlags() error {
 	}
 	Config.ReportCSS = *reportCSS
 	Config.ReportJavascript = *reportJavascript
+	Config.ReportMermaidJS = *reportMermaidJS
 	Config.ReportTitle = *reportTitle
+	Config.SchemaAuditFormat = strings.ToLower(*schemaAuditFormat)
+	Config.SchemaDiffSource = *schemaDiffSource
+	Config.FlashbackMaxRows = *flashbackMaxRows
+	Config.FlashbackFile = *flashbackFile
 	Config.MarkdownExtensions = *markdownExtensions
 	Config.MarkdownHTMLFlags = *markdownHTMLFlags
 	Config.IgnoreRules = strings.Split(*ignoreRules, ",")
@@ -738,10 +836,20 @@ func readCmdFlags() error {
 	Config.MaxSubqueryDepth = *maxSubqueryDepth
 	Config.MaxTotalRows = *maxTotalRows
 	Config.MaxQueryCost = *maxQueryCost
+	Config.MaxAffectedRatio = *maxAffectedRatio
+	Config.MinAffectedTableRows = *minAffectedTableRows
+	Config.AffectedCountLimit = *affectedCountLimit
 	Config.AllowDropIndex = *allowDropIdx
 	Config.MaxInCount = *maxInCount
 	Config.SpaghettiQueryLength = *spaghettiQueryLength
 	Config.Query = *query
+	Config.InputFormat = *inputFormat
+	Config.Source = *source
+	Config.TopQueries = *topQueries
+	Config.SampleInterval = *sampleInterval
+	Config.SampleDuration = *sampleDuration
+	Config.LongQueryTime = *longQueryTime
+	Config.PcapPort = *pcapPort
 	Config.Delimiter = *delimiter
 
 	Config.ExplainSQLReportType = strings.ToLower(*explainSQLReportType)
@@ -754,9 +862,20 @@ func readCmdFlags() error {
 	Config.ExplainMaxRows = *explainMaxRows
 	Config.ExplainWarnExtra = strings.Split(*explainWarnExtra, ",")
 	Config.ExplainMaxFiltered = *explainMaxFiltered
+	Config.ExplainMaxRowsGap = *explainMaxRowsGap
 	Config.ExplainWarnScalability = strings.Split(*explainWarnScalability, ",")
 	Config.ShowWarnings = *showWarnings
 	Config.ShowLastQueryCost = *showLastQueryCost
+	Config.ShowSessionStatus = *showSessionStatus
+	Config.CompareExplain = *compareExplain
+	Config.CompareExplainDSN = *compareExplainDSN
+	Config.PlanBaseline = *planBaseline
+	Config.SavePlans = *savePlans
+	Config.CheckPlans = *checkPlans
+	Config.PlanMaxDrift = *planMaxDrift
+	Config.MaxRowsExamined = *maxRowsExamined
+	Config.MaxLockTime = *maxLockTime
+	Config.MaxHandlerReadRatio = *maxHandlerReadRatio
 	Config.ListHeuristicRules = *listHeuristicRules
 	Config.ListRewriteRules = *listRewriteRules
 	Config.ListTestSqls = *listTestSQLs
@@ -902,7 +1021,7 @@ var ReportTypes = []ReportType{
 	},
 	{
 		Name:        "explain-digest",
-		Description: "输入为EXPLAIN的表格，JSON 或 Vertical格式，对其进行分析，给出分析结果",
+		Description: "输入为EXPLAIN的表格，JSON，Vertical，FORMAT=TREE 或 EXPLAIN ANALYZE 格式，对其进行分析，给出分析结果",
 		Example: `soar -report-type explain-digest << EOF
 +----+-------------+-------+------+---------------+------+---------+------+------+-------+
 | id | select_type | table | type | possible_keys | key  | key_len | ref  | rows | Extra |
@@ -911,11 +1030,51 @@ var ReportTypes = []ReportType{
 +----+-------------+-------+------+---------------+------+---------+------+------+-------+
 EOF`,
 	},
+	{
+		Name:        "explain-dot",
+		Description: "将 EXPLAIN 结果输出为 Graphviz DOT 格式的执行计划图，输入可以是 SQL 或 explain-digest 支持的 EXPLAIN 信息，全表扫描及扫描行数过多的节点会以不同颜色标出",
+		Example:     `echo "select * from film where length > 100" | soar -report-type explain-dot | dot -Tsvg > explain.svg`,
+	},
+	{
+		Name:        "explain-mermaid",
+		Description: "将 EXPLAIN 结果输出为 Mermaid 格式的执行计划图，输入可以是 SQL 或 explain-digest 支持的 EXPLAIN 信息，全表扫描及扫描行数过多的节点会以不同颜色标出",
+		Example:     `echo "select * from film where length > 100" | soar -report-type explain-mermaid`,
+	},
 	{
 		Name:        "duplicate-key-checker",
 		Description: "对 OnlineDsn 中指定的 database 进行索引重复检查",
 		Example:     `soar -report-type duplicate-key-checker -online-dsn user:password@127.0.0.1:3306/db`,
 	},
+	{
+		Name:        "unused-index",
+		Description: "根据 performance_schema 中的统计信息对 OnlineDsn 中指定的 database 进行未使用索引检查，给出删除索引的语句及预计可回收的空间",
+		Example:     `soar -report-type unused-index -online-dsn user:password@127.0.0.1:3306/db`,
+	},
+	{
+		Name:        "schema-audit",
+		Description: "对 OnlineDsn 中指定 database 的所有表结构进行审计，包括建表规则、重复索引及外键检查，输出格式由 -schema-audit-format 指定",
+		Example:     `soar -report-type schema-audit -schema-audit-format markdown -online-dsn user:password@127.0.0.1:3306/db`,
+	},
+	{
+		Name:        "schema-diff",
+		Description: "比较 OnlineDsn 与 -schema-diff-source 指定的 DSN 或建表语句目录中的表结构，生成将 OnlineDsn 变更为目标表结构的 CREATE, ALTER, DROP 语句，并对生成的语句进行 ALT, COL, KEY 类规则检查",
+		Example:     `soar -report-type schema-diff -online-dsn user:password@127.0.0.1:3306/db -schema-diff-source user:password@127.0.0.2:3306/db`,
+	},
+	{
+		Name:        "osc",
+		Description: "将输入的 ALTER 语句按表合并，生成 gh-ost 及 pt-online-schema-change 命令，并检查主键或唯一键、触发器、外键等前置条件，命令中的密码以 ******** 代替",
+		Example:     `echo "alter table film add column c int; alter table film add index idx_c(c)" | soar -report-type osc -online-dsn user:password@127.0.0.1:3306/sakila`,
+	},
+	{
+		Name:        "rollback",
+		Description: "为输入的 CREATE, ALTER, DROP, RENAME 等 DDL 语句生成回滚语句，删除或修改类操作依赖 OnlineDsn 中变更前的表结构，无法精确回滚时给出警告",
+		Example:     `echo "alter table film drop column rating" | soar -report-type rollback -online-dsn user:password@127.0.0.1:3306/sakila`,
+	},
+	{
+		Name:        "flashback",
+		Description: "将单表 UPDATE, DELETE 语句改写为 SELECT，从 OnlineDsn 中查询受影响的行并生成按主键回滚的 INSERT, UPDATE 语句，行数超过 -flashback-max-rows 时不生成，-flashback-file 指定输出文件",
+		Example:     `echo "delete from film where film_id = 1" | soar -report-type flashback -flashback-file rollback.sql -online-dsn user:password@127.0.0.1:3306/sakila`,
+	},
 	{
 		Name:        "html",
 		Description: "以HTML格式输出报表",
@@ -99,11 +99,19 @@ func MarkdownHTMLHeader() string {
 		js = loadExternalResource(Config.ReportJavascript)
 	}
 
+	// 执行计划图由浏览器加载 mermaid.js 渲染
+	var mermaid string
+	if Config.ReportMermaidJS != "" {
+		mermaid = `<script src="` + Config.ReportMermaidJS + `"></script>
+<script>if (window.mermaid) { mermaid.initialize({startOnLoad: true}); }</script>
+`
+	}
+
 	header := `<head>
 <meta http-equiv=Content-Type content="text/html;charset=utf-8">
 <title>` + Config.ReportTitle + `</title>
 <script>` + js + `</script>
-<style id="soar_md">
+` + mermaid + `<style id="soar_md">
 ` + css + `
 </style>
 </head>
@@ -158,3 +158,17 @@ func RemoveDuplicatesItem(duplicate []string) []string {
 	sort.Strings(unique)
 	return unique
 }
+
+// HumanBytes convert bytes into human readable format, eg. 1.50 MB
+func HumanBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit && exp < 4; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %cB", float64(bytes)/float64(div), "KMGTP"[exp])
+}
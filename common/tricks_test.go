@@ -409,3 +409,20 @@ func TestRemoveDuplicatesItem(t *testing.T) {
 	}
 	Log.Debug("Exiting function: %s", GetFunctionName())
 }
+
+func TestHumanBytes(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	cases := map[int64]string{
+		0:                      "0 B",
+		1023:                   "1023 B",
+		1024:                   "1.00 KB",
+		1536 * 1024:            "1.50 MB",
+		5 * 1024 * 1024 * 1024: "5.00 GB",
+	}
+	for bytes, want := range cases {
+		if got := HumanBytes(bytes); got != want {
+			t.Errorf("HumanBytes(%d) want: %s, got: %s", bytes, want, got)
+		}
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// auditRecord Percona Server audit_log_format=JSON 中的一条记录
+type auditRecord struct {
+	AuditRecord struct {
+		Name         string      `json:"name"`
+		Timestamp    string      `json:"timestamp"`
+		CommandClass string      `json:"command_class"`
+		ConnectionID json.Number `json:"connection_id"`
+		SQLText      string      `json:"sqltext"`
+		DB           string      `json:"db"`
+	} `json:"audit_record"`
+}
+
+// ParseAuditLog 解析 Percona Server 的 JSON 格式审计日志及 MariaDB server_audit 插件的 CSV 格式审计日志
+// 按连接记录当前使用的数据库，只保留 SQL 请求
+func ParseAuditLog(r io.Reader) ([]QueryEvent, error) {
+	var events []QueryEvent
+	databases := make(map[int64]string) // 每个连接当前使用的数据库
+
+	scanner := bufio.NewScanner(r)
+	// 单条 SQL 可能很长
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var event QueryEvent
+		var ok bool
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "{"):
+			event, ok = parseAuditJSON(line, databases)
+		default:
+			event, ok = parseAuditCSV(line, databases)
+		}
+		if !ok {
+			continue
+		}
+		event.Query = strings.TrimSuffix(strings.TrimSpace(event.Query), ";")
+		if m := slowLogUseExp.FindStringSubmatch(event.Query); m != nil {
+			databases[event.Connection] = m[1]
+			continue
+		}
+		if event.Query != "" {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
+
+// parseAuditJSON 解析 Percona Server 的审计日志，Connect 及 Query 记录中的 db 为连接当前使用的数据库
+func parseAuditJSON(line string, databases map[int64]string) (QueryEvent, bool) {
+	var event QueryEvent
+	var record auditRecord
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		common.Log.Debug("parseAuditJSON Error: %v, line: %s", err, line)
+		return event, false
+	}
+	audit := record.AuditRecord
+	event.Connection, _ = audit.ConnectionID.Int64()
+	if audit.DB != "" {
+		databases[event.Connection] = audit.DB
+	}
+	switch audit.Name {
+	case "Query", "Execute":
+	case "Quit":
+		delete(databases, event.Connection)
+		return event, false
+	default:
+		return event, false
+	}
+	event.Query = audit.SQLText
+	event.Database = databases[event.Connection]
+	if t, err := time.Parse("2006-01-02T15:04:05 MST", audit.Timestamp); err == nil {
+		event.Timestamp = t
+	}
+	return event, true
+}
+
+// parseAuditCSV 解析 MariaDB server_audit 的日志
+// timestamp,serverhost,username,host,connectionid,queryid,operation,database,object,retcode
+func parseAuditCSV(line string, databases map[int64]string) (QueryEvent, bool) {
+	var event QueryEvent
+	fields := strings.SplitN(line, ",", 9)
+	if len(fields) != 9 {
+		return event, false
+	}
+	event.Connection, _ = strconv.ParseInt(fields[4], 10, 64)
+	if fields[7] != "" {
+		databases[event.Connection] = fields[7]
+	}
+	switch fields[6] {
+	case "QUERY":
+	case "DISCONNECT":
+		delete(databases, event.Connection)
+		return event, false
+	default:
+		return event, false
+	}
+
+	// object 为单引号包围的 SQL，之后是 retcode
+	object := fields[8]
+	if i := strings.LastIndex(object, ","); i >= 0 {
+		object = object[:i]
+	}
+	event.Query = unescapeAuditQuery(strings.TrimSuffix(strings.TrimPrefix(object, "'"), "'"))
+	event.Database = databases[event.Connection]
+	if t, err := time.ParseInLocation("20060102 15:04:05", fields[0], time.Local); err == nil {
+		event.Timestamp = t
+	}
+	return event, true
+}
+
+// unescapeAuditQuery server_audit 会转义 SQL 中的引号、反斜杠及换行符
+func unescapeAuditQuery(s string) string {
+	return strings.NewReplacer(`\'`, `'`, `\"`, `"`, `\\`, `\`, `\n`, "\n", `\r`, "\r", `\t`, "\t").Replace(s)
+}
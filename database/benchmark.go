@@ -0,0 +1,191 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// BenchmarkResult 多次执行同一条 SQL 的耗时及 Handler 计数
+type BenchmarkResult struct {
+	Query   string
+	Count   int
+	Min     time.Duration
+	P50     time.Duration
+	P95     time.Duration
+	Max     time.Duration
+	Handler map[string]float64 // 平均每次执行 Handler_read% 的增量
+}
+
+// benchmarkWarmup 正式计时前预热执行的次数，使数据页加载到 Buffer Pool 中
+const benchmarkWarmup = 1
+
+// Benchmark 在测试环境中执行 n 次 SQL，统计耗时的分布及 SHOW SESSION STATUS 中 Handler_read% 的增量
+// 为避免修改数据只支持 SELECT
+func (db *Connector) Benchmark(query string, n int) (*BenchmarkResult, error) {
+	if sqlparser.Preview(query) != sqlparser.StmtSelect {
+		return nil, errors.New("no need benchmark")
+	}
+	if n <= 0 {
+		return nil, errors.New("benchmark count should be greater than 0")
+	}
+	if err := db.profilingCheck(query); err != nil {
+		return nil, err
+	}
+
+	common.Log.Debug("Benchmark SQL with DSN(%s/%s) %d times: %s", db.Addr, db.Database, n, query)
+	// SESSION STATUS 需要在同一个连接中获取
+	trx, err := db.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		trxErr := trx.Rollback()
+		if trxErr != nil {
+			common.Log.Debug(trxErr.Error())
+		}
+	}()
+
+	for i := 0; i < benchmarkWarmup; i++ {
+		if _, err = benchmarkExec(trx, query); err != nil {
+			return nil, err
+		}
+	}
+
+	durations := make([]time.Duration, 0, n)
+	handler, err := sessionStatusDelta(trx, "LIKE 'Handler_read%'", func() error {
+		for i := 0; i < n; i++ {
+			d, err := benchmarkExec(trx, query)
+			if err != nil {
+				return err
+			}
+			durations = append(durations, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewBenchmarkResult(query, durations)
+	for name, delta := range handler {
+		res.Handler[name] = float64(delta) / float64(n)
+	}
+	return res, nil
+}
+
+// NewBenchmarkResult 根据每次执行的耗时计算最小值、中位数、P95 及最大值
+func NewBenchmarkResult(query string, durations []time.Duration) *BenchmarkResult {
+	res := &BenchmarkResult{
+		Query:   query,
+		Count:   len(durations),
+		Handler: make(map[string]float64),
+	}
+	if len(durations) == 0 {
+		return res
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	res.Min = sorted[0]
+	res.P50 = percentile(sorted, 0.5)
+	res.P95 = percentile(sorted, 0.95)
+	res.Max = sorted[len(sorted)-1]
+	return res
+}
+
+// percentile nearest-rank 方法计算百分位数，durations 需要已经排好序
+func percentile(durations []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(durations)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return durations[rank]
+}
+
+// benchmarkExec 执行一次 SQL 并读取全部的结果，返回耗时
+func benchmarkExec(trx *sql.Tx, query string) (time.Duration, error) {
+	start := time.Now()
+	res, err := trx.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	for res.Next() {
+		continue
+	}
+	err = res.Err()
+	res.Close()
+	return time.Since(start), err
+}
+
+// sessionStatusDelta 返回执行 run 前后 SESSION STATUS 的增量，condition 为 SHOW SESSION STATUS 的 LIKE 或 WHERE 条件
+func sessionStatusDelta(trx *sql.Tx, condition string, run func() error) (map[string]int64, error) {
+	// SHOW SESSION STATUS 本身也会增加 Handler 计数，连续执行两次得到这部分开销
+	first, err := showSessionStatus(trx, condition)
+	if err != nil {
+		return nil, err
+	}
+	before, err := showSessionStatus(trx, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = run(); err != nil {
+		return nil, err
+	}
+
+	after, err := showSessionStatus(trx, condition)
+	if err != nil {
+		return nil, err
+	}
+	delta := make(map[string]int64)
+	for name, value := range after {
+		d := value - before[name] - (before[name] - first[name])
+		if d < 0 {
+			d = 0
+		}
+		delta[name] = d
+	}
+	return delta, nil
+}
+
+// showSessionStatus 获取当前会话的 STATUS，condition 为 LIKE 或 WHERE 条件
+func showSessionStatus(trx *sql.Tx, condition string) (map[string]int64, error) {
+	status := make(map[string]int64)
+	res, err := trx.Query("SHOW SESSION STATUS " + condition)
+	if err != nil {
+		return status, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var name string
+		var value int64
+		if err = res.Scan(&name, &value); err != nil {
+			return status, err
+		}
+		status[name] = value
+	}
+	return status, res.Err()
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestBenchmark(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	res, err := connTest.Benchmark("select * from film", 3)
+	if err != nil {
+		t.Error(err)
+	} else if res.Count != 3 || res.Handler["Handler_read_rnd_next"] == 0 {
+		t.Errorf("unexpected benchmark result: %s", pretty.Sprint(res))
+	}
+	_, err = connTest.Benchmark("delete from film", 3)
+	if err == nil {
+		t.Error("delete should not be benchmarked")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestNewBenchmarkResult(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	var durations []time.Duration
+	for i := 20; i > 0; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	res := NewBenchmarkResult("select 1", durations)
+	if res.Count != 20 || res.Min != time.Millisecond || res.P50 != 10*time.Millisecond ||
+		res.P95 != 19*time.Millisecond || res.Max != 20*time.Millisecond {
+		t.Errorf("unexpected benchmark result: %s", pretty.Sprint(res))
+	}
+	if durations[0] != 20*time.Millisecond {
+		t.Error("durations should not be sorted in place")
+	}
+
+	res = NewBenchmarkResult("select 1", []time.Duration{time.Second})
+	if res.P50 != time.Second || res.P95 != time.Second {
+		t.Errorf("unexpected benchmark result: %s", pretty.Sprint(res))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
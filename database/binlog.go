@@ -0,0 +1,306 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+const (
+	// binlogEventHeaderSize binlog v4 的事件头长度
+	binlogEventHeaderSize = 19
+	// binlogRowsQueryEvent binlog_rows_query_log_events 开启时 ROW 格式的事件前记录的原始 SQL
+	binlogRowsQueryEvent = 29
+)
+
+// binlogMagic binlog 文件头
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// binlogTrxExp 事务控制语句不需要评审
+var binlogTrxExp = regexp.MustCompile(`(?i)^(BEGIN|COMMIT|ROLLBACK|XA\s|SAVEPOINT\s|RELEASE\s+SAVEPOINT\s)`)
+
+// BinlogTableStat binlog 中每张表的 DML 统计
+// Statements 为 STATEMENT 格式的 DML 及 ROW 格式中 Rows_query 事件的个数，Inserts 等为 ROW 格式事件中的行数
+type BinlogTableStat struct {
+	Table      string
+	Statements int64
+	Inserts    int64
+	Updates    int64
+	Deletes    int64
+}
+
+// binlogParser 按顺序解析多个 binlog 文件，DML 统计在多个文件间累加
+type binlogParser struct {
+	format    mysql.BinlogFormat
+	tableMaps map[uint64]*mysql.TableMap
+	stats     map[string]*BinlogTableStat
+	events    []QueryEvent
+	rowsQuery int             // 等待 TABLE_MAP 补充数据库的 Rows_query 事件，-1 表示没有
+	counted   map[string]bool // 当前 Rows_query 事件已经统计过的表
+}
+
+// ParseBinlogFiles 解析本地的 binlog 文件，返回其中的 SQL 及每张表的 DML 统计
+// STATEMENT 格式使用 Query 事件中的 SQL，ROW 格式需要开启 binlog_rows_query_log_events 才能获取原始 SQL
+func ParseBinlogFiles(files []string) ([]QueryEvent, []*BinlogTableStat, error) {
+	p := &binlogParser{
+		tableMaps: make(map[uint64]*mysql.TableMap),
+		stats:     make(map[string]*BinlogTableStat),
+		rowsQuery: -1,
+	}
+	for _, file := range files {
+		f, err := os.Open(strings.TrimSpace(file))
+		if err != nil {
+			return p.events, p.tableStats(), err
+		}
+		err = p.parse(bufio.NewReader(f))
+		f.Close()
+		if err != nil {
+			return p.events, p.tableStats(), fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return p.events, p.tableStats(), nil
+}
+
+// parse 解析一个 binlog 文件
+func (p *binlogParser) parse(r io.Reader) error {
+	magic := make([]byte, len(binlogMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, binlogMagic) {
+		return fmt.Errorf("not a binlog file")
+	}
+
+	header := make([]byte, binlogEventHeaderSize)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		length := binary.LittleEndian.Uint32(header[9:13])
+		if length < binlogEventHeaderSize {
+			return fmt.Errorf("invalid event length %d", length)
+		}
+		buf := make([]byte, length)
+		copy(buf, header)
+		if _, err = io.ReadFull(r, buf[binlogEventHeaderSize:]); err != nil {
+			return err
+		}
+		if err = p.event(buf); err != nil {
+			return err
+		}
+	}
+}
+
+// event 处理一个事件，不支持或解析失败的事件会被忽略
+func (p *binlogParser) event(buf []byte) error {
+	ev := mysql.NewMysql56BinlogEvent(buf)
+	if !ev.IsValid() {
+		return fmt.Errorf("invalid event at %d", binary.LittleEndian.Uint32(buf[13:17]))
+	}
+	if ev.IsFormatDescription() {
+		format, err := ev.Format()
+		if err != nil {
+			return err
+		}
+		p.format = format
+		p.tableMaps = make(map[uint64]*mysql.TableMap)
+		return nil
+	}
+	if p.format.IsZero() {
+		return fmt.Errorf("missing FORMAT_DESCRIPTION_EVENT")
+	}
+	ev, _, err := ev.StripChecksum(p.format)
+	if err != nil {
+		return err
+	}
+	timestamp := time.Unix(int64(ev.Timestamp()), 0)
+
+	switch {
+	case ev.IsQuery():
+		q, err := ev.Query(p.format)
+		if err != nil {
+			common.Log.Warn("binlog Query event Error: %v", err)
+			return nil
+		}
+		sql := strings.TrimSuffix(strings.TrimSpace(q.SQL), ";")
+		if sql == "" || binlogTrxExp.MatchString(sql) {
+			return nil
+		}
+		data := buf[p.format.HeaderLength:]
+		p.events = append(p.events, QueryEvent{
+			Query:      sql,
+			Database:   q.Database,
+			Timestamp:  timestamp,
+			Connection: int64(binary.LittleEndian.Uint32(data[0:4])),
+			QueryTime:  float64(binary.LittleEndian.Uint32(data[4:8])),
+		})
+		if table := binlogDMLTable(sql, q.Database); table != "" {
+			p.stat(table).Statements++
+		}
+		p.rowsQuery = -1
+	case buf[4] == binlogRowsQueryEvent:
+		// 1 字节的长度（超过 255 时被截断，不可用）及 SQL
+		start := int(p.format.HeaderLength)
+		if len(p.format.HeaderSizes) >= binlogRowsQueryEvent {
+			start += int(p.format.HeaderSize(binlogRowsQueryEvent))
+		}
+		end := len(buf)
+		if p.format.ChecksumAlgorithm == mysql.BinlogChecksumAlgCRC32 {
+			end -= 4
+		}
+		if end-start < 1 {
+			return nil
+		}
+		sql := strings.TrimSuffix(strings.TrimSpace(string(buf[start+1:end])), ";")
+		if sql == "" {
+			return nil
+		}
+		p.events = append(p.events, QueryEvent{Query: sql, Timestamp: timestamp})
+		p.rowsQuery = len(p.events) - 1
+		p.counted = make(map[string]bool)
+	case ev.IsTableMap():
+		tm, err := ev.TableMap(p.format)
+		if err != nil {
+			common.Log.Warn("binlog TableMap event Error: %v", err)
+			return nil
+		}
+		p.tableMaps[ev.TableID(p.format)] = tm
+		// Rows_query 事件中没有数据库，使用其后第一个 TABLE_MAP 事件中的数据库
+		if p.rowsQuery >= 0 {
+			if p.events[p.rowsQuery].Database == "" {
+				p.events[p.rowsQuery].Database = tm.Database
+			}
+			table := tm.Database + "." + tm.Name
+			if !p.counted[table] {
+				p.counted[table] = true
+				p.stat(table).Statements++
+			}
+		}
+	case ev.IsWriteRows(), ev.IsUpdateRows(), ev.IsDeleteRows():
+		tm, ok := p.tableMaps[ev.TableID(p.format)]
+		if !ok {
+			return nil
+		}
+		rows, err := ev.Rows(p.format, tm)
+		if err != nil {
+			common.Log.Warn("binlog Rows event on %s.%s Error: %v", tm.Database, tm.Name, err)
+			return nil
+		}
+		stat := p.stat(tm.Database + "." + tm.Name)
+		switch {
+		case ev.IsWriteRows():
+			stat.Inserts += int64(len(rows.Rows))
+		case ev.IsUpdateRows():
+			stat.Updates += int64(len(rows.Rows))
+		default:
+			stat.Deletes += int64(len(rows.Rows))
+		}
+	case ev.IsXID():
+		p.rowsQuery = -1
+	}
+	return nil
+}
+
+// stat 获取表的 DML 统计，不存在时创建
+func (p *binlogParser) stat(table string) *BinlogTableStat {
+	stat, ok := p.stats[table]
+	if !ok {
+		stat = &BinlogTableStat{Table: table}
+		p.stats[table] = stat
+	}
+	return stat
+}
+
+// tableStats 按影响的行数及语句个数从大到小排序
+func (p *binlogParser) tableStats() []*BinlogTableStat {
+	var stats []*BinlogTableStat
+	for _, stat := range p.stats {
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		ri := stats[i].Inserts + stats[i].Updates + stats[i].Deletes
+		rj := stats[j].Inserts + stats[j].Updates + stats[j].Deletes
+		if ri != rj {
+			return ri > rj
+		}
+		if stats[i].Statements != stats[j].Statements {
+			return stats[i].Statements > stats[j].Statements
+		}
+		return stats[i].Table < stats[j].Table
+	})
+	return stats
+}
+
+// binlogDMLTable STATEMENT 格式中 DML 语句修改的表，多表时只返回第一张表
+func binlogDMLTable(sql, database string) string {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return ""
+	}
+	var table sqlparser.TableName
+	switch s := stmt.(type) {
+	case *sqlparser.Insert:
+		table = s.Table
+	case *sqlparser.Update:
+		table = firstTableName(s.TableExprs)
+	case *sqlparser.Delete:
+		if len(s.Targets) > 0 {
+			table = s.Targets[0]
+		} else {
+			table = firstTableName(s.TableExprs)
+		}
+	default:
+		return ""
+	}
+	if table.Name.IsEmpty() {
+		return ""
+	}
+	if !table.Qualifier.IsEmpty() {
+		database = table.Qualifier.String()
+	}
+	return database + "." + table.Name.String()
+}
+
+// firstTableName 获取 FROM 子句中的第一张表
+func firstTableName(exprs sqlparser.TableExprs) sqlparser.TableName {
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			if name, ok := e.Expr.(sqlparser.TableName); ok {
+				return name
+			}
+		case *sqlparser.JoinTableExpr:
+			return firstTableName(sqlparser.TableExprs{e.LeftExpr})
+		}
+	}
+	return sqlparser.TableName{}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+	"vitess.io/vitess/go/mysql"
+)
+
+// binlogSample 生成一个包含 STATEMENT 及 ROW 格式事件的 binlog 文件
+func binlogSample(t *testing.T) string {
+	f := mysql.NewMySQL56BinlogFormat()
+	s := mysql.NewFakeBinlogStream()
+	tm := &mysql.TableMap{
+		Database:  "sakila",
+		Name:      "film_actor",
+		Types:     []byte{mysql.TypeLong},
+		CanBeNull: mysql.NewServerBitmap(1),
+		Metadata:  []uint16{0},
+	}
+	identify := mysql.NewServerBitmap(1)
+	identify.Set(0, true)
+	rows := mysql.Rows{IdentifyColumns: identify}
+	for _, id := range []byte{1, 2} {
+		rows.Rows = append(rows.Rows, mysql.Row{
+			NullIdentifyColumns: mysql.NewServerBitmap(1),
+			Identify:            []byte{id, 0, 0, 0},
+		})
+	}
+	rowsQuery := "delete from film_actor where film_id < 3"
+
+	events := []mysql.BinlogEvent{
+		mysql.NewFormatDescriptionEvent(f, s),
+		mysql.NewQueryEvent(f, s, mysql.Query{Database: "sakila", SQL: "BEGIN"}),
+		mysql.NewQueryEvent(f, s, mysql.Query{Database: "sakila", SQL: "update film set rental_rate = 0.99 where film_id = 1"}),
+		mysql.NewQueryEvent(f, s, mysql.Query{Database: "world", SQL: "insert into sakila.actor(first_name) values ('A')"}),
+		mysql.NewXIDEvent(f, s),
+		mysql.NewQueryEvent(f, s, mysql.Query{Database: "sakila", SQL: "BEGIN"}),
+		mysql.NewMysql56BinlogEvent(s.Packetize(f, binlogRowsQueryEvent, 0, append([]byte{byte(len(rowsQuery))}, rowsQuery...))),
+		mysql.NewTableMapEvent(f, s, 1, tm),
+		mysql.NewDeleteRowsEvent(f, s, 1, rows),
+		mysql.NewXIDEvent(f, s),
+		mysql.NewQueryEvent(f, s, mysql.Query{Database: "world", SQL: "alter table city add index idx_name(name)"}),
+	}
+	buf := append([]byte{}, binlogMagic...)
+	for _, ev := range events {
+		buf = append(buf, ev.(interface{ Bytes() []byte }).Bytes()...)
+	}
+
+	dir, err := ioutil.TempDir("", "soar-binlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "mysql-bin.000001")
+	if err = ioutil.WriteFile(file, buf, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestParseBinlogFiles(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	file := binlogSample(t)
+	defer os.RemoveAll(filepath.Dir(file))
+
+	events, stats, err := ParseBinlogFiles([]string{file})
+	if err != nil {
+		t.Error(err)
+	}
+	err = common.GoldenDiff(func() {
+		for _, event := range events {
+			pretty.Println(event.Connection, event.Database, event.Query)
+		}
+		pretty.Println(stats)
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// 多个文件间累加 DML 统计
+	_, stats, err = ParseBinlogFiles([]string{file, file})
+	if err != nil || len(stats) == 0 || stats[0].Deletes != 4 {
+		t.Errorf("unexpected stats: %s, %v", pretty.Sprint(stats), err)
+	}
+
+	if _, _, err = ParseBinlogFiles([]string{"testdata/slow.log"}); err == nil {
+		t.Error("slow log should not be parsed as binlog")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -39,12 +39,14 @@ import (
 const (
 	TraditionalFormatExplain = iota // 默认输出
 	JSONFormatExplain               // JSON格式输出
+	TreeFormatExplain               // TREE格式输出，MySQL 8.0.16 及以上版本支持
 )
 
 // ExplainFormatType EXPLAIN 支持的 FORMAT_TYPE
 var ExplainFormatType = map[string]int{
 	"traditional": 0,
 	"json":        1,
+	"tree":        2,
 }
 
 // explain_type
@@ -52,6 +54,7 @@ const (
 	TraditionalExplainType = iota // 默认转出
 	ExtendedExplainType           // EXTENDED输出
 	PartitionsExplainType         // PARTITIONS输出
+	AnalyzeExplainType            // ANALYZE输出，MySQL 8.0.18 及以上版本支持，会真实执行查询
 )
 
 // ExplainType EXPLAIN命令支持的参数
@@ -59,6 +62,7 @@ var ExplainType = map[string]int{
 	"traditional": 0,
 	"extended":    1,
 	"partitions":  2,
+	"analyze":     3,
 }
 
 // 为TraditionalFormatExplain准备的结构体 { start
@@ -69,6 +73,7 @@ type ExplainInfo struct {
 	ExplainFormat int
 	ExplainRows   []ExplainRow
 	ExplainJSON   *ExplainJSON
+	ExplainTree   *ExplainNode // FORMAT=TREE 及 EXPLAIN ANALYZE 输出的迭代器树
 	Warnings      []ExplainWarning
 	QueryCost     float64
 }
@@ -139,6 +144,8 @@ type ExplainJSONTable struct {
 	CostInfo                 ExplainJSONCostInfo                 `json:"cost_info"`
 	UsedColumns              []string                            `json:"used_columns"`
 	AttachedCondition        string                              `json:"attached_condition"`
+	IndexCondition           string                              `json:"index_condition"`
+	UsingJoinBuffer          string                              `json:"using_join_buffer"`
 	AttachedSubqueries       []ExplainJSONSubqueries             `json:"attached_subqueries"`
 	MaterializedFromSubquery ExplainJSONMaterializedFromSubquery `json:"materialized_from_subquery"`
 }
@@ -179,12 +186,16 @@ type ExplainJSONDuplicatesRemoval struct {
 	BufferResult        ExplainJSONBufferResult      `json:"buffer_result"`
 	GroupingOperation   ExplainJSONGroupingOperation `json:"grouping_operation"`
 	Table               ExplainJSONTable             `json:"table"`
+	NestedLoop          []ExplainJSONNestedLoop      `json:"nested_loop"`
 }
 
 // ExplainJSONOrderingOperation JSON
 type ExplainJSONOrderingOperation struct {
+	UsingTemporaryTable     bool                         `json:"using_temporary_table"`
 	UsingFilesort           bool                         `json:"using_filesort"`
 	Table                   ExplainJSONTable             `json:"table"`
+	NestedLoop              []ExplainJSONNestedLoop      `json:"nested_loop"`
+	BufferResult            ExplainJSONBufferResult      `json:"buffer_result"`
 	DuplicatesRemoval       ExplainJSONDuplicatesRemoval `json:"duplicates_removal"`
 	GroupingOperation       ExplainJSONGroupingOperation `json:"grouping_operation"`
 	OrderbySubqueries       []ExplainJSONSubqueries      `json:"order_by_subqueries"`
@@ -199,6 +210,8 @@ type ExplainJSONQueryBlock struct {
 	NestedLoop              []ExplainJSONNestedLoop      `json:"nested_loop"`
 	OrderingOperation       ExplainJSONOrderingOperation `json:"ordering_operation"`
 	GroupingOperation       ExplainJSONGroupingOperation `json:"grouping_operation"`
+	DuplicatesRemoval       ExplainJSONDuplicatesRemoval `json:"duplicates_removal"`
+	BufferResult            ExplainJSONBufferResult      `json:"buffer_result"`
 	OptimizedAwaySubqueries []ExplainJSONSubqueries      `json:"optimized_away_subqueries"`
 	HavingSubqueries        []ExplainJSONSubqueries      `json:"having_subqueries"`
 	SelectListSubqueries    []ExplainJSONSubqueries      `json:"select_list_subqueries"`
@@ -221,6 +234,82 @@ type ExplainJSON struct {
 	QueryBlock ExplainJSONQueryBlock `json:"query_block"`
 }
 
+// JoinTables 查询块中按连接顺序排列的表，包括 ORDER BY, GROUP BY, DISTINCT 等操作下的表
+func (qb *ExplainJSONQueryBlock) JoinTables() []*ExplainJSONTable {
+	var tables []*ExplainJSONTable
+	add := func(table *ExplainJSONTable, nestedLoop []ExplainJSONNestedLoop) {
+		if table.TableName != "" {
+			tables = append(tables, table)
+		}
+		for i := range nestedLoop {
+			if nestedLoop[i].Table.TableName != "" {
+				tables = append(tables, &nestedLoop[i].Table)
+			}
+		}
+	}
+	addGrouping := func(op *ExplainJSONGroupingOperation) {
+		add(&op.Table, op.NestedLoop)
+	}
+	addBuffer := func(op *ExplainJSONBufferResult) {
+		add(&op.Table, op.NestedLoop)
+	}
+	addDuplicates := func(op *ExplainJSONDuplicatesRemoval) {
+		add(&op.Table, op.NestedLoop)
+		addGrouping(&op.GroupingOperation)
+		addBuffer(&op.BufferResult)
+	}
+
+	add(&qb.Table, qb.NestedLoop)
+	addGrouping(&qb.GroupingOperation)
+	addDuplicates(&qb.DuplicatesRemoval)
+	addBuffer(&qb.BufferResult)
+	ordering := &qb.OrderingOperation
+	add(&ordering.Table, ordering.NestedLoop)
+	addGrouping(&ordering.GroupingOperation)
+	addDuplicates(&ordering.DuplicatesRemoval)
+	addBuffer(&ordering.BufferResult)
+	return tables
+}
+
+// Subqueries 查询块中直接包含的子查询、派生表及 UNION 的查询块
+func (qb *ExplainJSONQueryBlock) Subqueries() []*ExplainJSONQueryBlock {
+	var blocks []*ExplainJSONQueryBlock
+	add := func(subqueries []ExplainJSONSubqueries) {
+		for i := range subqueries {
+			blocks = append(blocks, &subqueries[i].QueryBlock)
+		}
+	}
+	for _, table := range qb.JoinTables() {
+		add(table.AttachedSubqueries)
+		if table.MaterializedFromSubquery.QueryBlock != nil {
+			blocks = append(blocks, table.MaterializedFromSubquery.QueryBlock)
+		}
+	}
+	add(qb.OptimizedAwaySubqueries)
+	add(qb.HavingSubqueries)
+	add(qb.SelectListSubqueries)
+	add(qb.UpdateValueSubqueries)
+	add(qb.QuerySpecifications)
+	add(qb.UnionResult.QuerySpecifications)
+	add(qb.GroupingOperation.GroupBySubqueries)
+	add(qb.OrderingOperation.OrderbySubqueries)
+	add(qb.OrderingOperation.OptimizedAwaySubqueries)
+	add(qb.OrderingOperation.GroupingOperation.GroupBySubqueries)
+	return blocks
+}
+
+// Walk 深度优先遍历 JSON EXPLAIN 中所有的查询块
+func (exp *ExplainJSON) Walk(fn func(qb *ExplainJSONQueryBlock)) {
+	var walk func(qb *ExplainJSONQueryBlock)
+	walk = func(qb *ExplainJSONQueryBlock) {
+		fn(qb)
+		for _, sub := range qb.Subqueries() {
+			walk(sub)
+		}
+	}
+	walk(&exp.QueryBlock)
+}
+
 // 为JSONFormatExplain准备的结构体 end }
 
 // ExplainKeyWords 需要解释的关键字
@@ -431,43 +520,48 @@ func FormatJSONIntoTraditional(explainJSON string) []ExplainRow {
 	findTablesInJSON(explainJSON, 0)
 
 	var explainRows []ExplainRow
-	id := -1
 	for _, table := range explainJSONTables {
-		keyLen := table.KeyLength
-		filtered, err := strconv.ParseFloat(table.Filtered, 64)
-		if err != nil {
-			filtered = 0.00
-		}
-		if filtered > 100.00 {
-			filtered = 100.00
-		}
-		explainRows = append(explainRows, ExplainRow{
-			ID:           id + 1,
-			SelectType:   "",
-			TableName:    table.TableName,
-			Partitions:   "NULL",
-			AccessType:   table.AccessType,
-			PossibleKeys: table.PossibleKeys,
-			Key:          table.Key,
-			KeyLen:       keyLen,
-			Ref:          table.Ref,
-			Rows:         table.RowsExaminedPerScan,
-			Filtered:     filtered,
-			Scalability:  ExplainScalability[table.AccessType],
-			Extra:        "",
-		})
+		explainRows = append(explainRows, explainJSONTable2Row(0, table))
 	}
 	return explainRows
 }
 
-// ConvertExplainJSON2Row 将 JSON 格式转成 ROW 格式，为方便统一做优化建议
-// 但是会损失一些 JSON 特有的分析结果
-func ConvertExplainJSON2Row(explainJSON *ExplainJSON) []ExplainRow {
-	buf, err := json.Marshal(explainJSON)
+// explainJSONTable2Row 将 JSON 中的一个表转换为传统格式的一行
+func explainJSONTable2Row(id int, table *ExplainJSONTable) ExplainRow {
+	filtered, err := strconv.ParseFloat(table.Filtered, 64)
 	if err != nil {
-		return nil
+		filtered = 0.00
+	}
+	if filtered > 100.00 {
+		filtered = 100.00
+	}
+	return ExplainRow{
+		ID:           id,
+		SelectType:   "",
+		TableName:    table.TableName,
+		Partitions:   "NULL",
+		AccessType:   table.AccessType,
+		PossibleKeys: table.PossibleKeys,
+		Key:          table.Key,
+		KeyLen:       table.KeyLength,
+		Ref:          table.Ref,
+		Rows:         table.RowsExaminedPerScan,
+		Filtered:     filtered,
+		Scalability:  ExplainScalability[table.AccessType],
+		Extra:        "",
 	}
-	return FormatJSONIntoTraditional(string(buf))
+}
+
+// ConvertExplainJSON2Row 将 JSON 格式转成 ROW 格式，为方便统一做优化建议
+// 会损失一些 JSON 特有的分析结果，完整信息保留在 ExplainInfo.ExplainJSON 中
+func ConvertExplainJSON2Row(explainJSON *ExplainJSON) []ExplainRow {
+	var explainRows []ExplainRow
+	explainJSON.Walk(func(qb *ExplainJSONQueryBlock) {
+		for _, table := range qb.JoinTables() {
+			explainRows = append(explainRows, explainJSONTable2Row(qb.SelectID, table))
+		}
+	})
+	return explainRows
 }
 
 // 用于检测 MySQL 版本是否低于 MySQL5.6
@@ -570,6 +664,15 @@ func (db *Connector) explainQuery(sql string, explainType int, formatType int) s
 	if sql == "" || err != nil {
 		return sql
 	} else {
+		// EXPLAIN ANALYZE 会真实执行语句，DML 语句需要改写为 SELECT
+		if explainType == AnalyzeExplainType {
+			if stmt, err := sqlparser.Parse(sql); err == nil {
+				switch stmt.(type) {
+				case *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
+					sql = ast.NewRewrite(sql).RewriteDML2Select().NewSQL
+				}
+			}
+		}
 		// MySQL 5.7 support MAX_EXECUTION_TIME hint
 		// ref: https://dev.mysql.com/doc/refman/5.7/en/optimizer-hints.html
 		re := regexp.MustCompile(`(?i)(^select)(.*)`)
@@ -583,6 +686,10 @@ func (db *Connector) explainQuery(sql string, explainType int, formatType int) s
 		if common.Config.TestDSN.Version >= 50600 {
 			explainFormat = "FORMAT=JSON"
 		}
+	case TreeFormatExplain:
+		if common.Config.TestDSN.Version >= 80016 {
+			explainFormat = "FORMAT=TREE"
+		}
 	}
 
 	// 执行 explain
@@ -596,6 +703,8 @@ func (db *Connector) explainQuery(sql string, explainType int, formatType int) s
 		}
 	case PartitionsExplainType:
 		sql = fmt.Sprintf("explain partitions %s", sql)
+	case AnalyzeExplainType:
+		sql = fmt.Sprintf("explain analyze %s", sql)
 
 	default:
 		sql = fmt.Sprintf("explain %s %s", explainFormat, sql)
@@ -738,6 +847,13 @@ func ExplainInfoTranslator(exp *ExplainInfo) string {
 	return strings.Join(buf, "\n")
 }
 
+// IsExplainText 判断用户输入是否为 ParseExplainText 支持的 EXPLAIN 文本
+func IsExplainText(content string) bool {
+	content = strings.TrimSpace(content)
+	return strings.HasPrefix(content, "*") || strings.HasPrefix(content, "{") ||
+		strings.HasPrefix(content, "+") || IsTreeExplainText(content)
+}
+
 // ParseExplainText 解析explain文本信息（很可能是用户复制粘贴得到），返回格式化数据
 func ParseExplainText(content string) (exp *ExplainInfo, err error) {
 	exp = &ExplainInfo{ExplainFormat: TraditionalFormatExplain}
@@ -747,6 +863,13 @@ func ParseExplainText(content string) (exp *ExplainInfo, err error) {
 	jsonFormat := strings.HasPrefix(content, "{")
 	traditionalFormat := strings.HasPrefix(content, "+")
 
+	// FORMAT=TREE 及 EXPLAIN ANALYZE 也可能以表格或竖排格式粘贴，需要优先判断
+	if IsTreeExplainText(content) {
+		exp.ExplainFormat = TreeFormatExplain
+		exp.ExplainTree, err = parseTreeExplainText(content)
+		return exp, err
+	}
+
 	if verticalFormat && traditionalFormat && jsonFormat {
 		return nil, errors.New("not supported explain type")
 	}
@@ -812,10 +935,13 @@ func parseTraditionalExplainText(content string) (explainRows []ExplainRow, err
 			colsMap[item] = cols[i]
 		}
 
-		// 值类型转换
-		id, err := strconv.Atoi(colsMap["id"])
-		if err != nil {
-			return nil, err
+		// 值类型转换，UNION RESULT 的 id 为 NULL
+		id := 0
+		if colsMap["id"] != "NULL" {
+			id, err = strconv.Atoi(colsMap["id"])
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		// 不存在字段给默认值
@@ -827,7 +953,7 @@ func parseTraditionalExplainText(content string) (explainRows []ExplainRow, err
 
 		keylen = colsMap["key_len"]
 
-		rows, err = strconv.ParseInt(colsMap["Rows"], 10, 64)
+		rows, err = strconv.ParseInt(colsMap["rows"], 10, 64)
 		if err != nil {
 			rows = 0
 		}
@@ -886,9 +1012,12 @@ func parseVerticalExplainText(content string) (explainRows []ExplainRow, err err
 		}
 		if strings.HasPrefix(l, "id:") {
 			id := strings.TrimPrefix(l, "id: ")
-			explainRow.ID, err = strconv.Atoi(id)
-			if err != nil {
-				return nil, err
+			explainRow.ID = 0
+			if id != "NULL" {
+				explainRow.ID, err = strconv.Atoi(id)
+				if err != nil {
+					return nil, err
+				}
 			}
 		}
 		if strings.HasPrefix(l, "select_type:") {
@@ -917,20 +1046,24 @@ func parseVerticalExplainText(content string) (explainRows []ExplainRow, err err
 		if strings.HasPrefix(l, "ref:") {
 			explainRow.Ref = strings.Split(strings.TrimPrefix(l, "ref: "), ",")
 		}
-		if strings.HasPrefix(l, "Rows:") {
-			rows := strings.TrimPrefix(l, "Rows: ")
-			explainRow.Rows, err = strconv.ParseInt(rows, 10, 64)
-			if err != nil {
-				explainRow.Rows = 0
+		if strings.HasPrefix(l, "rows:") {
+			// UNION RESULT 等行的 rows 为 NULL
+			rows, rowsErr := strconv.ParseInt(strings.TrimPrefix(l, "rows: "), 10, 64)
+			if rowsErr != nil {
+				rows = 0
 			}
+			explainRow.Rows = rows
 		}
 		if strings.HasPrefix(l, "filtered:") {
 			filtered := strings.TrimPrefix(l, "filtered: ")
-			explainRow.Filtered, err = strconv.ParseFloat(filtered, 64)
-			if err != nil {
-				return nil, err
-			} else if explainRow.Filtered > 100.00 {
-				explainRow.Filtered = 100.00
+			explainRow.Filtered = 0.00
+			if filtered != "NULL" {
+				explainRow.Filtered, err = strconv.ParseFloat(filtered, 64)
+				if err != nil {
+					return nil, err
+				} else if explainRow.Filtered > 100.00 {
+					explainRow.Filtered = 100.00
+				}
 			}
 		}
 		if strings.HasPrefix(l, "Extra:") {
@@ -967,6 +1100,20 @@ func ParseExplainResult(res QueryResult, formatType int) (exp *ExplainInfo, err
 		return exp, err
 	}
 
+	// TREE 格式及 EXPLAIN ANALYZE 只有一列文本
+	if formatType == TreeFormatExplain {
+		if res.Rows.Next() {
+			var explainString string
+			err = res.Rows.Scan(&explainString)
+			if err != nil {
+				common.Log.Debug(err.Error())
+			}
+			exp.ExplainTree, err = parseTreeExplainText(explainString)
+		}
+		res.Rows.Close()
+		return exp, err
+	}
+
 	/*
 				+----+-------------+-------+------------+------+---------------+------+---------+------+------+----------+-------+
 				| id | select_type | table | partitions | type | possible_keys | key  | key_len | ref  | rows | filtered | Extra |
@@ -1067,7 +1214,15 @@ func ParseExplainResult(res QueryResult, formatType int) (exp *ExplainInfo, err
 // Explain 获取 SQL 的 explain 信息
 func (db *Connector) Explain(sql string, explainType int, formatType int) (exp *ExplainInfo, err error) {
 	exp = &ExplainInfo{SQL: sql}
-	if explainType != TraditionalExplainType {
+	switch {
+	case explainType == AnalyzeExplainType && common.Config.TestDSN.Version >= 80018:
+		// EXPLAIN ANALYZE 只支持 TREE 格式输出
+		formatType = TreeFormatExplain
+	case explainType == AnalyzeExplainType:
+		explainType, formatType = TraditionalExplainType, TraditionalFormatExplain
+	case explainType != TraditionalExplainType:
+		formatType = TraditionalFormatExplain
+	case formatType == TreeFormatExplain && common.Config.TestDSN.Version < 80016:
 		formatType = TraditionalFormatExplain
 	}
 
@@ -1099,6 +1254,12 @@ func (db *Connector) Explain(sql string, explainType int, formatType int) (exp *
 // PrintMarkdownExplainTable 打印 markdown 格式的 explain table
 func PrintMarkdownExplainTable(exp *ExplainInfo) string {
 	var buf []string
+	if exp.ExplainFormat == TreeFormatExplain {
+		if exp.ExplainTree == nil {
+			return ""
+		}
+		return fmt.Sprintf("```text\n%s\n```\n", exp.ExplainTree.String())
+	}
 	rows := exp.ExplainRows
 	// JSON 转换为 TRADITIONAL 格式
 	if exp.ExplainFormat == JSONFormatExplain {
@@ -18,6 +18,7 @@ package database
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/XiaoMi/soar/common"
@@ -2372,6 +2373,75 @@ func TestParseExplainText(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestParseExplainTextUnion(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	// UNION RESULT 的 id 为 NULL，MySQL 输出的列名为小写的 rows
+	contents := []string{
+		`+------+--------------+------------+------------+------+---------------+------+---------+------+------+----------+-----------------+
+| id   | select_type  | table      | partitions | type | possible_keys | key  | key_len | ref  | rows | filtered | Extra           |
++------+--------------+------------+------------+------+---------------+------+---------+------+------+----------+-----------------+
+|    1 | PRIMARY      | actor      | NULL       | ALL  | NULL          | NULL | NULL    | NULL |  200 |   100.00 | NULL            |
+|    2 | UNION        | customer   | NULL       | ALL  | NULL          | NULL | NULL    | NULL |  599 |   100.00 | NULL            |
+| NULL | UNION RESULT | <union1,2> | NULL       | ALL  | NULL          | NULL | NULL    | NULL | NULL |     NULL | Using temporary |
++------+--------------+------------+------------+------+---------------+------+---------+------+------+----------+-----------------+`,
+		`*************************** 1. row ***************************
+           id: 1
+  select_type: PRIMARY
+        table: actor
+   partitions: NULL
+         type: ALL
+possible_keys: NULL
+          key: NULL
+      key_len: NULL
+          ref: NULL
+         rows: 200
+     filtered: 100.00
+        Extra: NULL
+*************************** 2. row ***************************
+           id: 2
+  select_type: UNION
+        table: customer
+   partitions: NULL
+         type: ALL
+possible_keys: NULL
+          key: NULL
+      key_len: NULL
+          ref: NULL
+         rows: 599
+     filtered: 100.00
+        Extra: NULL
+*************************** 3. row ***************************
+           id: NULL
+  select_type: UNION RESULT
+        table: <union1,2>
+   partitions: NULL
+         type: ALL
+possible_keys: NULL
+          key: NULL
+      key_len: NULL
+          ref: NULL
+         rows: NULL
+     filtered: NULL
+        Extra: Using temporary`,
+	}
+	err := common.GoldenDiff(func() {
+		for _, content := range contents {
+			explainInfo, err := ParseExplainText(content)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			for _, row := range explainInfo.ExplainRows {
+				fmt.Println(row.ID, row.SelectType, row.TableName, row.Rows)
+			}
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestFindTablesInJson(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	idx := 9
@@ -2473,3 +2543,32 @@ func TestExplainAbleSQL(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestExplainJSONWalk(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	err := common.GoldenDiff(func() {
+		for _, content := range exp {
+			if !strings.HasPrefix(content, "{") {
+				continue
+			}
+			explainJSON, err := parseJSONExplainText(content)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			var blocks []string
+			explainJSON.Walk(func(qb *ExplainJSONQueryBlock) {
+				var tables []string
+				for _, table := range qb.JoinTables() {
+					tables = append(tables, table.TableName)
+				}
+				blocks = append(blocks, fmt.Sprintf("#%d[%s]", qb.SelectID, strings.Join(tables, ",")))
+			})
+			fmt.Println(strings.Join(blocks, " "))
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
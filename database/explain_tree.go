@@ -0,0 +1,159 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExplainNode EXPLAIN FORMAT=TREE 及 EXPLAIN ANALYZE 输出中的一个迭代器
+// https://dev.mysql.com/doc/refman/8.0/en/explain.html#explain-analyze
+type ExplainNode struct {
+	Operation  string  // 迭代器描述，如：Table scan on film
+	Estimated  bool    // 是否包含 cost, rows 预估信息
+	Cost       float64 // 预估代价
+	Rows       float64 // 预估每次循环返回的行数
+	Analyzed   bool    // 是否包含 EXPLAIN ANALYZE 实际执行信息
+	Executed   bool    // EXPLAIN ANALYZE 中该迭代器是否被执行，未执行时输出 never executed
+	FirstTime  float64 // 返回第一行的平均耗时，单位 ms
+	LastTime   float64 // 返回全部行的平均耗时，单位 ms
+	ActualRows float64 // 每次循环实际返回的平均行数
+	Loops      int64   // 循环次数
+	Children   []*ExplainNode
+}
+
+const explainTreeNumber = `(\d+(?:\.\d+)?(?:e[+-]?\d+)?)`
+
+var (
+	// 树形 EXPLAIN 的起始行，支持 mysql 客户端的表格及竖排格式
+	explainTreeExp = regexp.MustCompile(`(?m)^[\s|]*(EXPLAIN:\s*)?->`)
+	// 8.0.32 以后的版本 cost 可能输出为 cost=1.2..3.4
+	explainTreeCostExp   = regexp.MustCompile(`\s*\(cost=(?:\d+(?:\.\d+)?(?:e[+-]?\d+)?\.\.)?` + explainTreeNumber + ` rows=` + explainTreeNumber + `\)`)
+	explainTreeActualExp = regexp.MustCompile(`\s*\(actual time=` + explainTreeNumber + `\.\.` + explainTreeNumber + ` rows=` + explainTreeNumber + ` loops=(\d+)\)`)
+	explainTreeNeverExp  = regexp.MustCompile(`\s*\(never executed\)`)
+)
+
+// IsTreeExplainText 判断用户输入的 EXPLAIN 信息是否为 FORMAT=TREE 或 EXPLAIN ANALYZE 的输出
+func IsTreeExplainText(content string) bool {
+	return explainTreeExp.MatchString(content)
+}
+
+// parseTreeExplainText 解析 FORMAT=TREE 或 EXPLAIN ANALYZE 文本，子迭代器比父迭代器多缩进 4 个空格
+func parseTreeExplainText(content string) (*ExplainNode, error) {
+	type level struct {
+		indent int
+		node   *ExplainNode
+	}
+	var root *ExplainNode
+	var stack []level
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r|")
+		idx := strings.Index(line, "->")
+		if idx < 0 {
+			continue
+		}
+		node := parseExplainTreeNode(strings.TrimSpace(line[idx+2:]))
+		if root == nil {
+			// 第一行前可能有表格边框或 EXPLAIN: 前缀，作为根节点
+			root = node
+			stack = []level{{indent: -1, node: node}}
+			continue
+		}
+		for len(stack) > 1 && stack[len(stack)-1].indent >= idx {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1].node
+		parent.Children = append(parent.Children, node)
+		stack = append(stack, level{indent: idx, node: node})
+	}
+	if root == nil {
+		return nil, errors.New("not a tree format explain")
+	}
+	return root, nil
+}
+
+// parseExplainTreeNode 解析单个迭代器的描述及代价信息
+func parseExplainTreeNode(text string) *ExplainNode {
+	node := &ExplainNode{Executed: true}
+	if m := explainTreeCostExp.FindStringSubmatch(text); m != nil {
+		node.Estimated = true
+		node.Cost, _ = strconv.ParseFloat(m[1], 64)
+		node.Rows, _ = strconv.ParseFloat(m[2], 64)
+		text = strings.Replace(text, m[0], "", 1)
+	}
+	if m := explainTreeActualExp.FindStringSubmatch(text); m != nil {
+		node.Analyzed = true
+		node.FirstTime, _ = strconv.ParseFloat(m[1], 64)
+		node.LastTime, _ = strconv.ParseFloat(m[2], 64)
+		node.ActualRows, _ = strconv.ParseFloat(m[3], 64)
+		node.Loops, _ = strconv.ParseInt(m[4], 10, 64)
+		text = strings.Replace(text, m[0], "", 1)
+	} else if m := explainTreeNeverExp.FindString(text); m != "" {
+		node.Analyzed, node.Executed = true, false
+		text = strings.Replace(text, m, "", 1)
+	}
+	node.Operation = strings.TrimSpace(text)
+	return node
+}
+
+// Walk 深度优先遍历迭代器树
+func (node *ExplainNode) Walk(fn func(node *ExplainNode, depth int)) {
+	var walk func(n *ExplainNode, depth int)
+	walk = func(n *ExplainNode, depth int) {
+		fn(n, depth)
+		for _, child := range n.Children {
+			walk(child, depth+1)
+		}
+	}
+	walk(node, 0)
+}
+
+// TotalRows 迭代器处理的总行数，EXPLAIN ANALYZE 时为实际行数乘以循环次数，否则为预估行数
+func (node *ExplainNode) TotalRows() float64 {
+	if node.Analyzed {
+		return node.ActualRows * float64(node.Loops)
+	}
+	return node.Rows
+}
+
+// String 按 FORMAT=TREE 的格式输出
+func (node *ExplainNode) String() string {
+	var buf []string
+	node.Walk(func(n *ExplainNode, depth int) {
+		line := fmt.Sprintf("%s-> %s", strings.Repeat("    ", depth), n.Operation)
+		if n.Estimated {
+			line += fmt.Sprintf("  (cost=%s rows=%s)", formatTreeNumber(n.Cost), formatTreeNumber(n.Rows))
+		}
+		switch {
+		case n.Analyzed && n.Executed:
+			line += fmt.Sprintf(" (actual time=%s..%s rows=%s loops=%d)", formatTreeNumber(n.FirstTime),
+				formatTreeNumber(n.LastTime), formatTreeNumber(n.ActualRows), n.Loops)
+		case n.Analyzed:
+			line += " (never executed)"
+		}
+		buf = append(buf, line)
+	})
+	return strings.Join(buf, "\n")
+}
+
+func formatTreeNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
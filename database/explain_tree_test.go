@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+var treeExp = []string{
+	// FORMAT=TREE
+	`-> Nested loop inner join  (cost=4.95 rows=9)
+    -> Filter: (t1.a is not null)  (cost=1.15 rows=9)
+        -> Table scan on t1  (cost=1.15 rows=9)
+    -> Index lookup on t2 using idx1 (a=t1.a)  (cost=0.27 rows=1)`,
+	// EXPLAIN ANALYZE 表格格式
+	`+----------------------------------------------------------------------------------------------------------------+
+| EXPLAIN                                                                                                        |
++----------------------------------------------------------------------------------------------------------------+
+| -> Inner hash join (t2.a = t1.a)  (cost=4.70 rows=6) (actual time=0.213..0.244 rows=6 loops=1)
+    -> Table scan on t2  (cost=0.06 rows=6) (actual time=0.015..0.036 rows=6 loops=1)
+    -> Hash
+        -> Table scan on t1  (cost=0.85 rows=6) (actual time=0.039..0.062 rows=6 loops=1)
+ |
++----------------------------------------------------------------------------------------------------------------+`,
+	// EXPLAIN ANALYZE 竖排格式，包含子查询及未执行的迭代器
+	`*************************** 1. row ***************************
+EXPLAIN: -> Filter: (film.film_id = (select #2))  (cost=103.00 rows=1000) (actual time=0.502..0.502 rows=0 loops=1)
+    -> Table scan on film  (cost=103.00 rows=1000) (actual time=0.041..0.393 rows=1000 loops=1)
+    -> Select #2 (subquery in condition; run only once)
+        -> Sort: actor.actor_id  (cost=1.2..1.2 rows=200) (never executed)
+            -> Table scan on actor  (cost=20.25 rows=200) (never executed)`,
+}
+
+func TestParseTreeExplainText(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	err := common.GoldenDiff(func() {
+		for _, content := range treeExp {
+			if !IsTreeExplainText(content) {
+				t.Errorf("should be tree format: %s", content)
+			}
+			info, err := ParseExplainText(content)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			info.ExplainTree.Walk(func(node *ExplainNode, depth int) {
+				fmt.Println(depth, node.Operation, node.Estimated, node.Analyzed, node.Executed, node.TotalRows())
+			})
+			fmt.Println(PrintMarkdownExplainTable(info))
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, content := range exp {
+		if IsTreeExplainText(content) {
+			t.Errorf("should not be tree format: %s", content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestExplainNodeTotalRows(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	node := parseExplainTreeNode("Index lookup on t2 using idx1 (a=t1.a)  (cost=0.27 rows=1) (actual time=0.011..0.012 rows=2 loops=9)")
+	if node.Operation != "Index lookup on t2 using idx1 (a=t1.a)" || node.TotalRows() != 18 {
+		t.Errorf("got: %s", pretty.Sprint(node))
+	}
+	node = parseExplainTreeNode("Table scan on t1  (cost=1.15e+06 rows=9.5e+06)")
+	if node.Analyzed || node.TotalRows() != 9500000 || node.Cost != 1150000 {
+		t.Errorf("got: %s", pretty.Sprint(node))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// MySQL 5.7: 2019-06-01T08:00:01.123456Z	    8 Query	select 1
+	// MySQL 5.6: 190601  8:00:01	    8 Query	select 1，同一秒内的日志省略时间
+	generalLogHeaderExp = regexp.MustCompile(`^(\d{6}\s+\d{1,2}:\d\d:\d\d|\S+)?\t+\s*(\d+)\s+([A-Za-z][A-Za-z ]*?)(?:\t(.*))?$`)
+	// Connect	root@localhost on sakila using Socket
+	generalLogConnectExp = regexp.MustCompile(`\son\s+(\S*)\s+using\s`)
+)
+
+// ParseGeneralLog 解析 MySQL general log，按连接记录当前使用的数据库，只保留 Query 及 Execute 命令
+func ParseGeneralLog(r io.Reader) ([]QueryEvent, error) {
+	var events []QueryEvent
+	var event QueryEvent
+	var query []string
+	var timestamp time.Time
+	databases := make(map[int64]string) // 每个连接当前使用的数据库
+
+	// 遇到下一条日志的头部或文件结束时，保存之前的 SQL
+	flush := func() {
+		sql := strings.TrimSuffix(strings.TrimSpace(strings.Join(query, "\n")), ";")
+		if m := slowLogUseExp.FindStringSubmatch(sql); m != nil {
+			databases[event.Connection] = m[1]
+		} else if sql != "" {
+			event.Query = sql
+			event.Database = databases[event.Connection]
+			events = append(events, event)
+		}
+		event = QueryEvent{}
+		query = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	// 单条 SQL 可能很长
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		m := generalLogHeaderExp.FindStringSubmatch(line)
+		if m == nil {
+			// 多行 SQL 的后续行，非 Query 命令的参数被忽略
+			if len(query) > 0 {
+				query = append(query, line)
+			}
+			continue
+		}
+		flush()
+
+		if m[1] != "" {
+			timestamp = parseSlowLogTime(m[1])
+		}
+		id, _ := strconv.ParseInt(m[2], 10, 64)
+		switch m[3] {
+		case "Query", "Execute":
+			event.Connection = id
+			event.Timestamp = timestamp
+			query = append(query, m[4])
+		case "Connect":
+			if c := generalLogConnectExp.FindStringSubmatch(m[4]); c != nil {
+				databases[id] = c[1]
+			}
+		case "Init DB":
+			databases[id] = strings.TrimSpace(m[4])
+		case "Quit":
+			delete(databases, id)
+		}
+	}
+	flush()
+	return events, scanner.Err()
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestParseGeneralLog(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	// 不带时区的时间按 time.Local 解析，固定时区保证结果与运行环境无关
+	orgLocal := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = orgLocal }()
+
+	f, err := os.Open("testdata/general.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events, err := ParseQueryLog("general", f)
+	if err != nil {
+		t.Error(err)
+	}
+	err = common.GoldenDiff(func() {
+		for _, event := range events {
+			pretty.Println(event.Connection, event.Database, event.Query, event.Timestamp.UTC().String())
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
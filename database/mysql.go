@@ -306,12 +306,12 @@ func (db *Connector) IsView(tbName string) bool {
 // RemoveSQLComments 去除SQL中的注释
 func RemoveSQLComments(sql string) string {
 	buf := []byte(sql)
-	// ("(""|[^"]|(\"))*") 双引号中的内容, "", "\""
-	// ('(''|[^']|(\'))*') 单引号中的内容, '', '\''
+	// ("(""|[^"\\]|\\.)*") 双引号中的内容, "", "\""
+	// ('(''|[^'\\]|\\.)*') 单引号中的内容, '', '\''
 	// (--[^\n\r]*) 双减号注释
 	// (#.*) 井号注释
 	// (/\*([^*]|[\r\n]|(\*+([^*/]|[\r\n])))*\*+/) 多行注释
-	commentRegex := regexp.MustCompile(`("(""|[^"]|(\"))*")|('(''|[^']|(\'))*')|(--[^\n\r]*)|(#.*)|(/\*([^*]|[\r\n]|(\*+([^*/]|[\r\n])))*\*+/)`)
+	commentRegex := regexp.MustCompile(`("(""|[^"\\]|\\.)*")|('(''|[^'\\]|\\.)*')|(--[^\n\r]*)|(#.*)|(/\*([^*]|[\r\n]|(\*+([^*/]|[\r\n])))*\*+/)`)
 
 	res := commentRegex.ReplaceAllFunc(buf, func(s []byte) []byte {
 		if (s[0] == '"' && s[len(s)-1] == '"') ||
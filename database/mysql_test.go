@@ -150,14 +150,13 @@ func TestRemoveSQLComments(t *testing.T) {
 	// Notice: double dash without space not comment, eg. `--not comment`
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	SQLs := []string{
-		// FIXME: comments in two quotes string won't be remove
-		// 		`"abc" /* comment */ "abc"`,
-		// 		`"abc"
-		// # comment
-		// "abc"`,
-		// 		`"abc"
-		// -- comment
-		// "abc"`,
+		`"abc" /* comment */ "abc"`,
+		`"abc"
+# comment
+"abc"`,
+		`"abc"
+-- comment
+"abc"`,
 		`select 'c#\'#not comment'`,
 		`select "c#\"#not comment"`,
 		`-- comment`,
@@ -168,6 +167,11 @@ func TestRemoveSQLComments(t *testing.T) {
 comment*/`,
 		`--
 -- comment`,
+		// 反斜杠转义及两个引号转义
+		`select 'a\\' -- comment`,
+		`select 'it''s' # comment`,
+		`select "say ""hi""" /* comment */`,
+		`{"attached_condition": "(` + "`film`.`title`" + ` = 'a\"#b')"}`,
 	}
 
 	// fmt.Println(RemoveSQLComments(SQLs[0]))
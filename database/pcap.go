@@ -0,0 +1,285 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pcap 文件中的链路层类型
+const (
+	pcapLinkNull     = 0
+	pcapLinkEthernet = 1
+	pcapLinkRawBSD   = 12
+	pcapLinkRaw      = 101
+	pcapLinkSLL      = 113
+	pcapLinkSLL2     = 276
+)
+
+// pcapMaxPending 乱序等待的 TCP 分段超过该值时认为中间的分段已丢失
+const pcapMaxPending = 64
+
+// pcapMaxSnaplen tcpdump 默认的 snaplen，文件头中的 snaplen 为 0 或超过该值时作为记录长度的上限
+const pcapMaxSnaplen = 262144
+
+// tcpSegment 抓包中的一个 TCP 分段
+type tcpSegment struct {
+	Timestamp time.Time
+	Src       string // ip:port
+	Dst       string
+	SrcPort   int
+	DstPort   int
+	Seq       uint32
+	SYN       bool
+	FIN       bool
+	RST       bool
+	Payload   []byte
+}
+
+// tcpStream 单方向的 TCP 流重组，按序号交付数据，重传的数据会被丢弃
+type tcpStream struct {
+	started bool
+	next    uint32
+	pending map[uint32]tcpSegment
+}
+
+// ParsePcapFiles 解析本地的 pcap 文件，port 为 MySQL 服务端口，返回客户端发送的 SQL
+// 只支持 tcpdump -w 生成的 pcap 格式，不支持 pcapng，SSL 及压缩协议的连接会被忽略
+func ParsePcapFiles(files []string, port int) ([]QueryEvent, error) {
+	p := newMySQLCapture(port)
+	for _, file := range files {
+		f, err := os.Open(strings.TrimSpace(file))
+		if err != nil {
+			return p.events, err
+		}
+		err = readPcap(bufio.NewReader(f), p.segment)
+		f.Close()
+		if err != nil {
+			return p.events, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return p.events, nil
+}
+
+// readPcap 按顺序读取 pcap 文件中的 TCP 分段
+func readPcap(r io.Reader, handle func(tcpSegment)) error {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("not a pcap file")
+	}
+
+	var order binary.ByteOrder
+	nano := false
+	switch binary.LittleEndian.Uint32(header[0:4]) {
+	case 0xa1b2c3d4:
+		order = binary.LittleEndian
+	case 0xa1b23c4d:
+		order, nano = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		order = binary.BigEndian
+	case 0x4d3cb2a1:
+		order, nano = binary.BigEndian, true
+	case 0x0a0d0d0a:
+		return fmt.Errorf("pcapng is not supported, convert it with: editcap -F pcap")
+	default:
+		return fmt.Errorf("not a pcap file")
+	}
+	link := order.Uint32(header[20:24]) & 0x0fffffff
+	snaplen := order.Uint32(header[16:20])
+	if snaplen == 0 || snaplen > pcapMaxSnaplen {
+		snaplen = pcapMaxSnaplen
+	}
+
+	record := make([]byte, 16)
+	for {
+		_, err := io.ReadFull(r, record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		sec, frac := order.Uint32(record[0:4]), order.Uint32(record[4:8])
+		// 文件损坏时记录长度可能是任意值，超过 snaplen 时不再继续读取
+		length := order.Uint32(record[8:12])
+		if length > snaplen {
+			return fmt.Errorf("invalid pcap record length %d, snaplen %d", length, snaplen)
+		}
+		data := make([]byte, length)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return err
+		}
+
+		ts := time.Unix(int64(sec), int64(frac)*1000)
+		if nano {
+			ts = time.Unix(int64(sec), int64(frac))
+		}
+		if seg, ok := decodePacket(link, data); ok {
+			seg.Timestamp = ts
+			handle(seg)
+		}
+	}
+}
+
+// decodePacket 解析链路层、IP 层及 TCP 层，IP 分片及其他协议会被忽略
+func decodePacket(link uint32, data []byte) (tcpSegment, bool) {
+	var seg tcpSegment
+	var proto uint16
+	switch link {
+	case pcapLinkEthernet:
+		if len(data) < 14 {
+			return seg, false
+		}
+		proto, data = binary.BigEndian.Uint16(data[12:14]), data[14:]
+		// 802.1Q VLAN
+		for proto == 0x8100 && len(data) >= 4 {
+			proto, data = binary.BigEndian.Uint16(data[2:4]), data[4:]
+		}
+	case pcapLinkSLL:
+		if len(data) < 16 {
+			return seg, false
+		}
+		proto, data = binary.BigEndian.Uint16(data[14:16]), data[16:]
+	case pcapLinkSLL2:
+		if len(data) < 20 {
+			return seg, false
+		}
+		proto, data = binary.BigEndian.Uint16(data[0:2]), data[20:]
+	case pcapLinkNull, pcapLinkRawBSD, pcapLinkRaw:
+		if link == pcapLinkNull {
+			if len(data) < 4 {
+				return seg, false
+			}
+			data = data[4:]
+		}
+		if len(data) == 0 {
+			return seg, false
+		}
+		proto = 0x0800
+		if data[0]>>4 == 6 {
+			proto = 0x86dd
+		}
+	default:
+		return seg, false
+	}
+
+	var src, dst net.IP
+	switch proto {
+	case 0x0800:
+		if len(data) < 20 || data[9] != 6 {
+			return seg, false
+		}
+		// 不处理 IP 分片
+		if binary.BigEndian.Uint16(data[6:8])&0x3fff != 0 {
+			return seg, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(data[2:4]))
+		if ihl < 20 || total < ihl || total > len(data) {
+			return seg, false
+		}
+		src, dst, data = net.IP(data[12:16]), net.IP(data[16:20]), data[ihl:total]
+	case 0x86dd:
+		if len(data) < 40 || data[6] != 6 {
+			return seg, false
+		}
+		total := 40 + int(binary.BigEndian.Uint16(data[4:6]))
+		if total > len(data) {
+			return seg, false
+		}
+		src, dst, data = net.IP(data[8:24]), net.IP(data[24:40]), data[40:total]
+	default:
+		return seg, false
+	}
+
+	if len(data) < 20 {
+		return seg, false
+	}
+	offset := int(data[12]>>4) * 4
+	if offset < 20 || offset > len(data) {
+		return seg, false
+	}
+	seg.SrcPort = int(binary.BigEndian.Uint16(data[0:2]))
+	seg.DstPort = int(binary.BigEndian.Uint16(data[2:4]))
+	seg.Src = net.JoinHostPort(src.String(), strconv.Itoa(seg.SrcPort))
+	seg.Dst = net.JoinHostPort(dst.String(), strconv.Itoa(seg.DstPort))
+	seg.Seq = binary.BigEndian.Uint32(data[4:8])
+	flags := data[13]
+	seg.FIN, seg.SYN, seg.RST = flags&0x01 != 0, flags&0x02 != 0, flags&0x04 != 0
+	seg.Payload = data[offset:]
+	return seg, true
+}
+
+// add 添加一个分段，返回按序可以交付的分段，lost 表示中间有数据丢失
+func (s *tcpStream) add(seg tcpSegment) (ready []tcpSegment, lost bool) {
+	if seg.SYN {
+		s.started, s.next = true, seg.Seq+1
+		s.pending = make(map[uint32]tcpSegment)
+		return nil, false
+	}
+	if len(seg.Payload) == 0 {
+		return nil, false
+	}
+	if !s.started {
+		// 抓包开始时连接已经建立
+		s.started, s.next = true, seg.Seq
+		s.pending = make(map[uint32]tcpSegment)
+	}
+	s.pending[seg.Seq] = seg
+
+	for {
+		progress := false
+		for seq, p := range s.pending {
+			diff := int32(seq - s.next)
+			end := int32(seq + uint32(len(p.Payload)) - s.next)
+			switch {
+			case end <= 0:
+				// 重传的数据
+				delete(s.pending, seq)
+			case diff <= 0:
+				delete(s.pending, seq)
+				// 乱序的数据在补齐前面的分段后才可用
+				p.Payload, p.Timestamp = p.Payload[-diff:], seg.Timestamp
+				ready = append(ready, p)
+				s.next += uint32(len(p.Payload))
+				progress = true
+			}
+		}
+		if progress {
+			continue
+		}
+		if len(s.pending) <= pcapMaxPending {
+			return ready, lost
+		}
+		// 丢包，从最小的序号继续
+		var seqs []uint32
+		for seq := range s.pending {
+			seqs = append(seqs, seq)
+		}
+		sort.Slice(seqs, func(i, j int) bool { return int32(seqs[i]-s.next) < int32(seqs[j]-s.next) })
+		s.next, lost = seqs[0], true
+	}
+}
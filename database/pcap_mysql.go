@@ -0,0 +1,543 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// MySQL 协议中用到的命令及 capability flags
+const (
+	comInitDB      = 0x02
+	comQuery       = 0x03
+	comStmtPrepare = 0x16
+	comStmtExecute = 0x17
+	comStmtClose   = 0x19
+
+	clientCompress         = 0x00000020
+	clientConnectWithDB    = 0x00000008
+	clientProtocol41       = 0x00000200
+	clientSSL              = 0x00000800
+	clientSecureConn       = 0x00008000
+	clientPluginAuthLenenc = 0x00200000
+	clientQueryAttributes  = 0x08000000
+
+	// COM_STMT_EXECUTE 中 flags 的 PARAMETER_COUNT_AVAILABLE
+	stmtParameterCountAvailable = 0x08
+)
+
+// mysqlFixedSize 二进制协议中定长类型的字节数
+var mysqlFixedSize = map[byte]int{0x01: 1, 0x02: 2, 0x0d: 2, 0x03: 4, 0x09: 4, 0x04: 4, 0x08: 8, 0x05: 8}
+
+// mysqlStmt COM_STMT_PREPARE 成功后服务端返回的 statement
+type mysqlStmt struct {
+	query  string
+	params int
+	types  []byte // 每个参数 2 字节，只在第一次 COM_STMT_EXECUTE 时发送
+}
+
+// mysqlConn 一个连接的协议解析状态
+type mysqlConn struct {
+	id         int64
+	client     tcpStream
+	server     tcpStream
+	clientBuf  []byte
+	serverBuf  []byte
+	database   string
+	handshake  bool // 已解析客户端的握手包或已发送过命令
+	ignored    bool // SSL 或压缩协议，无法解析
+	attributes bool // CLIENT_QUERY_ATTRIBUTES
+	stmts      map[uint32]*mysqlStmt
+	prepare    string // 等待服务端返回 statement id 的 SQL
+	preparing  bool
+	pending    int // 等待服务端响应的 SQL 在 events 中的下标，-1 表示没有
+	requestAt  time.Time
+}
+
+// mysqlCapture 按连接解析抓包中的 MySQL 协议
+type mysqlCapture struct {
+	port   int
+	conns  map[string]*mysqlConn // 以客户端 ip:port 为 key
+	nextID int64
+	events []QueryEvent
+}
+
+func newMySQLCapture(port int) *mysqlCapture {
+	return &mysqlCapture{port: port, conns: make(map[string]*mysqlConn)}
+}
+
+// segment 处理一个 TCP 分段，根据端口区分客户端发送的请求及服务端的响应
+func (p *mysqlCapture) segment(seg tcpSegment) {
+	var key string
+	var fromClient bool
+	switch {
+	case seg.DstPort == p.port:
+		key, fromClient = seg.Src, true
+	case seg.SrcPort == p.port:
+		key = seg.Dst
+	default:
+		return
+	}
+
+	c, ok := p.conns[key]
+	// 客户端的 SYN 为新的连接
+	if !ok || (fromClient && seg.SYN) {
+		p.nextID++
+		c = &mysqlConn{id: p.nextID, stmts: make(map[uint32]*mysqlStmt), pending: -1}
+		p.conns[key] = c
+	}
+	if seg.FIN || seg.RST {
+		defer delete(p.conns, key)
+	}
+	if c.ignored {
+		return
+	}
+
+	if fromClient {
+		ready, lost := c.client.add(seg)
+		if lost {
+			c.clientBuf = nil
+		}
+		for _, r := range ready {
+			c.clientBuf = append(c.clientBuf, r.Payload...)
+			c.clientBuf = p.clientPackets(c, c.clientBuf, r.Timestamp)
+		}
+		return
+	}
+
+	ready, lost := c.server.add(seg)
+	if lost {
+		c.serverBuf = nil
+	}
+	for _, r := range ready {
+		// 请求到第一个响应分段的时间作为执行时间
+		if c.pending >= 0 {
+			p.events[c.pending].QueryTime = r.Timestamp.Sub(c.requestAt).Seconds()
+			c.pending = -1
+		}
+		if c.preparing {
+			c.serverBuf = append(c.serverBuf, r.Payload...)
+			c.prepareResponse()
+		}
+	}
+}
+
+// clientPackets 解析客户端发送的完整数据包，返回剩余的数据
+func (p *mysqlCapture) clientPackets(c *mysqlConn, buf []byte, ts time.Time) []byte {
+	for len(buf) >= 4 && !c.ignored {
+		length := int(buf[0]) | int(buf[1])<<8 | int(buf[2])<<16
+		if len(buf) < 4+length {
+			break
+		}
+		seq, payload := buf[3], buf[4:4+length]
+		buf = buf[4+length:]
+		if seq == 1 && !c.handshake {
+			c.handshakeResponse(payload)
+			continue
+		}
+		// 认证过程中的其他数据包及 LOAD DATA LOCAL 的文件内容
+		if seq != 0 || len(payload) == 0 {
+			continue
+		}
+		c.handshake = true
+		c.serverBuf, c.preparing = nil, false
+		p.command(c, payload, ts)
+	}
+	return buf
+}
+
+// handshakeResponse 解析客户端的握手包，获取连接时指定的数据库
+func (c *mysqlConn) handshakeResponse(payload []byte) {
+	c.handshake = true
+	if len(payload) < 32 {
+		return
+	}
+	caps := binary.LittleEndian.Uint32(payload[0:4])
+	if caps&clientProtocol41 == 0 {
+		return
+	}
+	if caps&clientSSL != 0 || caps&clientCompress != 0 {
+		common.Log.Debug("mysqlCapture ignore SSL or compressed connection %d", c.id)
+		c.ignored = true
+		return
+	}
+	c.attributes = caps&clientQueryAttributes != 0
+
+	// capability flags, max packet size, charset, reserved
+	data := payload[32:]
+	// username
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return
+	}
+	data = data[i+1:]
+	// auth response
+	switch {
+	case caps&clientPluginAuthLenenc != 0:
+		n, size := readLenencInt(data)
+		if size == 0 || int(n) > len(data)-size {
+			return
+		}
+		data = data[size+int(n):]
+	case caps&clientSecureConn != 0:
+		if len(data) < 1 || int(data[0]) > len(data)-1 {
+			return
+		}
+		data = data[1+int(data[0]):]
+	default:
+		i = bytes.IndexByte(data, 0)
+		if i < 0 {
+			return
+		}
+		data = data[i+1:]
+	}
+	if caps&clientConnectWithDB != 0 {
+		if i = bytes.IndexByte(data, 0); i >= 0 {
+			c.database = string(data[:i])
+		}
+	}
+}
+
+// command 解析客户端发送的命令
+func (p *mysqlCapture) command(c *mysqlConn, payload []byte, ts time.Time) {
+	switch payload[0] {
+	case comQuery:
+		data := payload[1:]
+		if c.attributes {
+			// query attributes 的参数个数及参数值
+			n, size := readLenencInt(data)
+			if size == 0 {
+				return
+			}
+			data = data[size:]
+			_, size = readLenencInt(data)
+			data = data[size:]
+			if n > 0 {
+				var err error
+				_, data, err = readBinaryParams(data, int(n), nil, true)
+				if err != nil {
+					common.Log.Debug("mysqlCapture query attributes Error: %v", err)
+					return
+				}
+			}
+		}
+		p.request(c, string(data), ts)
+	case comInitDB:
+		c.database = string(payload[1:])
+	case comStmtPrepare:
+		c.prepare, c.preparing = string(payload[1:]), true
+	case comStmtExecute:
+		if len(payload) < 10 {
+			return
+		}
+		stmt, ok := c.stmts[binary.LittleEndian.Uint32(payload[1:5])]
+		if !ok {
+			// 抓包开始前 PREPARE 的 statement
+			return
+		}
+		sql, err := stmt.bind(payload[5], payload[10:], c.attributes)
+		if err != nil {
+			common.Log.Debug("mysqlCapture COM_STMT_EXECUTE Error: %v, SQL: %s", err, stmt.query)
+			sql = stmt.query
+		}
+		p.request(c, sql, ts)
+	case comStmtClose:
+		if len(payload) >= 5 {
+			delete(c.stmts, binary.LittleEndian.Uint32(payload[1:5]))
+		}
+	}
+}
+
+// request 记录客户端发送的 SQL，USE 语句只修改连接当前使用的数据库
+func (p *mysqlCapture) request(c *mysqlConn, sql string, ts time.Time) {
+	sql = strings.TrimSuffix(strings.TrimSpace(sql), ";")
+	if sql == "" {
+		return
+	}
+	if m := slowLogUseExp.FindStringSubmatch(sql); m != nil {
+		c.database = m[1]
+		return
+	}
+	p.events = append(p.events, QueryEvent{Query: sql, Database: c.database, Timestamp: ts, Connection: c.id})
+	c.pending, c.requestAt = len(p.events)-1, ts
+}
+
+// prepareResponse 解析 COM_STMT_PREPARE 的响应，只需要第一个数据包
+func (c *mysqlConn) prepareResponse() {
+	buf := c.serverBuf
+	if len(buf) < 4 {
+		return
+	}
+	length := int(buf[0]) | int(buf[1])<<8 | int(buf[2])<<16
+	if len(buf) < 4+length {
+		return
+	}
+	payload := buf[4 : 4+length]
+	if len(payload) >= 9 && payload[0] == 0x00 {
+		c.stmts[binary.LittleEndian.Uint32(payload[1:5])] = &mysqlStmt{
+			query:  c.prepare,
+			params: int(binary.LittleEndian.Uint16(payload[7:9])),
+		}
+	}
+	c.serverBuf, c.preparing = nil, false
+}
+
+// bind 将 COM_STMT_EXECUTE 中的参数值替换到 SQL 的占位符中
+func (s *mysqlStmt) bind(flags byte, data []byte, attributes bool) (string, error) {
+	n := s.params
+	if attributes && flags&stmtParameterCountAvailable != 0 {
+		count, size := readLenencInt(data)
+		if size == 0 {
+			return "", fmt.Errorf("invalid parameter count")
+		}
+		n, data = int(count), data[size:]
+	}
+	if n == 0 {
+		return s.query, nil
+	}
+
+	values, _, err := readBinaryParams(data, n, s, attributes)
+	if err != nil {
+		return "", err
+	}
+	// query attributes 在 SQL 的参数之后
+	if len(values) > s.params {
+		values = values[:s.params]
+	}
+	return replacePlaceholders(s.query, values), nil
+}
+
+// readBinaryParams 解析二进制协议中的 NULL bitmap、参数类型及参数值
+// stmt 不为空时参数类型只在第一次执行时发送，withNames 表示参数类型后带有 query attributes 的名字
+func readBinaryParams(data []byte, n int, stmt *mysqlStmt, withNames bool) ([]string, []byte, error) {
+	nullBitmap := (n + 7) / 8
+	if len(data) < nullBitmap+1 {
+		return nil, data, fmt.Errorf("params overflow")
+	}
+	nulls, bound := data[:nullBitmap], data[nullBitmap]
+	data = data[nullBitmap+1:]
+
+	var types []byte
+	if bound == 1 {
+		for i := 0; i < n; i++ {
+			if len(data) < 2 {
+				return nil, data, fmt.Errorf("param types overflow")
+			}
+			types, data = append(types, data[0], data[1]), data[2:]
+			if withNames {
+				l, size := readLenencInt(data)
+				if size == 0 || int(l) > len(data)-size {
+					return nil, data, fmt.Errorf("param names overflow")
+				}
+				data = data[size+int(l):]
+			}
+		}
+		if stmt != nil {
+			stmt.types = types
+		}
+	} else if stmt != nil {
+		types = stmt.types
+	}
+	if len(types) < 2*n {
+		return nil, data, fmt.Errorf("missing param types")
+	}
+
+	var values []string
+	for i := 0; i < n; i++ {
+		if nulls[i/8]&(1<<(uint(i)%8)) != 0 {
+			values = append(values, "NULL")
+			continue
+		}
+		v, size, err := mysqlBinaryValue(types[2*i], types[2*i+1]&0x80 != 0, data)
+		if err != nil {
+			return nil, data, err
+		}
+		values, data = append(values, v), data[size:]
+	}
+	return values, data, nil
+}
+
+// mysqlBinaryValue 按二进制协议解析一个参数值，返回 SQL 中的字面量及占用的字节数
+func mysqlBinaryValue(typ byte, unsigned bool, data []byte) (string, int, error) {
+	if size, ok := mysqlFixedSize[typ]; ok && len(data) < size {
+		return "", 0, fmt.Errorf("value of type 0x%02x overflow", typ)
+	}
+
+	switch typ {
+	case 0x06: // NULL
+		return "NULL", 0, nil
+	case 0x01: // TINY
+		if unsigned {
+			return strconv.FormatUint(uint64(data[0]), 10), 1, nil
+		}
+		return strconv.FormatInt(int64(int8(data[0])), 10), 1, nil
+	case 0x02, 0x0d: // SHORT, YEAR
+		v := binary.LittleEndian.Uint16(data)
+		if unsigned {
+			return strconv.FormatUint(uint64(v), 10), 2, nil
+		}
+		return strconv.FormatInt(int64(int16(v)), 10), 2, nil
+	case 0x03, 0x09: // LONG, INT24
+		v := binary.LittleEndian.Uint32(data)
+		if unsigned {
+			return strconv.FormatUint(uint64(v), 10), 4, nil
+		}
+		return strconv.FormatInt(int64(int32(v)), 10), 4, nil
+	case 0x08: // LONGLONG
+		v := binary.LittleEndian.Uint64(data)
+		if unsigned {
+			return strconv.FormatUint(v, 10), 8, nil
+		}
+		return strconv.FormatInt(int64(v), 10), 8, nil
+	case 0x04: // FLOAT
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 'g', -1, 32), 4, nil
+	case 0x05: // DOUBLE
+		return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)), 'g', -1, 64), 8, nil
+	case 0x07, 0x0a, 0x0c: // TIMESTAMP, DATE, DATETIME
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return "", 0, fmt.Errorf("datetime overflow")
+		}
+		n, d := int(data[0]), data[1:]
+		var year, month, day, hour, minute, second, micro int
+		if n >= 4 {
+			year, month, day = int(binary.LittleEndian.Uint16(d)), int(d[2]), int(d[3])
+		}
+		if n >= 7 {
+			hour, minute, second = int(d[4]), int(d[5]), int(d[6])
+		}
+		if n >= 11 {
+			micro = int(binary.LittleEndian.Uint32(d[7:11]))
+		}
+		v := fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+		if typ != 0x0a {
+			v += fmt.Sprintf(" %02d:%02d:%02d", hour, minute, second)
+		}
+		if micro > 0 {
+			v += fmt.Sprintf(".%06d", micro)
+		}
+		return "'" + v + "'", 1 + n, nil
+	case 0x0b: // TIME
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return "", 0, fmt.Errorf("time overflow")
+		}
+		n, d := int(data[0]), data[1:]
+		v := "00:00:00"
+		if n >= 8 {
+			sign := ""
+			if d[0] == 1 {
+				sign = "-"
+			}
+			hours := int(binary.LittleEndian.Uint32(d[1:5]))*24 + int(d[5])
+			v = fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, d[6], d[7])
+			if n >= 12 {
+				v += fmt.Sprintf(".%06d", binary.LittleEndian.Uint32(d[8:12]))
+			}
+		}
+		return "'" + v + "'", 1 + n, nil
+	default:
+		// 字符串、DECIMAL、BLOB、JSON 等均为 length encoded string
+		l, size := readLenencInt(data)
+		if size == 0 || int(l) > len(data)-size {
+			return "", 0, fmt.Errorf("string of type 0x%02x overflow", typ)
+		}
+		v := string(data[size : size+int(l)])
+		if typ == 0x00 || typ == 0xf6 { // DECIMAL, NEWDECIMAL
+			return v, size + int(l), nil
+		}
+		return "'" + mysqlEscape(v) + "'", size + int(l), nil
+	}
+}
+
+// readLenencInt 解析 length encoded integer，返回值及占用的字节数，数据不完整时字节数为 0
+func readLenencInt(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	switch data[0] {
+	case 0xfc:
+		if len(data) < 3 {
+			return 0, 0
+		}
+		return uint64(binary.LittleEndian.Uint16(data[1:3])), 3
+	case 0xfd:
+		if len(data) < 4 {
+			return 0, 0
+		}
+		return uint64(data[1]) | uint64(data[2])<<8 | uint64(data[3])<<16, 4
+	case 0xfe:
+		if len(data) < 9 {
+			return 0, 0
+		}
+		return binary.LittleEndian.Uint64(data[1:9]), 9
+	case 0xfb, 0xff:
+		return 0, 0
+	default:
+		return uint64(data[0]), 1
+	}
+}
+
+// mysqlEscape 转义字符串中的特殊字符
+func mysqlEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\x00", `\0`, "\x1a", `\Z`).Replace(s)
+}
+
+// replacePlaceholders 按顺序替换 SQL 中不在引号及注释中的 ? 占位符
+func replacePlaceholders(sql string, values []string) string {
+	var buf strings.Builder
+	var quote byte
+	i, n := 0, 0
+	for i < len(sql) {
+		ch := sql[i]
+		switch {
+		case quote != 0:
+			buf.WriteByte(ch)
+			if ch == '\\' && quote != '`' && i+1 < len(sql) {
+				i++
+				buf.WriteByte(sql[i])
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+			buf.WriteByte(ch)
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				buf.WriteString(sql[i:])
+				return buf.String()
+			}
+			buf.WriteString(sql[i : i+4+end])
+			i += 4 + end
+			continue
+		case ch == '?' && n < len(values):
+			buf.WriteString(values[n])
+			n++
+		default:
+			buf.WriteByte(ch)
+		}
+		i++
+	}
+	return buf.String()
+}
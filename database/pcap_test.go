@@ -0,0 +1,210 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+// pcapWriter 生成 Ethernet + IPv4 + TCP 的 pcap 文件
+type pcapWriter struct {
+	buf  []byte
+	seqs map[string]uint32
+}
+
+func newPcapWriter() *pcapWriter {
+	w := &pcapWriter{seqs: make(map[string]uint32)}
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], 65535)
+	binary.LittleEndian.PutUint32(header[20:24], pcapLinkEthernet)
+	w.buf = header
+	return w
+}
+
+// packet 写入一个 TCP 分段，seq 为 0 时使用该方向上的下一个序号
+func (w *pcapWriter) packet(ms int, src, dst string, flags byte, seq uint32, payload []byte) uint32 {
+	if seq == 0 {
+		seq = w.seqs[src] + 1000
+	}
+	if end := seq + uint32(len(payload)) - 1000; len(payload) > 0 && end > w.seqs[src] {
+		w.seqs[src] = end
+	}
+	srcHost, srcPort, _ := net.SplitHostPort(src)
+	dstHost, dstPort, _ := net.SplitHostPort(dst)
+	sp, _ := net.LookupPort("tcp", srcPort)
+	dp, _ := net.LookupPort("tcp", dstPort)
+
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], uint16(sp))
+	binary.BigEndian.PutUint16(tcp[2:4], uint16(dp))
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12], tcp[13] = 5<<4, flags|0x10
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20)
+	ip[0], ip[8], ip[9] = 0x45, 64, 6
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	copy(ip[12:16], net.ParseIP(srcHost).To4())
+	copy(ip[16:20], net.ParseIP(dstHost).To4())
+
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	frame = append(append(frame, ip...), tcp...)
+
+	ts := time.Unix(1559376000, 0).Add(time.Duration(ms) * time.Millisecond)
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(frame)))
+	w.buf = append(append(w.buf, record...), frame...)
+	return seq
+}
+
+// mysqlPacket 添加 MySQL 协议的包头
+func mysqlPacket(seq byte, payload ...[]byte) []byte {
+	var data []byte
+	for _, p := range payload {
+		data = append(data, p...)
+	}
+	return append([]byte{byte(len(data)), byte(len(data) >> 8), byte(len(data) >> 16), seq}, data...)
+}
+
+func pcapSample(t *testing.T) string {
+	client, server, other := "10.0.0.1:50000", "10.0.0.2:3306", "10.0.0.3:50001"
+	w := newPcapWriter()
+	w.packet(0, client, server, 0x02, 999, nil)
+	w.packet(1, server, client, 0, 0, mysqlPacket(0, []byte{10}, []byte("5.7.26\x00")))
+
+	// 握手包中指定了数据库
+	caps := make([]byte, 32)
+	binary.LittleEndian.PutUint32(caps[0:4], clientProtocol41|clientSecureConn|clientConnectWithDB)
+	w.packet(2, client, server, 0, 0, mysqlPacket(1, caps, []byte("app\x00"), []byte{4}, []byte("auth"),
+		[]byte("sakila\x00"), []byte("mysql_native_password\x00")))
+	w.packet(3, server, client, 0, 0, mysqlPacket(2, []byte{0, 0, 0, 2, 0, 0, 0}))
+
+	// 分成两个乱序的 TCP 分段
+	query := mysqlPacket(0, []byte{comQuery}, []byte("select * from film where film_id = 1"))
+	seq := w.seqs[client] + 1000
+	w.packet(1000, client, server, 0, seq+10, query[10:])
+	w.packet(1001, client, server, 0, seq, query[:10])
+	w.packet(1026, server, client, 0, 0, mysqlPacket(1, []byte{1}))
+
+	w.packet(1500, client, server, 0, 0, mysqlPacket(0, []byte{comInitDB}, []byte("world")))
+	w.packet(1501, server, client, 0, 0, mysqlPacket(1, []byte{0, 0, 0, 2, 0, 0, 0}))
+
+	// 重传的分段
+	seq = w.packet(2000, client, server, 0, 0, mysqlPacket(0, []byte{comQuery}, []byte("select name from city where id = 1")))
+	w.packet(2050, client, server, 0, seq, mysqlPacket(0, []byte{comQuery}, []byte("select name from city where id = 1")))
+	w.packet(2100, server, client, 0, 0, mysqlPacket(1, []byte{1}))
+
+	w.packet(2500, client, server, 0, 0, mysqlPacket(0, []byte{comStmtPrepare},
+		[]byte("select * from city where name = ? /* ? */ and population > ? and district is ? and code = '?'")))
+	w.packet(2501, server, client, 0, 0, mysqlPacket(1, []byte{0, 7, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0}))
+	// 第三个参数为 NULL
+	w.packet(3000, client, server, 0, 0, mysqlPacket(0, []byte{comStmtExecute, 7, 0, 0, 0, 0, 1, 0, 0, 0},
+		[]byte{0x04, 1}, []byte{0xfd, 0, 0x08, 0, 0x06, 0},
+		[]byte{7}, []byte("O'Brien"), []byte{0xa0, 0x86, 1, 0, 0, 0, 0, 0}))
+	w.packet(3500, server, client, 0, 0, mysqlPacket(1, []byte{1}))
+
+	w.packet(4000, client, server, 0, 0, mysqlPacket(0, []byte{comQuery}, []byte("use sakila")))
+	w.packet(4001, server, client, 0, 0, mysqlPacket(1, []byte{0, 0, 0, 2, 0, 0, 0}))
+	w.packet(4100, client, server, 0, 0, mysqlPacket(0, []byte{comQuery}, []byte("select count(*) from actor")))
+
+	// 抓包开始前已经建立的连接
+	w.packet(5000, other, server, 0, 0, mysqlPacket(0, []byte{comQuery}, []byte("select * from payment where amount > 10")))
+	w.packet(5300, server, other, 0, 0, mysqlPacket(1, []byte{1}))
+	w.packet(6000, client, server, 0x01, 0, nil)
+
+	dir, err := ioutil.TempDir("", "soar-pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "mysql.pcap")
+	if err = ioutil.WriteFile(file, w.buf, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestParsePcapFiles(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	file := pcapSample(t)
+	defer os.RemoveAll(filepath.Dir(file))
+
+	events, err := ParsePcapFiles([]string{file}, 3306)
+	if err != nil {
+		t.Error(err)
+	}
+	err = common.GoldenDiff(func() {
+		for _, event := range events {
+			pretty.Println(event.Connection, event.Database, event.Query, event.QueryTime)
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, err = ParsePcapFiles([]string{"testdata/slow.log"}, 3306); err == nil {
+		t.Error("slow log should not be parsed as pcap")
+	}
+
+	// 损坏的文件，记录长度超过 snaplen
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(buf[24+8:24+12], 0xffffffff)
+	corrupt := filepath.Join(filepath.Dir(file), "corrupt.pcap")
+	if err = ioutil.WriteFile(corrupt, buf, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParsePcapFiles([]string{corrupt}, 3306); err == nil || !strings.Contains(err.Error(), "snaplen") {
+		t.Errorf("corrupt pcap record length should be rejected, got: %v", err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestReplacePlaceholders(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string]string{
+		"select ?, ?":                                "select 1, 'a'",
+		"select '?', `?`, \"\\\"?\", ? /* ? */":      "select '?', `?`, \"\\\"?\", 1 /* ? */",
+		"select ?, ?, ?":                             "select 1, 'a', ?",
+		"select * from film where title = 'it''s ?'": "select * from film where title = 'it''s ?'",
+	}
+	for sql, want := range cases {
+		if got := replacePlaceholders(sql, []string{"1", "'a'"}); got != want {
+			t.Errorf("replacePlaceholders(%s) got: %s, want: %s", sql, got, want)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/percona/go-mysql/query"
+)
+
+// processlistSQL 正在执行的 SQL，不包含 soar 自己的连接
+// performance_schema.threads 不需要持有 processlist 的全局锁，对线上环境影响更小，不可用时使用 information_schema.PROCESSLIST
+const (
+	processlistThreadsSQL = `SELECT PROCESSLIST_ID, IFNULL(PROCESSLIST_DB, ''), PROCESSLIST_COMMAND,
+  IFNULL(PROCESSLIST_TIME, 0), IFNULL(PROCESSLIST_STATE, ''), PROCESSLIST_INFO
+FROM performance_schema.threads
+WHERE TYPE = 'FOREGROUND' AND PROCESSLIST_COMMAND IN ('Query', 'Execute')
+  AND PROCESSLIST_INFO IS NOT NULL AND PROCESSLIST_ID <> CONNECTION_ID()`
+	processlistSQL = `SELECT ID, IFNULL(DB, ''), COMMAND, TIME, IFNULL(STATE, ''), INFO
+FROM information_schema.PROCESSLIST
+WHERE COMMAND IN ('Query', 'Execute') AND INFO IS NOT NULL AND ID <> CONNECTION_ID()`
+)
+
+// ProcessRow processlist 中正在执行 SQL 的线程
+type ProcessRow struct {
+	ID       int64
+	Database string
+	Command  string
+	Time     float64 // 已执行的时间，单位为秒
+	State    string
+	Info     string
+}
+
+// ProcessDigest 按 SQL 指纹聚合的 processlist 采样结果
+// Count 为不同的执行次数，同一线程上连续采样到的同一条 SQL 算一次执行，TotalTime 为每次执行被观测到的最长时间之和
+type ProcessDigest struct {
+	QueryDigest
+	Seen   int64    // 被采样到的次数
+	States []string // 采样到的线程状态
+}
+
+// ProcessSampler 多次采样 processlist 并按 SQL 指纹聚合
+type ProcessSampler struct {
+	digests []*ProcessDigest
+	index   map[string]*ProcessDigest
+	running map[int64]ProcessRow // 上一次采样时每个线程正在执行的 SQL
+}
+
+// NewProcessSampler 创建 ProcessSampler
+func NewProcessSampler() *ProcessSampler {
+	return &ProcessSampler{
+		index:   make(map[string]*ProcessDigest),
+		running: make(map[int64]ProcessRow),
+	}
+}
+
+// Processlist 获取正在执行 SQL 的线程
+func (db *Connector) Processlist() ([]ProcessRow, error) {
+	res, err := db.Conn.Query(processlistThreadsSQL)
+	if err != nil {
+		common.Log.Debug("Processlist Error: %v, fallback to information_schema.PROCESSLIST", err)
+		res, err = db.Conn.Query(processlistSQL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer res.Close()
+
+	var rows []ProcessRow
+	for res.Next() {
+		var row ProcessRow
+		err = res.Scan(&row.ID, &row.Database, &row.Command, &row.Time, &row.State, &row.Info)
+		if err != nil {
+			return rows, err
+		}
+		row.Info = strings.TrimSpace(row.Info)
+		rows = append(rows, row)
+	}
+	return rows, res.Err()
+}
+
+// SampleProcesslist 在 duration 时间内每隔 interval 采样一次 processlist
+// 第一次采样失败时返回错误，之后的采样失败只记录日志
+func (db *Connector) SampleProcesslist(interval, duration time.Duration) (*ProcessSampler, error) {
+	common.Log.Debug("SampleProcesslist with DSN(%s/%s) interval %s duration %s", db.Addr, db.Database, interval, duration)
+	sampler := NewProcessSampler()
+	rows, err := db.Processlist()
+	if err != nil {
+		return sampler, err
+	}
+	sampler.Add(rows)
+
+	if interval <= 0 {
+		return sampler, nil
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.After(duration)
+	for {
+		select {
+		case <-deadline:
+			return sampler, nil
+		case <-ticker.C:
+			rows, err = db.Processlist()
+			if err != nil {
+				common.Log.Warn("SampleProcesslist Error: %v", err)
+				continue
+			}
+			sampler.Add(rows)
+		}
+	}
+}
+
+// Add 添加一次采样的结果
+func (s *ProcessSampler) Add(rows []ProcessRow) {
+	running := make(map[int64]ProcessRow)
+	for _, row := range rows {
+		if row.Info == "" {
+			continue
+		}
+		running[row.ID] = row
+
+		fingerprint := strings.TrimSpace(query.Fingerprint(row.Info))
+		id := query.Id(fingerprint)
+		digest, ok := s.index[id]
+		if !ok {
+			digest = &ProcessDigest{QueryDigest: QueryDigest{ID: id, Fingerprint: fingerprint, Sample: row.Info, Database: row.Database}}
+			s.index[id] = digest
+			s.digests = append(s.digests, digest)
+		}
+		digest.Seen++
+		if row.State != "" {
+			digest.States = common.RemoveDuplicatesItem(append(digest.States, row.State))
+		}
+		if row.Time > digest.MaxTime {
+			digest.MaxTime = row.Time
+			digest.Sample = row.Info
+			digest.Database = row.Database
+		}
+
+		// 同一线程上执行时间没有变小的同一条 SQL 是同一次执行
+		prev, ok := s.running[row.ID]
+		if ok && prev.Info == row.Info && row.Time >= prev.Time {
+			digest.TotalTime += row.Time - prev.Time
+			continue
+		}
+		digest.Count++
+		digest.TotalTime += row.Time
+	}
+	s.running = running
+}
+
+// Digests 执行时间达到 minTime 的 SQL，按被采样到的次数及最长执行时间从大到小排序，n 大于 0 时只返回前 n 类
+func (s *ProcessSampler) Digests(minTime float64, n int) []*ProcessDigest {
+	var digests []*ProcessDigest
+	for _, digest := range s.digests {
+		if digest.MaxTime >= minTime {
+			digests = append(digests, digest)
+		}
+	}
+	sort.SliceStable(digests, func(i, j int) bool {
+		if digests[i].Seen != digests[j].Seen {
+			return digests[i].Seen > digests[j].Seen
+		}
+		return digests[i].MaxTime > digests[j].MaxTime
+	})
+	if n > 0 && n < len(digests) {
+		digests = digests[:n]
+	}
+	return digests
+}
+
+// ProcessDigests2SQL 将采样到的 SQL 拼接为待评审的 SQL
+func ProcessDigests2SQL(digests []*ProcessDigest) string {
+	var queries []*QueryDigest
+	for _, digest := range digests {
+		queries = append(queries, &digest.QueryDigest)
+	}
+	return QueryDigests2SQL(queries)
+}
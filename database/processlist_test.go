@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestProcesslist(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	rows, err := connTest.Processlist()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, row := range rows {
+		if row.Info == "" {
+			t.Errorf("unexpected row: %s", pretty.Sprint(row))
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestProcessSampler(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	samples := [][]ProcessRow{
+		{
+			{ID: 10, Database: "sakila", Command: "Query", Time: 3, State: "Sending data", Info: "select * from film where length > 100"},
+			{ID: 11, Database: "sakila", Command: "Query", Time: 0, State: "Sending data", Info: "select * from actor where actor_id = 1"},
+		},
+		{
+			// 线程 10 上同一次执行，线程 11 上执行了新的 SQL
+			{ID: 10, Database: "sakila", Command: "Query", Time: 4, State: "Creating sort index", Info: "select * from film where length > 100"},
+			{ID: 11, Database: "sakila", Command: "Query", Time: 1, State: "Sending data", Info: "select * from actor where actor_id = 2"},
+		},
+		{
+			// 线程 10 上再次执行同一条 SQL
+			{ID: 10, Database: "sakila", Command: "Query", Time: 1, State: "Sending data", Info: "select * from film where length > 100"},
+			{ID: 12, Database: "sakila", Command: "Query", Time: 0, State: "", Info: ""},
+		},
+	}
+	sampler := NewProcessSampler()
+	for _, rows := range samples {
+		sampler.Add(rows)
+	}
+
+	err := common.GoldenDiff(func() {
+		digests := sampler.Digests(0, 0)
+		pretty.Println(digests)
+		fmt.Println(ProcessDigests2SQL(digests))
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if digests := sampler.Digests(2, 0); len(digests) != 1 || digests[0].Count != 2 || digests[0].TotalTime != 5 {
+		t.Errorf("unexpected long-running digests: %s", pretty.Sprint(digests))
+	}
+	if digests := sampler.Digests(0, 1); len(digests) != 1 {
+		t.Errorf("want 1 digest, got %d", len(digests))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
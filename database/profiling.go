@@ -38,28 +38,36 @@ type ProfilingRow struct {
 	// TODO: 支持show profile all, 不过目前看所有的信息过多有点眼花缭乱
 }
 
-// Profiling 执行SQL，并对其 Profile
-func (db *Connector) Profiling(sql string, params ...interface{}) ([]ProfilingRow, error) {
-	var rows []ProfilingRow
+// profilingCheck 检查 SQL 是否需要且允许在测试环境中执行
+func (db *Connector) profilingCheck(sql string, params ...interface{}) error {
 	// 过滤不需要 profiling 的 SQL
 	switch sqlparser.Preview(sql) {
 	case sqlparser.StmtSelect, sqlparser.StmtUpdate, sqlparser.StmtDelete:
 	default:
-		return rows, errors.New("no need profiling")
+		return errors.New("no need profiling")
 	}
 
 	// 测试环境如果检查是关闭的，则 SQL 不会被执行
 	if common.Config.TestDSN.Disable {
-		return rows, errors.New("dsn is disable")
+		return errors.New("dsn is disable")
 	}
 
 	// 数据库安全性检查：如果 Connector 的 IP 端口与 TEST 环境不一致，则启用 SQL 白名单
 	// 不在白名单中的 SQL 不允许执行
 	// 执行环境与 test 环境不相同
 	if db.Addr != common.Config.TestDSN.Addr && db.dangerousQuery(sql) {
-		return rows, fmt.Errorf("query execution deny: Execute SQL with DSN(%s/%s) '%s'",
+		return fmt.Errorf("query execution deny: Execute SQL with DSN(%s/%s) '%s'",
 			db.Addr, db.Database, fmt.Sprintf(sql, params...))
 	}
+	return nil
+}
+
+// Profiling 执行SQL，并对其 Profile
+func (db *Connector) Profiling(sql string, params ...interface{}) ([]ProfilingRow, error) {
+	var rows []ProfilingRow
+	if err := db.profilingCheck(sql, params...); err != nil {
+		return rows, err
+	}
 
 	common.Log.Debug("Execute SQL with DSN(%s/%s) : %s", db.Addr, db.Database, sql)
 	// Keep connection
@@ -123,3 +131,217 @@ func FormatProfiling(rows []ProfilingRow) string {
 	}
 	return strings.Join(str, "\n")
 }
+
+// StatementProfiling performance_schema 中记录的 SQL 执行信息，时间单位为秒
+type StatementProfiling struct {
+	Duration        float64
+	LockTime        float64
+	RowsExamined    int64
+	RowsSent        int64
+	TmpTables       int64 // CREATED_TMP_TABLES
+	TmpDiskTables   int64 // CREATED_TMP_DISK_TABLES
+	SortMergePasses int64
+	SelectScan      int64 // 第一张表全表扫描的次数
+	SelectFullJoin  int64 // 被驱动表没有使用索引导致全表扫描的次数
+	NoIndexUsed     bool
+	Stages          []ProfilingRow
+	Waits           []ProfilingWait
+	Disabled        []string // 未开启的 consumer 及 instrument，对应的信息无法获取
+}
+
+// ProfilingWait 同一类等待事件的汇总
+type ProfilingWait struct {
+	Event    string
+	Count    int64
+	Duration float64
+}
+
+// profilingMaxWaits 最多列出的等待事件个数
+const profilingMaxWaits = 10
+
+// picoseconds performance_schema 中 TIMER_WAIT 等时间的单位为皮秒
+const picoseconds = 1e12
+
+// profilingConsumers 获取语句统计信息、执行阶段及等待事件需要开启的 consumer
+var profilingConsumers = []string{
+	"global_instrumentation",
+	"thread_instrumentation",
+	"events_statements_history",
+	"events_stages_history_long",
+	"events_waits_history_long",
+}
+
+// PerformanceSchemaProfiling 执行 SQL，从 performance_schema 中获取执行阶段、等待事件及语句的统计信息
+// 需要开启 performance_schema，不会修改 setup_* 中的配置，未开启的 instrument 和 consumer 记录在 Disabled 中
+func (db *Connector) PerformanceSchemaProfiling(sql string, params ...interface{}) (*StatementProfiling, error) {
+	if err := db.profilingCheck(sql, params...); err != nil {
+		return nil, err
+	}
+
+	common.Log.Debug("Execute SQL with DSN(%s/%s) : %s", db.Addr, db.Database, sql)
+	// 所有的查询需要在同一个连接中执行
+	trx, err := db.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		trxErr := trx.Rollback()
+		if trxErr != nil {
+			common.Log.Debug(trxErr.Error())
+		}
+	}()
+
+	var threadID int64
+	var instrumented string
+	err = trx.QueryRow("SELECT THREAD_ID, INSTRUMENTED FROM performance_schema.threads WHERE PROCESSLIST_ID = CONNECTION_ID()").Scan(&threadID, &instrumented)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只读取 stage 及 wait 事件的采集配置，未开启的部分在结果中给出提示
+	p := &StatementProfiling{}
+	if instrumented != "YES" {
+		p.Disabled = append(p.Disabled, "threads.INSTRUMENTED")
+	}
+	enabled := make(map[string]bool)
+	res, err := trx.Query("SELECT NAME FROM performance_schema.setup_consumers WHERE ENABLED = 'YES'")
+	if err != nil {
+		return nil, err
+	}
+	for res.Next() {
+		var name string
+		if err = res.Scan(&name); err != nil {
+			common.LogIfError(err, "")
+			break
+		}
+		enabled[name] = true
+	}
+	res.Close()
+	for _, consumer := range profilingConsumers {
+		if !enabled[consumer] {
+			p.Disabled = append(p.Disabled, "setup_consumers."+consumer)
+		}
+	}
+	res, err = trx.Query(`SELECT SUBSTRING_INDEX(NAME, '/', 1), COUNT(*) FROM performance_schema.setup_instruments
+WHERE (NAME LIKE 'stage/%' OR NAME LIKE 'wait/%') AND (ENABLED = 'NO' OR TIMED = 'NO') GROUP BY 1 ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	for res.Next() {
+		var name string
+		var count int64
+		if err = res.Scan(&name, &count); err != nil {
+			common.LogIfError(err, "")
+			break
+		}
+		p.Disabled = append(p.Disabled, fmt.Sprintf("setup_instruments.%s/%% (%d)", name, count))
+	}
+	res.Close()
+
+	// 执行 SQL，抛弃返回结果
+	tmpRes, err := trx.Query(sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	for tmpRes.Next() {
+		continue
+	}
+	tmpRes.Close()
+
+	// 当前线程最近一条执行完成的语句即为刚刚执行的 SQL
+	var eventID, endEventID, timerWait, lockTime int64
+	var noIndexUsed int64
+	err = trx.QueryRow(`SELECT EVENT_ID, IFNULL(END_EVENT_ID, EVENT_ID), IFNULL(TIMER_WAIT, 0), LOCK_TIME, ROWS_EXAMINED, ROWS_SENT,
+  CREATED_TMP_TABLES, CREATED_TMP_DISK_TABLES, SORT_MERGE_PASSES, SELECT_SCAN, SELECT_FULL_JOIN, NO_INDEX_USED
+FROM performance_schema.events_statements_history
+WHERE THREAD_ID = ? ORDER BY EVENT_ID DESC LIMIT 1`, threadID).Scan(&eventID, &endEventID, &timerWait, &lockTime,
+		&p.RowsExamined, &p.RowsSent, &p.TmpTables, &p.TmpDiskTables, &p.SortMergePasses,
+		&p.SelectScan, &p.SelectFullJoin, &noIndexUsed)
+	if err != nil {
+		return nil, err
+	}
+	p.Duration = float64(timerWait) / picoseconds
+	p.LockTime = float64(lockTime) / picoseconds
+	p.NoIndexUsed = noIndexUsed > 0
+
+	res, err = trx.Query(`SELECT EVENT_NAME, IFNULL(TIMER_WAIT, 0) FROM performance_schema.events_stages_history_long
+WHERE THREAD_ID = ? AND NESTING_EVENT_ID = ? ORDER BY EVENT_ID`, threadID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	for res.Next() {
+		var stage ProfilingRow
+		var wait int64
+		err = res.Scan(&stage.Status, &wait)
+		if err != nil {
+			common.LogIfError(err, "")
+			break
+		}
+		stage.Status = strings.TrimPrefix(stage.Status, "stage/sql/")
+		stage.Duration = float64(wait) / picoseconds
+		p.Stages = append(p.Stages, stage)
+	}
+	res.Close()
+
+	// 等待事件嵌套在 stage 中，按事件 ID 的范围过滤
+	res, err = trx.Query(`SELECT EVENT_NAME, COUNT(*), IFNULL(SUM(TIMER_WAIT), 0) FROM performance_schema.events_waits_history_long
+WHERE THREAD_ID = ? AND EVENT_ID > ? AND EVENT_ID <= ? GROUP BY EVENT_NAME ORDER BY SUM(TIMER_WAIT) DESC LIMIT ?`,
+		threadID, eventID, endEventID, profilingMaxWaits)
+	if err != nil {
+		return nil, err
+	}
+	for res.Next() {
+		var wait ProfilingWait
+		var sum float64
+		err = res.Scan(&wait.Event, &wait.Count, &sum)
+		if err != nil {
+			common.LogIfError(err, "")
+			break
+		}
+		wait.Duration = sum / picoseconds
+		p.Waits = append(p.Waits, wait)
+	}
+	res.Close()
+	return p, err
+}
+
+// FormatStatementProfiling 格式化输出 performance_schema 中获取的 Profiling 信息
+func FormatStatementProfiling(p *StatementProfiling) string {
+	str := []string{"| Item | Value |", "| --- | --- |"}
+	for _, item := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"duration", fmt.Sprintf("%f", p.Duration)},
+		{"lock_time", fmt.Sprintf("%f", p.LockTime)},
+		{"rows_examined", p.RowsExamined},
+		{"rows_sent", p.RowsSent},
+		{"tmp_tables", p.TmpTables},
+		{"tmp_disk_tables", p.TmpDiskTables},
+		{"sort_merge_passes", p.SortMergePasses},
+		{"select_scan", p.SelectScan},
+		{"select_full_join", p.SelectFullJoin},
+		{"no_index_used", p.NoIndexUsed},
+	} {
+		str = append(str, fmt.Sprintf("| %s | %v |", item.name, item.value))
+	}
+
+	if len(p.Stages) > 0 {
+		str = append(str, "", FormatProfiling(p.Stages))
+	}
+
+	if len(p.Waits) > 0 {
+		str = append(str, "", "| Wait Event | Count | Duration |", "| --- | --- | --- |")
+		for _, wait := range p.Waits {
+			str = append(str, fmt.Sprintf("| %s | %d | %f |", wait.Event, wait.Count, wait.Duration))
+		}
+	}
+
+	if len(p.Disabled) > 0 {
+		str = append(str, "", "以下 performance_schema 配置未开启，相关信息可能缺失：", "")
+		for _, item := range p.Disabled {
+			str = append(str, "* "+item)
+		}
+	}
+	return strings.Join(str, "\n")
+}
@@ -47,3 +47,47 @@ func TestFormatProfiling(t *testing.T) {
 	pretty.Println(FormatProfiling(res))
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestPerformanceSchemaProfiling(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	res, err := connTest.PerformanceSchemaProfiling("select 1")
+	if err != nil {
+		t.Error(err)
+	}
+	pretty.Println(res)
+	_, err = connTest.PerformanceSchemaProfiling("show tables")
+	if err == nil {
+		t.Error("show tables should not be profiled")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatStatementProfiling(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	p := &StatementProfiling{
+		Duration:       0.0123,
+		LockTime:       0.0001,
+		RowsExamined:   16049,
+		RowsSent:       10,
+		TmpTables:      1,
+		TmpDiskTables:  1,
+		SelectScan:     1,
+		SelectFullJoin: 1,
+		NoIndexUsed:    true,
+		Stages: []ProfilingRow{
+			{Status: "starting", Duration: 0.000062},
+			{Status: "Sending data", Duration: 0.0121},
+		},
+		Waits: []ProfilingWait{
+			{Event: "wait/io/table/sql/handler", Count: 16049, Duration: 0.0061},
+		},
+		Disabled: []string{"setup_consumers.events_waits_history_long", "setup_instruments.wait/% (342)"},
+	}
+	err := common.GoldenDiff(func() {
+		pretty.Println(FormatStatementProfiling(p))
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// SessionStatusCounters 执行 SQL 前后对比的 SESSION STATUS
+// Innodb_rows_read 只有全局计数，测试环境中有其他会话时会偏大
+var SessionStatusCounters = []string{
+	"Handler_read_first",
+	"Handler_read_key",
+	"Handler_read_last",
+	"Handler_read_next",
+	"Handler_read_prev",
+	"Handler_read_rnd",
+	"Handler_read_rnd_next",
+	"Select_scan",
+	"Select_full_join",
+	"Sort_merge_passes",
+	"Created_tmp_disk_tables",
+	"Innodb_rows_read",
+}
+
+// SessionStatus 执行一次 SQL 前后 SESSION STATUS 的增量
+type SessionStatus struct {
+	Query    string
+	RowsSent int64
+	Delta    map[string]int64
+}
+
+// HandlerRead Handler_read% 增量之和，即存储引擎层读取的行数
+func (s *SessionStatus) HandlerRead() int64 {
+	var read int64
+	for name, value := range s.Delta {
+		if strings.HasPrefix(name, "Handler_read_") {
+			read += value
+		}
+	}
+	return read
+}
+
+// SessionStatus 在测试环境中执行 SELECT，返回执行前后 SessionStatusCounters 的增量
+func (db *Connector) SessionStatus(query string) (*SessionStatus, error) {
+	if sqlparser.Preview(query) != sqlparser.StmtSelect {
+		return nil, errors.New("no need session status")
+	}
+	if err := db.profilingCheck(query); err != nil {
+		return nil, err
+	}
+
+	common.Log.Debug("SessionStatus SQL with DSN(%s/%s) : %s", db.Addr, db.Database, query)
+	// SESSION STATUS 需要在同一个连接中获取
+	trx, err := db.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		trxErr := trx.Rollback()
+		if trxErr != nil {
+			common.Log.Debug(trxErr.Error())
+		}
+	}()
+
+	where := fmt.Sprintf("WHERE Variable_name IN ('%s')", strings.Join(SessionStatusCounters, "', '"))
+	status := &SessionStatus{Query: query}
+	status.Delta, err = sessionStatusDelta(trx, where, func() error {
+		res, err := trx.Query(query)
+		if err != nil {
+			return err
+		}
+		for res.Next() {
+			status.RowsSent++
+		}
+		err = res.Err()
+		res.Close()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// FormatSessionStatus 格式化输出 SESSION STATUS 的增量
+func FormatSessionStatus(s *SessionStatus) string {
+	str := []string{"| Variable_name | Delta |", "| --- | --- |"}
+	for _, name := range SessionStatusCounters {
+		str = append(str, fmt.Sprintf("| %s | %d |", name, s.Delta[name]))
+	}
+	str = append(str, fmt.Sprintf("| rows_sent | %d |", s.RowsSent))
+	return strings.Join(str, "\n")
+}
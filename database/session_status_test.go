@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestSessionStatus(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	res, err := connTest.SessionStatus("select * from film")
+	if err != nil {
+		t.Error(err)
+	} else if res.RowsSent == 0 || res.Delta["Select_scan"] != 1 {
+		t.Errorf("unexpected session status: %s", pretty.Sprint(res))
+	}
+	_, err = connTest.SessionStatus("delete from film")
+	if err == nil {
+		t.Error("delete should not be executed")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatSessionStatus(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	s := &SessionStatus{
+		Query:    "select * from film where length > 100",
+		RowsSent: 610,
+		Delta: map[string]int64{
+			"Handler_read_first":    1,
+			"Handler_read_rnd_next": 1001,
+			"Select_scan":           1,
+			"Innodb_rows_read":      1000,
+		},
+	}
+	if s.HandlerRead() != 1002 {
+		t.Errorf("HandlerRead want 1002, got %d", s.HandlerRead())
+	}
+	err := common.GoldenDiff(func() {
+		pretty.Println(FormatSessionStatus(s))
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -521,6 +521,11 @@ func (db *Connector) ShowCreateTable(tableName string) (string, error) {
 	return ddl, err
 }
 
+// ShowCreateTableRaw show create table，与 ShowCreateTable 不同，保留外键约束
+func (db *Connector) ShowCreateTableRaw(tableName string) (string, error) {
+	return db.showCreate("TABLE", tableName)
+}
+
 // FindColumn find column
 func (db *Connector) FindColumn(name, dbName string, tables ...string) ([]*common.Column, error) {
 	// 执行 show create table
@@ -620,29 +625,45 @@ type Reference map[string][]ReferenceValue
 
 // ReferenceValue 用于处理表之间的关系
 type ReferenceValue struct {
-	ReferencedTableSchema string // 夫表所属数据库
-	ReferencedTableName   string // 父表
-	TableSchema           string // 子表所属数据库
-	TableName             string // 子表
-	ConstraintName        string // 关系名称
+	ReferencedTableSchema string   // 夫表所属数据库
+	ReferencedTableName   string   // 父表
+	TableSchema           string   // 子表所属数据库
+	TableName             string   // 子表
+	ConstraintName        string   // 关系名称
+	Columns               []string // 子表中的外键列，按 ORDINAL_POSITION 排序
+	ReferencedColumns     []string // 父表中被引用的列
 }
 
-// ShowReference 查找所有的外键信息
+// ShowReference 查找所有的外键信息，指定表名时只查找这些表作为子表的外键
 func (db *Connector) ShowReference(dbName string, tbName ...string) ([]ReferenceValue, error) {
-	var referenceValues []ReferenceValue
-	sql := `SELECT DISTINCT C.REFERENCED_TABLE_SCHEMA,C.REFERENCED_TABLE_NAME,C.TABLE_SCHEMA,C.TABLE_NAME,C.CONSTRAINT_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE C JOIN INFORMATION_SCHEMA. TABLES T ON T.TABLE_NAME = C.TABLE_NAME WHERE C.REFERENCED_TABLE_NAME IS NOT NULL`
-	sql = sql + fmt.Sprintf(` AND C.TABLE_SCHEMA = "%s"`, Escape(dbName, false))
-
+	where := fmt.Sprintf("TABLE_SCHEMA = '%s'", Escape(dbName, false))
 	var tables []string
 	for _, tb := range tbName {
 		tables = append(tables, "'"+Escape(tb, false)+"'")
 	}
 	if len(tbName) > 0 {
-		extra := fmt.Sprintf(` AND C.TABLE_NAME IN ("%s")`, strings.Join(tables, ","))
-		sql = sql + extra
+		where += fmt.Sprintf(" AND TABLE_NAME IN (%s)", strings.Join(tables, ","))
 	}
+	return db.showReference("ShowReference", where)
+}
+
+// ShowReferenced 查找引用了该表的外键信息，即表作为父表的外键
+// 子表的外键列和父表的被引用列上都需要有索引
+func (db *Connector) ShowReferenced(dbName, tbName string) ([]ReferenceValue, error) {
+	where := fmt.Sprintf("REFERENCED_TABLE_SCHEMA = '%s' AND REFERENCED_TABLE_NAME = '%s'",
+		Escape(dbName, false), Escape(tbName, false))
+	return db.showReference("ShowReferenced", where)
+}
 
-	common.Log.Debug("ShowReference, execute SQL: %s", sql)
+// showReference 按条件查找外键，多列外键按约束合并
+func (db *Connector) showReference(caller, where string) ([]ReferenceValue, error) {
+	var referenceValues []ReferenceValue
+	sql := "SELECT REFERENCED_TABLE_SCHEMA, REFERENCED_TABLE_NAME, TABLE_SCHEMA, TABLE_NAME, CONSTRAINT_NAME, " +
+		"COLUMN_NAME, REFERENCED_COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE " +
+		"WHERE REFERENCED_TABLE_NAME IS NOT NULL AND " + where +
+		" ORDER BY TABLE_SCHEMA, TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION"
+
+	common.Log.Debug("%s, execute SQL: %s", caller, sql)
 	// 执行SQL查找外键关联关系
 	res, err := db.Query(sql)
 	if err != nil {
@@ -652,12 +673,120 @@ func (db *Connector) ShowReference(dbName string, tbName ...string) ([]Reference
 	// 获取值
 	for res.Rows.Next() {
 		var rv ReferenceValue
-		err = res.Rows.Scan(&rv.ReferencedTableSchema, &rv.ReferencedTableName, &rv.TableSchema, &rv.TableName, &rv.ConstraintName)
+		var col, refCol string
+		err = res.Rows.Scan(&rv.ReferencedTableSchema, &rv.ReferencedTableName, &rv.TableSchema, &rv.TableName,
+			&rv.ConstraintName, &col, &refCol)
 		if err != nil {
 			break
 		}
+		if last := len(referenceValues) - 1; last >= 0 && referenceValues[last].ConstraintName == rv.ConstraintName &&
+			referenceValues[last].TableSchema == rv.TableSchema && referenceValues[last].TableName == rv.TableName {
+			referenceValues[last].Columns = append(referenceValues[last].Columns, col)
+			referenceValues[last].ReferencedColumns = append(referenceValues[last].ReferencedColumns, refCol)
+			continue
+		}
+		rv.Columns = []string{col}
+		rv.ReferencedColumns = []string{refCol}
 		referenceValues = append(referenceValues, rv)
 	}
 	res.Rows.Close()
 	return referenceValues, err
 }
+
+// UnusedIndexRow 实例启动后没有被读取过的索引
+type UnusedIndexRow struct {
+	Schema string // 数据库名
+	Table  string // 表名
+	Index  string // 索引名
+}
+
+// ShowUnusedIndexes 从 performance_schema 中查找实例启动以来没有被读取过的索引，不含主键
+// performance_schema 查询失败时尝试使用 sys.schema_unused_indexes
+func (db *Connector) ShowUnusedIndexes(dbName string) ([]UnusedIndexRow, error) {
+	var rows []UnusedIndexRow
+	enabled, err := db.SingleIntValue("performance_schema")
+	if err != nil {
+		return rows, err
+	}
+	if enabled != 1 {
+		return rows, fmt.Errorf("performance_schema is disabled on %s", db.Addr)
+	}
+
+	sql := fmt.Sprintf("SELECT OBJECT_SCHEMA, OBJECT_NAME, INDEX_NAME "+
+		"FROM performance_schema.table_io_waits_summary_by_index_usage "+
+		"WHERE INDEX_NAME IS NOT NULL AND INDEX_NAME <> 'PRIMARY' AND COUNT_READ = 0 AND OBJECT_SCHEMA = '%s' "+
+		"ORDER BY OBJECT_NAME, INDEX_NAME", Escape(dbName, false))
+	common.Log.Debug("ShowUnusedIndexes, execute SQL: %s", sql)
+	res, err := db.Query(sql)
+	if err != nil {
+		sql = fmt.Sprintf("SELECT object_schema, object_name, index_name FROM sys.schema_unused_indexes "+
+			"WHERE object_schema = '%s' ORDER BY object_name, index_name", Escape(dbName, false))
+		common.Log.Debug("ShowUnusedIndexes, execute SQL: %s", sql)
+		res, err = db.Query(sql)
+		if err != nil {
+			return rows, err
+		}
+	}
+
+	for res.Rows.Next() {
+		var row UnusedIndexRow
+		err = res.Rows.Scan(&row.Schema, &row.Table, &row.Index)
+		if err != nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	res.Rows.Close()
+	return rows, err
+}
+
+// ShowIndexSize 从 mysql.innodb_index_stats 获取 InnoDB 表每个索引占用的空间，单位为字节
+// 分区表按索引名汇总各个分区的大小，统计信息未持久化或非 InnoDB 表时没有结果
+func (db *Connector) ShowIndexSize(dbName, tbName string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	sql := fmt.Sprintf("SELECT index_name, CAST(SUM(stat_value) * @@innodb_page_size AS SIGNED) "+
+		"FROM mysql.innodb_index_stats WHERE database_name = '%s' AND (table_name = '%s' OR table_name LIKE '%s#P#%%') "+
+		"AND stat_name = 'size' GROUP BY index_name",
+		Escape(dbName, false), Escape(tbName, false), Escape(tbName, false))
+	common.Log.Debug("ShowIndexSize, execute SQL: %s", sql)
+	res, err := db.Query(sql)
+	if err != nil {
+		return sizes, err
+	}
+
+	for res.Rows.Next() {
+		var name string
+		var size int64
+		err = res.Rows.Scan(&name, &size)
+		if err != nil {
+			break
+		}
+		sizes[name] = size
+	}
+	res.Rows.Close()
+	return sizes, err
+}
+
+// ShowTriggers 获取表上定义的触发器名称
+func (db *Connector) ShowTriggers(dbName, tbName string) ([]string, error) {
+	var triggers []string
+	sql := fmt.Sprintf("SELECT TRIGGER_NAME FROM INFORMATION_SCHEMA.TRIGGERS "+
+		"WHERE EVENT_OBJECT_SCHEMA = '%s' AND EVENT_OBJECT_TABLE = '%s' ORDER BY TRIGGER_NAME",
+		Escape(dbName, false), Escape(tbName, false))
+	common.Log.Debug("ShowTriggers, execute SQL: %s", sql)
+	res, err := db.Query(sql)
+	if err != nil {
+		return triggers, err
+	}
+
+	for res.Rows.Next() {
+		var name string
+		err = res.Rows.Scan(&name)
+		if err != nil {
+			break
+		}
+		triggers = append(triggers, name)
+	}
+	res.Rows.Close()
+	return triggers, err
+}
@@ -18,6 +18,7 @@ package database
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/XiaoMi/soar/common"
@@ -187,3 +188,70 @@ func TestShowReference(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestShowReferenced(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	fks, err := connTest.ShowReferenced("sakila", "city")
+	if err != nil {
+		t.Error("ShowReferenced Error: ", err)
+	}
+	// city 作为父表被 address 引用
+	if len(fks) != 1 || fks[0].TableName != "address" || len(fks[0].ReferencedColumns) != 1 {
+		t.Errorf("want 1 foreign key, got: %s", pretty.Sprint(fks))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestShowUnusedIndexes(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	rows, err := connTest.ShowUnusedIndexes("sakila")
+	if err != nil {
+		t.Error("ShowUnusedIndexes Error: ", err)
+	}
+	for _, row := range rows {
+		if row.Schema != "sakila" || row.Index == "PRIMARY" {
+			t.Errorf("unexpected unused index: %s", pretty.Sprint(row))
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestShowIndexSize(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sizes, err := connTest.ShowIndexSize("sakila", "film")
+	if err != nil {
+		t.Error("ShowIndexSize Error: ", err)
+	}
+	if sizes["PRIMARY"] <= 0 || sizes["idx_title"] <= 0 {
+		t.Errorf("got index sizes: %s", pretty.Sprint(sizes))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestShowTriggers(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	triggers, err := connTest.ShowTriggers("sakila", "film")
+	if err != nil {
+		t.Error("ShowTriggers Error: ", err)
+	}
+	// sakila.film 上有 ins_film, upd_film, del_film 三个触发器
+	if len(triggers) != 3 {
+		t.Errorf("got triggers: %s", pretty.Sprint(triggers))
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestShowCreateTableRaw(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDatabase := connTest.Database
+	connTest.Database = "sakila"
+	ddl, err := connTest.ShowCreateTableRaw("city")
+	if err != nil {
+		t.Error("ShowCreateTableRaw Error: ", err)
+	}
+	if !strings.Contains(ddl, "FOREIGN KEY") {
+		t.Errorf("want foreign key in ddl: %s", ddl)
+	}
+	connTest.Database = orgDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryEvent 从慢查询日志等来源中解析出的一次 SQL 执行，时间单位为秒
+type QueryEvent struct {
+	Query        string
+	Database     string
+	Connection   int64 // 连接 ID，general log 及审计日志中按连接记录当前使用的数据库
+	Timestamp    time.Time
+	QueryTime    float64
+	LockTime     float64
+	RowsSent     int64
+	RowsExamined int64
+}
+
+var (
+	// # Time: 2019-01-01T00:00:00.000000Z 或 # Time: 190101  0:00:00
+	slowLogTimeExp = regexp.MustCompile(`^#\s+Time:\s+(.+)$`)
+	// # Query_time: 0.000123  Lock_time: 0.000050 Rows_sent: 1  Rows_examined: 1000
+	slowLogMetricExp = regexp.MustCompile(`(\w+):\s+(\S+)`)
+	// Percona Server: # Schema: sakila  Last_errno: 0  Killed: 0
+	slowLogSchemaExp = regexp.MustCompile(`^#\s+Schema:\s+(\S+)`)
+	slowLogHeaderExp = regexp.MustCompile(`^#\s+[A-Z]`)
+	slowLogUseExp    = regexp.MustCompile("(?i)^use\\s+`?([^`;]+)`?\\s*;?$")
+	slowLogSetTsExp  = regexp.MustCompile(`(?i)^SET\s+timestamp\s*=\s*(\d+)\s*;?$`)
+	slowLogAdminExp  = regexp.MustCompile(`^#\s+administrator command:`)
+	// mysqld 启动及 FLUSH LOGS 时写入的文件头
+	slowLogBannerExp = regexp.MustCompile(`^(\S+, Version: .+ started with:|Tcp port: \d+|Time\s+Id\s+Command\s+Argument)`)
+)
+
+// ParseSlowLog 解析 MySQL 慢查询日志，use db 及 SET timestamp 会补充到 QueryEvent 中
+func ParseSlowLog(r io.Reader) ([]QueryEvent, error) {
+	var events []QueryEvent
+	var event QueryEvent
+	var query []string
+	var database string
+	admin := false
+
+	// 遇到下一条日志的头部或文件结束时，保存之前的 SQL
+	flush := func() {
+		sql := strings.TrimSuffix(strings.TrimSpace(strings.Join(query, "\n")), ";")
+		if sql != "" && !admin {
+			event.Query = sql
+			event.Database = database
+			events = append(events, event)
+		}
+		event = QueryEvent{}
+		query = nil
+		admin = false
+	}
+
+	scanner := bufio.NewScanner(r)
+	// 单条 SQL 可能很长
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case slowLogHeaderExp.MatchString(trimmed):
+			if len(query) > 0 || admin {
+				flush()
+			}
+			if m := slowLogTimeExp.FindStringSubmatch(trimmed); m != nil {
+				event.Timestamp = parseSlowLogTime(m[1])
+			}
+			if m := slowLogSchemaExp.FindStringSubmatch(trimmed); m != nil {
+				database = m[1]
+			}
+			if strings.Contains(trimmed, "Query_time:") {
+				for _, m := range slowLogMetricExp.FindAllStringSubmatch(trimmed, -1) {
+					switch m[1] {
+					case "Query_time":
+						event.QueryTime, _ = strconv.ParseFloat(m[2], 64)
+					case "Lock_time":
+						event.LockTime, _ = strconv.ParseFloat(m[2], 64)
+					case "Rows_sent":
+						event.RowsSent, _ = strconv.ParseInt(m[2], 10, 64)
+					case "Rows_examined":
+						event.RowsExamined, _ = strconv.ParseInt(m[2], 10, 64)
+					}
+				}
+			}
+		case slowLogAdminExp.MatchString(trimmed):
+			// 如：# administrator command: Quit;
+			admin = true
+		case len(query) == 0 && slowLogBannerExp.MatchString(trimmed):
+		case len(query) == 0 && slowLogUseExp.MatchString(trimmed):
+			database = slowLogUseExp.FindStringSubmatch(trimmed)[1]
+		case len(query) == 0 && slowLogSetTsExp.MatchString(trimmed):
+			ts, _ := strconv.ParseInt(slowLogSetTsExp.FindStringSubmatch(trimmed)[1], 10, 64)
+			event.Timestamp = time.Unix(ts, 0)
+		case len(query) == 0 && trimmed == "":
+		default:
+			query = append(query, line)
+		}
+	}
+	flush()
+	return events, scanner.Err()
+}
+
+// parseSlowLogTime 支持 MySQL 5.7 之后的 RFC3339 格式及之前的 YYMMDD HH:MM:SS 格式
+func parseSlowLogTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	if t, err := time.ParseInLocation("060102 15:04:05", strings.Join(strings.Fields(s), " "), time.Local); err == nil {
+		return t
+	}
+	return time.Time{}
+}
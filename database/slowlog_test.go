@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package database
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestParseSlowLog(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	f, err := os.Open("testdata/slow.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events, err := ParseSlowLog(f)
+	if err != nil {
+		t.Error(err)
+	}
+	err = common.GoldenDiff(func() {
+		for _, event := range events {
+			pretty.Println(event.Query, event.Database, event.Timestamp.UTC().String(),
+				event.QueryTime, event.LockTime, event.RowsSent, event.RowsExamined)
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	events, err = ParseSlowLog(strings.NewReader(""))
+	if err != nil || len(events) != 0 {
+		t.Errorf("empty slow log got events: %v, %v", events, err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
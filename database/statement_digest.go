@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package database
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/percona/go-mysql/query"
+)
+
+// statementDigestSQL events_statements_summary_by_digest 中总执行时间最长的 SQL，时间单位为皮秒
+// MySQL 8.0 中有 QUERY_SAMPLE_TEXT 及 QUANTILE_95，5.7 中只有带占位符的 DIGEST_TEXT
+const (
+	statementDigestSQL80 = `SELECT IFNULL(SCHEMA_NAME, ''), IFNULL(QUERY_SAMPLE_TEXT, DIGEST_TEXT), COUNT_STAR, SUM_TIMER_WAIT,
+  MAX_TIMER_WAIT, QUANTILE_95, SUM_LOCK_TIME, SUM_ROWS_SENT, SUM_ROWS_EXAMINED
+FROM performance_schema.events_statements_summary_by_digest
+WHERE DIGEST_TEXT IS NOT NULL ORDER BY SUM_TIMER_WAIT DESC LIMIT ?`
+	statementDigestSQL57 = `SELECT IFNULL(SCHEMA_NAME, ''), DIGEST_TEXT, COUNT_STAR, SUM_TIMER_WAIT,
+  MAX_TIMER_WAIT, 0, SUM_LOCK_TIME, SUM_ROWS_SENT, SUM_ROWS_EXAMINED
+FROM performance_schema.events_statements_summary_by_digest
+WHERE DIGEST_TEXT IS NOT NULL ORDER BY SUM_TIMER_WAIT DESC LIMIT ?`
+)
+
+// StatementDigests 读取 performance_schema 中总执行时间最长的 n 类 SQL
+// MySQL 5.7 中使用带占位符的 DIGEST_TEXT，没有 P95 耗时，被截断的 SQL 会被忽略，SQL 指纹 ID 相同的多行会合并
+func (db *Connector) StatementDigests(n int) ([]*QueryDigest, error) {
+	if n <= 0 {
+		// 未指定时按 performance_schema_digests_size 的默认值全部读取
+		n = 10000
+	}
+
+	common.Log.Debug("StatementDigests with DSN(%s/%s) top %d", db.Addr, db.Database, n)
+	res, err := db.Conn.Query(statementDigestSQL80, n)
+	if err != nil {
+		// MySQL 5.7 中没有 QUERY_SAMPLE_TEXT 列
+		common.Log.Debug("StatementDigests Error: %v, fallback to DIGEST_TEXT", err)
+		res, err = db.Conn.Query(statementDigestSQL57, n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer res.Close()
+
+	var digests []*QueryDigest
+	for res.Next() {
+		var sample string
+		var sumTimer, maxTimer, p95Timer, lockTime float64
+		digest := &QueryDigest{}
+		err = res.Scan(&digest.Database, &sample, &digest.Count, &sumTimer, &maxTimer, &p95Timer,
+			&lockTime, &digest.RowsSent, &digest.RowsExamined)
+		if err != nil {
+			return digests, err
+		}
+		sample = strings.TrimSpace(sample)
+		if statementTruncated(sample) {
+			common.Log.Debug("StatementDigests skip truncated SQL: %s", sample)
+			continue
+		}
+		digest.Sample = sample
+		digest.Fingerprint = strings.TrimSpace(query.Fingerprint(sample))
+		digest.ID = query.Id(digest.Fingerprint)
+		digest.TotalTime = sumTimer / picoseconds
+		digest.MaxTime = maxTimer / picoseconds
+		digest.P95Time = p95Timer / picoseconds
+		digest.LockTime = lockTime / picoseconds
+		digests = append(digests, digest)
+	}
+	return mergeStatementDigests(digests), res.Err()
+}
+
+// mergeStatementDigests 合并 SQL 指纹 ID 相同的 digest
+// 不同数据库中的同一类 SQL，或 MySQL 与 pt-query-digest 指纹规则不同的 SQL 在 performance_schema 中是多行
+func mergeStatementDigests(digests []*QueryDigest) []*QueryDigest {
+	var merged []*QueryDigest
+	index := make(map[string]*QueryDigest)
+	for _, digest := range digests {
+		m, ok := index[digest.ID]
+		if !ok {
+			index[digest.ID] = digest
+			merged = append(merged, digest)
+			continue
+		}
+		// Sample 及 P95 取执行时间最长的一行
+		if digest.MaxTime > m.MaxTime {
+			m.MaxTime = digest.MaxTime
+			m.Sample = digest.Sample
+			m.Database = digest.Database
+		}
+		if digest.P95Time > m.P95Time {
+			m.P95Time = digest.P95Time
+		}
+		m.Count += digest.Count
+		m.TotalTime += digest.TotalTime
+		m.LockTime += digest.LockTime
+		m.RowsSent += digest.RowsSent
+		m.RowsExamined += digest.RowsExamined
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].TotalTime > merged[j].TotalTime
+	})
+	return merged
+}
+
+// statementTruncated 超过 performance_schema_max_digest_length 或 performance_schema_max_sql_text_length 的 SQL 会被截断
+func statementTruncated(sample string) bool {
+	return strings.HasSuffix(sample, "...")
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestStatementDigests(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	digests, err := connTest.StatementDigests(5)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, digest := range digests {
+		if digest.ID == "" || digest.Count == 0 || statementTruncated(digest.Sample) {
+			t.Errorf("unexpected digest: %s", pretty.Sprint(digest))
+		}
+	}
+
+	// IN 列表被折叠为 (...)，不是截断
+	if statementTruncated("SELECT * FROM `film` WHERE `film_id` IN (...)") {
+		t.Error("IN list should not be treated as truncated")
+	}
+	if !statementTruncated("SELECT `film_id` , `title` , `description` ...") {
+		t.Error("truncated SQL not detected")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestMergeStatementDigests(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	// 不同数据库中的同一类 SQL 合并为一行
+	digests := []*QueryDigest{
+		{ID: "A", Sample: "select * from film where film_id = 1", Database: "sakila", Count: 10, TotalTime: 1, MaxTime: 0.2, P95Time: 0.15, LockTime: 0.01, RowsSent: 10, RowsExamined: 10},
+		{ID: "B", Sample: "select * from actor", Database: "sakila", Count: 1, TotalTime: 1.5, MaxTime: 1.5, P95Time: 1.5, RowsSent: 200, RowsExamined: 200},
+		{ID: "A", Sample: "select * from film where film_id = 2", Database: "sakila2", Count: 20, TotalTime: 1, MaxTime: 0.3, P95Time: 0.1, LockTime: 0.02, RowsSent: 20, RowsExamined: 20},
+	}
+	err := common.GoldenDiff(func() {
+		pretty.Println(mergeStatementDigests(digests))
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -17,9 +17,12 @@
 package database
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/XiaoMi/soar/common"
@@ -124,11 +127,10 @@ func (db *Connector) Trace(sql string, params ...interface{}) ([]TraceRow, error
 
 // FormatTrace 格式化输出Trace信息
 func FormatTrace(rows []TraceRow) string {
-	explainReg := regexp.MustCompile(`(?i)^explain\s+`)
 	str := []string{""}
 	for _, row := range rows {
 		str = append(str, "```sql")
-		sql := explainReg.ReplaceAllString(row.Query, "")
+		sql := traceExplainExp.ReplaceAllString(row.Query, "")
 		str = append(str, sql)
 		str = append(str, "```\n")
 		str = append(str, "```json")
@@ -137,3 +139,241 @@ func FormatTrace(rows []TraceRow) string {
 	}
 	return strings.Join(str, "\n")
 }
+
+// TraceInfo 从 optimizer_trace 中提取的优化器决策过程
+type TraceInfo struct {
+	Query         string
+	RangeAnalysis []TraceRangeAnalysis // 每张表的全表扫描代价及范围扫描分析结果
+	AccessPaths   []TraceAccessPath    // 生成执行计划时每张表考虑过的访问方式
+	Filterings    []TraceFiltering     // 每张表条件过滤后剩余行数的比例
+	JoinOrders    [][]string           // 每个查询块最终选择的连接顺序
+}
+
+// TraceRangeAnalysis rows_estimation 中一张表的行数估算及 range_analysis 结果
+type TraceRangeAnalysis struct {
+	Table      string
+	ScanRows   float64
+	ScanCost   float64
+	Indexes    []TraceRangeIndex
+	ChosenType string // 最终选择的范围扫描类型，如：range_scan, index_merge，没有选择范围扫描时为空
+	ChosenKey  string
+	ChosenRows float64
+	ChosenCost float64
+}
+
+// TraceRangeIndex 范围扫描分析中的一个索引
+type TraceRangeIndex struct {
+	Index     string
+	Usable    bool
+	Ranges    []string
+	SkipDives bool // index_dives_for_eq_ranges 为 false，等值范围过多时使用索引统计信息代替 index dive 估算行数
+	Rows      float64
+	Cost      float64
+	Chosen    bool
+	Cause     string // 不可用或未被选择的原因
+}
+
+// TraceAccessPath best_access_path 中考虑过的一种访问方式
+type TraceAccessPath struct {
+	Table        string
+	AccessType   string
+	Index        string
+	Rows         float64
+	Cost         float64
+	Chosen       bool
+	Cause        string
+	StatsMissing bool // rec_per_key_stats_missing，索引统计信息缺失
+}
+
+// TraceFiltering 一张表的 condition_filtering_pct
+type TraceFiltering struct {
+	Table string
+	Pct   float64
+}
+
+// traceExplainExp optimizer_trace 中记录的 SQL 带有 EXPLAIN 前缀
+var traceExplainExp = regexp.MustCompile(`(?i)^explain\s+`)
+
+// ParseTrace 解析 optimizer_trace 中的 JSON
+func ParseTrace(row TraceRow) (*TraceInfo, error) {
+	var trace interface{}
+	err := json.Unmarshal([]byte(row.Trace), &trace)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TraceInfo{Query: traceExplainExp.ReplaceAllString(row.Query, "")}
+	paths := make(map[string]bool)
+	filterings := make(map[string]bool)
+	walkTrace(trace, func(m map[string]interface{}) {
+		table := traceString(m["table"])
+		if ra, ok := m["range_analysis"].(map[string]interface{}); ok && table != "" {
+			info.RangeAnalysis = append(info.RangeAnalysis, parseTraceRangeAnalysis(table, ra))
+		} else if scan, ok := m["table_scan"].(map[string]interface{}); ok && table != "" {
+			// 没有可用于范围扫描的索引时只有 table_scan
+			info.RangeAnalysis = append(info.RangeAnalysis, TraceRangeAnalysis{
+				Table:    table,
+				ScanRows: traceNumber(scan["rows"]),
+				ScanCost: traceNumber(scan["cost"]),
+			})
+		}
+
+		if bap, ok := m["best_access_path"].(map[string]interface{}); ok && table != "" {
+			// 不同的连接前缀下同一张表会被多次分析，相同的访问方式只保留一次
+			considered, _ := bap["considered_access_paths"].([]interface{})
+			for _, c := range considered {
+				cm, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				path := TraceAccessPath{
+					Table:        table,
+					AccessType:   traceString(cm["access_type"]),
+					Index:        traceString(cm["index"]),
+					Rows:         traceNumber(cm["rows"]),
+					Cost:         traceNumber(cm["cost"]),
+					Chosen:       traceBool(cm["chosen"]),
+					Cause:        traceString(cm["cause"]),
+					StatsMissing: traceBool(cm["rec_per_key_stats_missing"]),
+				}
+				if path.Rows == 0 {
+					path.Rows = traceNumber(cm["rows_to_scan"])
+				}
+				if detail, ok := cm["range_details"].(map[string]interface{}); ok && path.Index == "" {
+					path.Index = traceString(detail["used_index"])
+				}
+				key := fmt.Sprintf("%+v", path)
+				if !paths[key] {
+					paths[key] = true
+					info.AccessPaths = append(info.AccessPaths, path)
+				}
+			}
+			if pct, ok := m["condition_filtering_pct"]; ok {
+				filtering := TraceFiltering{Table: table, Pct: traceNumber(pct)}
+				key := fmt.Sprintf("%+v", filtering)
+				if !filterings[key] {
+					filterings[key] = true
+					info.Filterings = append(info.Filterings, filtering)
+				}
+			}
+		}
+
+		if refine, ok := m["refine_plan"].([]interface{}); ok {
+			var order []string
+			for _, r := range refine {
+				if rm, ok := r.(map[string]interface{}); ok && traceString(rm["table"]) != "" {
+					order = append(order, traceString(rm["table"]))
+				}
+			}
+			if len(order) > 0 {
+				info.JoinOrders = append(info.JoinOrders, order)
+			}
+		}
+	})
+	return info, nil
+}
+
+// parseTraceRangeAnalysis 解析 range_analysis
+func parseTraceRangeAnalysis(table string, ra map[string]interface{}) TraceRangeAnalysis {
+	analysis := TraceRangeAnalysis{Table: table}
+	if scan, ok := ra["table_scan"].(map[string]interface{}); ok {
+		analysis.ScanRows = traceNumber(scan["rows"])
+		analysis.ScanCost = traceNumber(scan["cost"])
+	}
+
+	potential, _ := ra["potential_range_indexes"].([]interface{})
+	for _, p := range potential {
+		pm, ok := p.(map[string]interface{})
+		if !ok || traceBool(pm["usable"]) {
+			continue
+		}
+		analysis.Indexes = append(analysis.Indexes, TraceRangeIndex{
+			Index: traceString(pm["index"]),
+			Cause: traceString(pm["cause"]),
+		})
+	}
+
+	if alternatives, ok := ra["analyzing_range_alternatives"].(map[string]interface{}); ok {
+		scans, _ := alternatives["range_scan_alternatives"].([]interface{})
+		for _, s := range scans {
+			sm, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			dives, ok := sm["index_dives_for_eq_ranges"].(bool)
+			index := TraceRangeIndex{
+				Index:     traceString(sm["index"]),
+				Usable:    true,
+				SkipDives: ok && !dives,
+				Rows:      traceNumber(sm["rows"]),
+				Cost:      traceNumber(sm["cost"]),
+				Chosen:    traceBool(sm["chosen"]),
+				Cause:     traceString(sm["cause"]),
+			}
+			ranges, _ := sm["ranges"].([]interface{})
+			for _, r := range ranges {
+				index.Ranges = append(index.Ranges, traceString(r))
+			}
+			analysis.Indexes = append(analysis.Indexes, index)
+		}
+	}
+
+	if summary, ok := ra["chosen_range_access_summary"].(map[string]interface{}); ok && traceBool(summary["chosen"]) {
+		if plan, ok := summary["range_access_plan"].(map[string]interface{}); ok {
+			analysis.ChosenType = traceString(plan["type"])
+			analysis.ChosenKey = traceString(plan["index"])
+		}
+		analysis.ChosenRows = traceNumber(summary["rows_for_plan"])
+		analysis.ChosenCost = traceNumber(summary["cost_for_plan"])
+	}
+	return analysis
+}
+
+// walkTrace 深度优先遍历 JSON 中的所有对象
+func walkTrace(v interface{}, fn func(m map[string]interface{})) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		fn(val)
+		// 按 key 排序保证遍历顺序稳定
+		var keys []string
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkTrace(val[k], fn)
+		}
+	case []interface{}:
+		for _, item := range val {
+			walkTrace(item, fn)
+		}
+	}
+}
+
+func traceString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func traceNumber(v interface{}) float64 {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case string:
+		f, _ := strconv.ParseFloat(val, 64)
+		return f
+	default:
+		return 0
+	}
+}
+
+func traceBool(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
@@ -58,3 +58,142 @@ func TestFormatTrace(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+// traceSample MySQL 5.7 中 select * from film f join film_actor fa using(film_id) where f.release_year in (...) and fa.actor_id > 10 的 optimizer_trace 节选
+var traceSample = `{
+  "steps": [
+    {"join_preparation": {"select#": 1, "steps": []}},
+    {
+      "join_optimization": {
+        "select#": 1,
+        "steps": [
+          {
+            "rows_estimation": [
+              {
+                "table": "` + "`film` `f`" + `",
+                "range_analysis": {
+                  "table_scan": {"rows": 1000, "cost": 215},
+                  "potential_range_indexes": [
+                    {"index": "PRIMARY", "usable": false, "cause": "not_applicable"},
+                    {"index": "idx_title", "usable": false, "cause": "not_applicable"},
+                    {"index": "idx_release_year", "usable": true, "key_parts": ["release_year", "film_id"]}
+                  ],
+                  "analyzing_range_alternatives": {
+                    "range_scan_alternatives": [
+                      {
+                        "index": "idx_release_year",
+                        "ranges": ["2006 <= release_year <= 2006", "2007 <= release_year <= 2007"],
+                        "index_dives_for_eq_ranges": false,
+                        "rowid_ordered": false,
+                        "using_mrr": false,
+                        "index_only": false,
+                        "rows": 1000,
+                        "cost": 1202,
+                        "chosen": false,
+                        "cause": "cost"
+                      }
+                    ]
+                  }
+                }
+              },
+              {
+                "table": "` + "`film_actor` `fa`" + `",
+                "range_analysis": {
+                  "table_scan": {"rows": 5462, "cost": 1111.5},
+                  "potential_range_indexes": [
+                    {"index": "PRIMARY", "usable": true, "key_parts": ["actor_id", "film_id"]},
+                    {"index": "idx_fk_film_id", "usable": false, "cause": "not_applicable"}
+                  ],
+                  "analyzing_range_alternatives": {
+                    "range_scan_alternatives": [
+                      {
+                        "index": "PRIMARY",
+                        "ranges": ["10 < actor_id"],
+                        "index_dives_for_eq_ranges": true,
+                        "rows": 2731,
+                        "cost": 552.2,
+                        "chosen": true
+                      }
+                    ]
+                  },
+                  "chosen_range_access_summary": {
+                    "range_access_plan": {"type": "range_scan", "index": "PRIMARY", "rows": 2731, "ranges": ["10 < actor_id"]},
+                    "rows_for_plan": 2731,
+                    "cost_for_plan": 552.2,
+                    "chosen": true
+                  }
+                }
+              }
+            ]
+          },
+          {
+            "considered_execution_plans": [
+              {
+                "plan_prefix": [],
+                "table": "` + "`film` `f`" + `",
+                "best_access_path": {
+                  "considered_access_paths": [
+                    {"rows_to_scan": 1000, "access_type": "scan", "resulting_rows": 100, "cost": 213, "chosen": true}
+                  ]
+                },
+                "condition_filtering_pct": 10,
+                "rows_for_plan": 100,
+                "cost_for_plan": 213,
+                "rest_of_plan": [
+                  {
+                    "plan_prefix": ["` + "`film` `f`" + `"],
+                    "table": "` + "`film_actor` `fa`" + `",
+                    "best_access_path": {
+                      "considered_access_paths": [
+                        {"access_type": "ref", "index": "idx_fk_film_id", "rows": 5.4, "cost": 648, "chosen": true, "rec_per_key_stats_missing": true},
+                        {"access_type": "range", "range_details": {"used_index": "PRIMARY"}, "chosen": false, "cause": "heuristic_index_cheaper"}
+                      ]
+                    },
+                    "condition_filtering_pct": 50,
+                    "rows_for_plan": 270,
+                    "cost_for_plan": 915,
+                    "chosen": true
+                  }
+                ]
+              },
+              {
+                "plan_prefix": [],
+                "table": "` + "`film_actor` `fa`" + `",
+                "best_access_path": {
+                  "considered_access_paths": [
+                    {"rows_to_scan": 2731, "access_type": "range", "range_details": {"used_index": "PRIMARY"}, "resulting_rows": 2731, "cost": 1098.4, "chosen": true}
+                  ]
+                },
+                "condition_filtering_pct": 100,
+                "rows_for_plan": 2731,
+                "cost_for_plan": 1098.4,
+                "pruned_by_cost": true
+              }
+            ]
+          },
+          {
+            "refine_plan": [
+              {"table": "` + "`film` `f`" + `"},
+              {"table": "` + "`film_actor` `fa`" + `"}
+            ]
+          }
+        ]
+      }
+    },
+    {"join_execution": {"select#": 1, "steps": []}}
+  ]
+}`
+
+func TestParseTrace(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	err := common.GoldenDiff(func() {
+		info, err := ParseTrace(TraceRow{Query: "explain select 1", Trace: traceSample})
+		pretty.Println(info, err)
+		_, err = ParseTrace(TraceRow{Query: "select 1", Trace: "{"})
+		pretty.Println(err)
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/percona/go-mysql/query"
+)
+
+// QueryDigest 按 SQL 指纹聚合的执行统计，时间单位为秒
+type QueryDigest struct {
+	ID           string // 与评审结果中的 SQL 指纹 ID 一致
+	Fingerprint  string
+	Sample       string // 执行时间最长的一条 SQL
+	Database     string
+	Count        int64
+	TotalTime    float64
+	MaxTime      float64
+	P95Time      float64
+	LockTime     float64
+	RowsSent     int64
+	RowsExamined int64
+}
+
+// AggregateQueryEvents 按 SQL 指纹聚合，按总执行时间从大到小排序，总执行时间相同时按执行次数排序
+func AggregateQueryEvents(events []QueryEvent) []*QueryDigest {
+	var digests []*QueryDigest
+	index := make(map[string]*QueryDigest)
+	times := make(map[string][]float64)
+	for _, event := range events {
+		fingerprint := strings.TrimSpace(query.Fingerprint(event.Query))
+		id := query.Id(fingerprint)
+		digest, ok := index[id]
+		if !ok {
+			digest = &QueryDigest{ID: id, Fingerprint: fingerprint, Sample: event.Query, Database: event.Database}
+			index[id] = digest
+			digests = append(digests, digest)
+		}
+		if event.QueryTime > digest.MaxTime {
+			digest.MaxTime = event.QueryTime
+			digest.Sample = event.Query
+			digest.Database = event.Database
+		}
+		digest.Count++
+		digest.TotalTime += event.QueryTime
+		digest.LockTime += event.LockTime
+		digest.RowsSent += event.RowsSent
+		digest.RowsExamined += event.RowsExamined
+		times[id] = append(times[id], event.QueryTime)
+	}
+
+	for _, digest := range digests {
+		t := times[digest.ID]
+		sort.Float64s(t)
+		// nearest-rank 方法计算 P95
+		rank := int(math.Ceil(0.95*float64(len(t)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		digest.P95Time = t[rank]
+	}
+
+	// general log 及审计日志中没有执行时间，按执行次数排序
+	sort.SliceStable(digests, func(i, j int) bool {
+		if digests[i].TotalTime != digests[j].TotalTime {
+			return digests[i].TotalTime > digests[j].TotalTime
+		}
+		return digests[i].Count > digests[j].Count
+	})
+	return digests
+}
+
+// TopQueryDigests 总执行时间最长的 n 类 SQL，n 小于等于 0 时返回全部
+func TopQueryDigests(digests []*QueryDigest, n int) []*QueryDigest {
+	if n <= 0 || n >= len(digests) {
+		return digests
+	}
+	return digests[:n]
+}
+
+// QueryDigests2SQL 将聚合后的 SQL 拼接为待评审的 SQL，切换数据库时添加 USE 语句
+// 数据库未知的 SQL 切换回 -online-dsn 中的数据库，未指定时放在所有 USE 语句之前，避免沿用上一条 SQL 的数据库
+func QueryDigests2SQL(digests []*QueryDigest) string {
+	var head, buf []string
+	// currentDB 为空时使用的是 -online-dsn 中的数据库
+	currentDB := ""
+	for _, digest := range digests {
+		sql := strings.TrimSuffix(digest.Sample, common.Config.Delimiter) + common.Config.Delimiter
+		switch {
+		case digest.Database != "" && digest.Database != currentDB:
+			currentDB = digest.Database
+			buf = append(buf, fmt.Sprintf("USE `%s`%s", currentDB, common.Config.Delimiter))
+		case digest.Database == "" && currentDB != "":
+			if common.Config.OnlineDSN.Schema == "" {
+				head = append(head, sql)
+				continue
+			}
+			currentDB = ""
+			buf = append(buf, fmt.Sprintf("USE `%s`%s", common.Config.OnlineDSN.Schema, common.Config.Delimiter))
+		}
+		buf = append(buf, sql)
+	}
+	return strings.Join(append(head, buf...), "\n")
+}
+
+// ParseQueryLog 按 -input-format 解析慢查询日志、general log 或审计日志
+func ParseQueryLog(format string, r io.Reader) ([]QueryEvent, error) {
+	switch format {
+	case "slowlog":
+		return ParseSlowLog(r)
+	case "general":
+		return ParseGeneralLog(r)
+	case "audit":
+		return ParseAuditLog(r)
+	default:
+		return nil, fmt.Errorf("not supported input-format: %s", format)
+	}
+}
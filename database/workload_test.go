@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package database
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestAggregateQueryEvents(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	f, err := os.Open("testdata/slow.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events, err := ParseSlowLog(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digests := AggregateQueryEvents(events)
+	err = common.GoldenDiff(func() {
+		pretty.Println(digests)
+		fmt.Println(QueryDigests2SQL(TopQueryDigests(digests, 2)))
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(TopQueryDigests(digests, 0)) != len(digests) {
+		t.Error("TopQueryDigests with n = 0 should return all digests")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestQueryDigests2SQL(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgSchema := common.Config.OnlineDSN.Schema
+	defer func() { common.Config.OnlineDSN.Schema = orgSchema }()
+
+	// 数据库未知的 SQL 不能沿用上一条 SQL 的数据库
+	digests := []*QueryDigest{
+		{Sample: "select * from film", Database: "sakila"},
+		{Sample: "select 1"},
+		{Sample: "select * from t1", Database: "test"},
+		{Sample: "select * from actor", Database: "sakila"},
+	}
+	err := common.GoldenDiff(func() {
+		for _, schema := range []string{"", "world"} {
+			common.Config.OnlineDSN.Schema = schema
+			fmt.Println(QueryDigests2SQL(digests))
+			fmt.Println()
+		}
+	}, t.Name(), update)
+	if err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}